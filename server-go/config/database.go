@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"os"
 
-	"mentorback/models"
+	"mentorback/migrations"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -45,27 +45,16 @@ func InitDatabase() (*gorm.DB, error) {
 	return db, nil
 }
 
-// MigrateDatabase automatically migrates the database schema
+// MigrateDatabase applies the pending versioned migrations. Servers booting at the same time wait
+// for each other, so every migration runs once.
 func MigrateDatabase(db *gorm.DB) error {
 	fmt.Println("Starting database migration...")
 
-	// Add all models that should be auto-migrated here
-	err := db.AutoMigrate(
-		&models.User{},
-		&models.Mentor{},
-		&models.Admin{},
-		&models.Roadmap{},
-		&models.RoadmapStep{},
-		&models.ChatSession{},
-		&models.ChatMessage{},
-		&models.PersonalizedContent{},
-		&models.UserProgress{},
-	)
-
+	applied, err := migrations.Up(db)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("Database migration completed successfully.")
+	fmt.Printf("Database migration completed successfully, %d migrations applied.\n", applied)
 	return nil
 }
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"net/http"
+
+	"mentorback/middleware"
+	"mentorback/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminController handles admin authentication
+type AdminController struct {
+	BaseController
+}
+
+// NewAdminController creates a new admin controller
+func NewAdminController(base BaseController) *AdminController {
+	return &AdminController{BaseController: base}
+}
+
+// AdminLoginRequest represents the admin login request
+type AdminLoginRequest struct {
+	Username string `json:"username" binding:"required"` // Username or email
+	Password string `json:"password" binding:"required"`
+}
+
+// Login logs an admin in, setting the same authentication cookie users and mentors get
+func (ac *AdminController) Login(c *gin.Context) {
+	var request AdminLoginRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var admin models.Admin
+	if err := ac.DB.Where("username = ? OR email = ?", request.Username, request.Username).First(&admin).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		return
+	}
+	if err := admin.ComparePassword(request.Password); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		return
+	}
+
+	if err := middleware.SetAuthCookie(c, admin.ID, "admin"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set authentication cookie: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Login successful",
+		"admin":   admin,
+	})
+}
+
+// GetCurrentAdmin returns the authenticated admin
+func (ac *AdminController) GetCurrentAdmin(c *gin.Context) {
+	admin, exists := c.Get("admin")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin not authenticated"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "admin": admin})
+}
@@ -1,12 +1,40 @@
 package controllers
 
 import (
+	"encoding/json"
+	"fmt"
 	"mentorback/models"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Limits of the platform analytics queries
+const (
+	defaultActiveUserDays      = 30
+	maxActiveUserDays          = 365
+	defaultCohortWeeks         = 8
+	maxCohortWeeks             = 52
+	defaultDropOffInactiveDays = 14 // Days without learning after which a learner has dropped off
+	defaultDropOffMinUsers     = 5  // Learners a topic needs before its rates mean anything
+	defaultDropOffTopics       = 20
+	maxDropOffTopics           = 100
+)
+
+// Plausibility limits of analytics events
+const (
+	maxBatchEvents          = 100
+	maxEventIDLength        = 64
+	maxEventAge             = 7 * 24 * time.Hour // How long a client may queue events before sending them
+	maxEventClockSkew       = 5 * time.Minute    // How far ahead of the server a client's clock may run
+	maxEventLearningMinutes = 240                // Learning time a single event may report
+	maxEventAttempts        = 1000
 )
 
 // AnalyticsController handles analytics-related API requests
@@ -51,9 +79,15 @@ func (ac *AnalyticsController) GetUserAnalytics(c *gin.Context) {
 		ac.DB.Create(&analytics)
 	}
 
-	// Get daily activity for the past 30 days
+	// The streak runs out on days without activity, so it is brought up to date on every read
 	now := time.Now()
-	thirtyDaysAgo := now.AddDate(0, 0, -30)
+	if current, longest, err := models.RecomputeStreaks(ac.DB, userData.ID, userData.Location(), now); err == nil {
+		analytics.StreakDays, analytics.LongestStreak = current, longest
+	}
+
+	// Get daily activity for the past 30 days in the user's timezone
+	today := models.ActivityDay(now, userData.Location())
+	thirtyDaysAgo := today.AddDate(0, 0, -30)
 
 	var dailyActivity []models.DailyActivity
 	ac.DB.Where("user_id = ? AND date >= ?", userData.ID, thirtyDaysAgo).
@@ -61,7 +95,7 @@ func (ac *AnalyticsController) GetUserAnalytics(c *gin.Context) {
 		Find(&dailyActivity)
 
 	// Fill in missing days with zero values
-	completeDailyActivity := ac.fillMissingDailyActivity(dailyActivity, userData.ID, thirtyDaysAgo, now)
+	completeDailyActivity := ac.fillMissingDailyActivity(dailyActivity, userData.ID, thirtyDaysAgo, today)
 
 	// Get top interactions
 	var topInteractions []models.TopicInteraction
@@ -88,6 +122,61 @@ func (ac *AnalyticsController) GetUserAnalytics(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// TopicViewRequest represents a view of a topic
+type TopicViewRequest struct {
+	Topic     string `json:"topic" binding:"required"`
+	TimeSpent int    `json:"timeSpent"`
+}
+
+// TopicCompletionRequest represents the completion of a topic
+type TopicCompletionRequest struct {
+	Topic          string  `json:"topic" binding:"required"`
+	TimeToComplete int     `json:"timeToComplete"`
+	Attempts       int     `json:"attempts"`
+	SuccessRate    float64 `json:"successRate"`
+	Difficulty     int     `json:"difficulty"`
+	QuizScore      float64 `json:"quizScore"`    // Quiz performance score
+	CodeScore      float64 `json:"codeScore"`    // Code exercise performance score
+	AverageScore   float64 `json:"averageScore"` // Combined average score
+	CompletedAt    string  `json:"completedAt"`  // ISO format timestamp when completed
+}
+
+// ExerciseActivityRequest represents an attempt at an exercise
+type ExerciseActivityRequest struct {
+	Topic      string  `json:"topic" binding:"required"`
+	ExerciseID string  `json:"exerciseId" binding:"required"`
+	Completed  bool    `json:"completed"`
+	Score      float64 `json:"score"`
+	TimeSpent  int     `json:"timeSpent"`
+}
+
+// TopicRatingRequest represents a rating of a topic
+type TopicRatingRequest struct {
+	Topic  string `json:"topic" binding:"required"`
+	Rating int    `json:"rating" binding:"required,min=1,max=5"`
+}
+
+// AnalyticsEvent is one event of a batch. The client generates its ID so a retried batch is only
+// counted once, and records when the event happened so queued events land on the right day.
+type AnalyticsEvent struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"` // "topic-view", "topic-completion", "exercise-activity" or "rate-topic"
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"` // The body the single-event endpoint of the type takes
+}
+
+// AnalyticsBatchRequest represents a batch of analytics events
+type AnalyticsBatchRequest struct {
+	Events []AnalyticsEvent `json:"events" binding:"required,min=1"`
+}
+
+// AnalyticsEventResult reports what happened to an event of a batch
+type AnalyticsEventResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"` // "applied", "duplicate" or "rejected"
+	Error  string `json:"error,omitempty"`
+}
+
 // TrackTopicView tracks when a user views a topic
 func (ac *AnalyticsController) TrackTopicView(c *gin.Context) {
 	// Get user from context
@@ -99,104 +188,28 @@ func (ac *AnalyticsController) TrackTopicView(c *gin.Context) {
 	userData := user.(models.User)
 
 	// Parse request
-	var request struct {
-		Topic     string `json:"topic" binding:"required"`
-		TimeSpent int    `json:"timeSpent"`
-	}
-
+	var request TopicViewRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := request.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record the topic under its canonical name from the topic catalog
+	topic, ok := resolveRequestTopic(c, ac.DB, request.Topic)
+	if !ok {
+		return
+	}
 
 	// Update analytics in a transaction
 	err := ac.DB.Transaction(func(tx *gorm.DB) error {
-		// Update user analytics
-		var analytics models.Analytics
-		result := tx.Where("user_id = ?", userData.ID).First(&analytics)
-
-		if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
-			return result.Error
-		}
-
-		now := time.Now()
-
-		// If no analytics record exists, create a new one
-		if result.Error == gorm.ErrRecordNotFound {
-			analytics = models.Analytics{
-				UserID:            userData.ID,
-				TopicsViewed:      1,
-				LastActivityDate:  now,
-				LastTopicAccessed: request.Topic,
-				TotalLearningTime: request.TimeSpent,
-			}
-			if err := tx.Create(&analytics).Error; err != nil {
-				return err
-			}
-		} else {
-			// Update existing analytics
-			analytics.TopicsViewed++
-			analytics.LastActivityDate = now
-			analytics.LastTopicAccessed = request.Topic
-			analytics.TotalLearningTime += request.TimeSpent
-
-			// Update streak if this is a new day
-			if analytics.LastActivityDate.Day() != now.Day() {
-				analytics.StreakDays++
-			}
-
-			if err := tx.Save(&analytics).Error; err != nil {
-				return err
-			}
-		}
-
-		// Update or create topic interaction
-		var topicInteraction models.TopicInteraction
-		result = tx.Where("user_id = ? AND topic_name = ?", userData.ID, request.Topic).First(&topicInteraction)
-
-		if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
-			return result.Error
-		}
-
-		if result.Error == gorm.ErrRecordNotFound {
-			// Create new topic interaction
-			topicInteraction = models.TopicInteraction{
-				UserID:     userData.ID,
-				TopicName:  request.Topic,
-				ViewCount:  1,
-				TimeSpent:  request.TimeSpent,
-				LastViewed: now,
-			}
-			if err := tx.Create(&topicInteraction).Error; err != nil {
-				return err
-			}
-		} else {
-			// Update existing topic interaction
-			topicInteraction.ViewCount++
-			topicInteraction.TimeSpent += request.TimeSpent
-			topicInteraction.LastViewed = now
-
-			if err := tx.Save(&topicInteraction).Error; err != nil {
-				return err
-			}
-		}
-
-		// Create activity log
-		activityLog := models.ActivityLog{
-			UserID:       userData.ID,
-			ActivityType: "topic-view",
-			Description:  "Viewed topic: " + request.Topic,
-			TopicName:    request.Topic,
-			TimeSpent:    request.TimeSpent,
-			Timestamp:    now,
-		}
-
-		if err := tx.Create(&activityLog).Error; err != nil {
+		if err := ac.applyTopicView(tx, userData, topic, request, time.Now()); err != nil {
 			return err
 		}
-
-		// Update daily activity
-		return ac.updateDailyActivity(tx, userData.ID, 1, 0, request.TimeSpent)
+		return recomputeStreaks(tx, userData)
 	})
 
 	if err != nil {
@@ -218,166 +231,28 @@ func (ac *AnalyticsController) TrackTopicCompletion(c *gin.Context) {
 	userData := user.(models.User)
 
 	// Parse request
-	var request struct {
-		Topic          string  `json:"topic" binding:"required"`
-		TimeToComplete int     `json:"timeToComplete"`
-		Attempts       int     `json:"attempts"`
-		SuccessRate    float64 `json:"successRate"`
-		Difficulty     int     `json:"difficulty"`
-		QuizScore      float64 `json:"quizScore"`    // Quiz performance score
-		CodeScore      float64 `json:"codeScore"`    // Code exercise performance score
-		AverageScore   float64 `json:"averageScore"` // Combined average score
-		CompletedAt    string  `json:"completedAt"`  // ISO format timestamp when completed
-	}
-
+	var request TopicCompletionRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := request.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record the topic under its canonical name from the topic catalog
+	topic, ok := resolveRequestTopic(c, ac.DB, request.Topic)
+	if !ok {
+		return
+	}
 
 	// Update analytics in a transaction
 	err := ac.DB.Transaction(func(tx *gorm.DB) error {
-		// Update user analytics
-		var analytics models.Analytics
-		result := tx.Where("user_id = ?", userData.ID).First(&analytics)
-
-		if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
-			return result.Error
-		}
-
-		now := time.Now()
-		completedAt := now
-		if request.CompletedAt != "" {
-			parsedTime, err := time.Parse(time.RFC3339, request.CompletedAt)
-			if err == nil {
-				completedAt = parsedTime
-			}
-		}
-
-		// If no analytics record exists, create a new one
-		if result.Error == gorm.ErrRecordNotFound {
-			analytics = models.Analytics{
-				UserID:              userData.ID,
-				TopicsViewed:        1,
-				TopicsCompleted:     1,
-				LastActivityDate:    now,
-				LastTopicAccessed:   request.Topic,
-				TotalLearningTime:   request.TimeToComplete,
-				AverageQuizScore:    request.SuccessRate,
-				AverageCodeScore:    request.CodeScore,
-				TopicCompletionRate: 100.0, // First topic means 100% completion rate
-			}
-
-			// If quiz score is provided, use it instead of success rate
-			if request.QuizScore > 0 {
-				analytics.AverageQuizScore = request.QuizScore
-			}
-
-			if err := tx.Create(&analytics).Error; err != nil {
-				return err
-			}
-		} else {
-			// Update existing analytics
-			analytics.TopicsCompleted++
-			analytics.LastActivityDate = now
-			analytics.LastTopicAccessed = request.Topic
-			analytics.TotalLearningTime += request.TimeToComplete
-
-			// Update average quiz score (prefer explicit quiz score if provided)
-			scoreToUse := request.SuccessRate
-			if request.QuizScore > 0 {
-				scoreToUse = request.QuizScore
-			}
-			analytics.AverageQuizScore = (analytics.AverageQuizScore*float64(analytics.TopicsCompleted-1) + scoreToUse) / float64(analytics.TopicsCompleted)
-
-			// Update average code score if provided
-			if request.CodeScore > 0 {
-				if analytics.AverageCodeScore == 0 {
-					analytics.AverageCodeScore = request.CodeScore
-				} else {
-					analytics.AverageCodeScore = (analytics.AverageCodeScore*float64(analytics.TopicsCompleted-1) + request.CodeScore) / float64(analytics.TopicsCompleted)
-				}
-			}
-
-			// Update completion rate
-			if analytics.TopicsViewed > 0 {
-				analytics.TopicCompletionRate = float64(analytics.TopicsCompleted) / float64(analytics.TopicsViewed) * 100.0
-			}
-
-			// Update streak if this is a new day
-			if analytics.LastActivityDate.Day() != now.Day() {
-				analytics.StreakDays++
-			}
-
-			if err := tx.Save(&analytics).Error; err != nil {
-				return err
-			}
-		}
-
-		// Update or create topic interaction
-		var topicInteraction models.TopicInteraction
-		result = tx.Where("user_id = ? AND topic_name = ?", userData.ID, request.Topic).First(&topicInteraction)
-
-		if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
-			return result.Error
-		}
-
-		if result.Error == gorm.ErrRecordNotFound {
-			// Create new topic interaction
-			topicInteraction = models.TopicInteraction{
-				UserID:      userData.ID,
-				TopicName:   request.Topic,
-				ViewCount:   1,
-				TimeSpent:   request.TimeToComplete,
-				LastViewed:  now,
-				CompletedAt: completedAt,
-				Difficulty:  request.Difficulty,
-				QuizScore:   request.QuizScore,
-				CodeScore:   request.CodeScore,
-			}
-			if err := tx.Create(&topicInteraction).Error; err != nil {
-				return err
-			}
-		} else {
-			// Update existing topic interaction
-			topicInteraction.CompletedAt = completedAt
-			topicInteraction.TimeSpent += request.TimeToComplete
-
-			// Update difficulty if provided
-			if request.Difficulty > 0 {
-				topicInteraction.Difficulty = request.Difficulty
-			}
-
-			// Update scores if provided
-			if request.QuizScore > 0 {
-				topicInteraction.QuizScore = request.QuizScore
-			}
-			if request.CodeScore > 0 {
-				topicInteraction.CodeScore = request.CodeScore
-			}
-
-			if err := tx.Save(&topicInteraction).Error; err != nil {
-				return err
-			}
-		}
-
-		// Create activity log
-		activityLog := models.ActivityLog{
-			UserID:       userData.ID,
-			ActivityType: "topic-completion",
-			Description:  "Completed topic: " + request.Topic,
-			TopicName:    request.Topic,
-			TimeSpent:    request.TimeToComplete,
-			Score:        request.SuccessRate,
-			Timestamp:    now,
-		}
-
-		if err := tx.Create(&activityLog).Error; err != nil {
+		if err := ac.applyTopicCompletion(tx, userData, topic, request, time.Now()); err != nil {
 			return err
 		}
-
-		// Update daily activity
-		return ac.updateDailyActivity(tx, userData.ID, 0, 1, request.TimeToComplete)
+		return recomputeStreaks(tx, userData)
 	})
 
 	if err != nil {
@@ -399,103 +274,28 @@ func (ac *AnalyticsController) TrackExerciseActivity(c *gin.Context) {
 	userData := user.(models.User)
 
 	// Parse request
-	var request struct {
-		Topic      string  `json:"topic" binding:"required"`
-		ExerciseID string  `json:"exerciseId" binding:"required"`
-		Completed  bool    `json:"completed"`
-		Score      float64 `json:"score"`
-		TimeSpent  int     `json:"timeSpent"`
-	}
-
+	var request ExerciseActivityRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := request.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record the topic under its canonical name from the topic catalog
+	topic, ok := resolveRequestTopic(c, ac.DB, request.Topic)
+	if !ok {
+		return
+	}
 
 	// Update analytics in a transaction
 	err := ac.DB.Transaction(func(tx *gorm.DB) error {
-		// Update user analytics
-		var analytics models.Analytics
-		result := tx.Where("user_id = ?", userData.ID).First(&analytics)
-
-		if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
-			return result.Error
-		}
-
-		now := time.Now()
-
-		// If no analytics record exists, create a new one
-		if result.Error == gorm.ErrRecordNotFound {
-			analytics = models.Analytics{
-				UserID:             userData.ID,
-				LastActivityDate:   now,
-				LastTopicAccessed:  request.Topic,
-				TotalLearningTime:  request.TimeSpent,
-				ExercisesAttempted: 1,
-			}
-
-			if request.Completed {
-				analytics.ExercisesCompleted = 1
-				analytics.AverageQuizScore = request.Score
-			}
-
-			if err := tx.Create(&analytics).Error; err != nil {
-				return err
-			}
-		} else {
-			// Update existing analytics
-			analytics.LastActivityDate = now
-			analytics.LastTopicAccessed = request.Topic
-			analytics.TotalLearningTime += request.TimeSpent
-			analytics.ExercisesAttempted++
-
-			if request.Completed {
-				analytics.ExercisesCompleted++
-				// Update average quiz score
-				analytics.AverageQuizScore = (analytics.AverageQuizScore*float64(analytics.ExercisesCompleted-1) + request.Score) / float64(analytics.ExercisesCompleted)
-			}
-
-			// Update streak if this is a new day
-			if analytics.LastActivityDate.Day() != now.Day() {
-				analytics.StreakDays++
-			}
-
-			if err := tx.Save(&analytics).Error; err != nil {
-				return err
-			}
-		}
-
-		// Create activity log
-		activityType := "exercise-attempt"
-		description := "Attempted exercise in topic: " + request.Topic
-
-		if request.Completed {
-			activityType = "exercise-completion"
-			description = "Completed exercise in topic: " + request.Topic
-		}
-
-		activityLog := models.ActivityLog{
-			UserID:       userData.ID,
-			ActivityType: activityType,
-			Description:  description,
-			TopicName:    request.Topic,
-			ExerciseID:   request.ExerciseID,
-			TimeSpent:    request.TimeSpent,
-			Score:        request.Score,
-			Timestamp:    now,
-		}
-
-		if err := tx.Create(&activityLog).Error; err != nil {
+		if err := ac.applyExerciseActivity(tx, userData, topic, request, time.Now()); err != nil {
 			return err
 		}
-
-		// Update daily activity
-		exercisesDone := 0
-		if request.Completed {
-			exercisesDone = 1
-		}
-
-		return ac.updateDailyActivityWithExercises(tx, userData.ID, 0, 0, request.TimeSpent, exercisesDone)
+		return recomputeStreaks(tx, userData)
 	})
 
 	if err != nil {
@@ -517,174 +317,338 @@ func (ac *AnalyticsController) RateTopic(c *gin.Context) {
 	userData := user.(models.User)
 
 	// Parse request
-	var request struct {
-		Topic  string `json:"topic" binding:"required"`
-		Rating int    `json:"rating" binding:"required,min=1,max=5"`
-	}
-
+	var request TopicRatingRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Update topic interaction
-	var topicInteraction models.TopicInteraction
-	result := ac.DB.Where("user_id = ? AND topic_name = ?", userData.ID, request.Topic).First(&topicInteraction)
+	// Record the topic under its canonical name from the topic catalog
+	topic, ok := resolveRequestTopic(c, ac.DB, request.Topic)
+	if !ok {
+		return
+	}
 
-	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve topic interaction"})
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		return ac.applyTopicRating(tx, userData, topic, request, time.Now())
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record topic rating"})
 		return
 	}
 
-	now := time.Now()
+	c.JSON(http.StatusOK, gin.H{"message": "Topic rating recorded successfully"})
+}
 
-	if result.Error == gorm.ErrRecordNotFound {
-		// Create new topic interaction
-		topicInteraction = models.TopicInteraction{
-			UserID:     userData.ID,
-			TopicName:  request.Topic,
-			ViewCount:  1,
-			LastViewed: now,
-			Rating:     request.Rating,
+// TrackEvents records a batch of analytics events in one transaction. Events are identified by
+// the ID the client gave them, so events that were already recorded are skipped and retrying a
+// batch counts nothing twice. Events that fail validation are rejected without failing the rest.
+func (ac *AnalyticsController) TrackEvents(c *gin.Context) {
+	// Get user from context
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	var request AnalyticsBatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(request.Events) > maxBatchEvents {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A batch may hold at most %d events", maxBatchEvents)})
+		return
+	}
+
+	// Validate every event and resolve its topic before anything is written
+	now := time.Now()
+	results := make([]AnalyticsEventResult, len(request.Events))
+	events := make([]parsedAnalyticsEvent, len(request.Events))
+	topics := make(map[string]models.Topic)
+	for i, event := range request.Events {
+		results[i] = AnalyticsEventResult{ID: event.ID}
+		parsed, err := parseAnalyticsEvent(event, now)
+		if err == nil {
+			topic, ok := topics[parsed.topicName]
+			if !ok {
+				if topic, err = resolveEventTopic(ac.DB, parsed.topicName); err == nil {
+					topics[parsed.topicName] = topic
+				}
+			}
+			parsed.topic = topic
 		}
-		if err := ac.DB.Create(&topicInteraction).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create topic interaction"})
-			return
+		if err != nil {
+			results[i].Status, results[i].Error = "rejected", err.Error()
+			continue
 		}
-	} else {
-		// Update existing topic interaction
-		topicInteraction.Rating = request.Rating
+		events[i] = parsed
+	}
 
-		if err := ac.DB.Save(&topicInteraction).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update topic rating"})
-			return
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		// Events too old to be accepted no longer need their receipts to be recognized
+		if err := tx.Where("user_id = ? AND occurred_at < ?", userData.ID, now.Add(-maxEventAge)).
+			Delete(&models.AnalyticsEventReceipt{}).Error; err != nil {
+			return err
 		}
-	}
 
-	// Create activity log
-	activityLog := models.ActivityLog{
-		UserID:       userData.ID,
-		ActivityType: "topic-rating",
-		Description:  "Rated topic: " + request.Topic,
-		TopicName:    request.Topic,
-		Score:        float64(request.Rating),
-		Timestamp:    now,
-	}
+		applied := 0
+		for i, event := range events {
+			if results[i].Status == "rejected" {
+				continue
+			}
+
+			// The receipt is the deduplication: an event that already has one was recorded before
+			receipt := models.AnalyticsEventReceipt{
+				UserID:     userData.ID,
+				EventID:    event.ID,
+				Type:       event.Type,
+				OccurredAt: event.OccurredAt,
+			}
+			result := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "event_id"}},
+				DoNothing: true,
+			}).Create(&receipt)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				results[i].Status = "duplicate"
+				continue
+			}
+
+			if err := ac.applyAnalyticsEvent(tx, userData, event); err != nil {
+				return err
+			}
+			results[i].Status = "applied"
+			applied++
+		}
 
-	if err := ac.DB.Create(&activityLog).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log activity"})
+		if applied == 0 {
+			return nil
+		}
+		return recomputeStreaks(tx, userData)
+	})
+
+	if err != nil {
+		fmt.Printf("Error recording analytics events of user %d: %v\n", userData.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record events"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Topic rating recorded successfully"})
+	summary := gin.H{"applied": 0, "duplicate": 0, "rejected": 0}
+	for _, result := range results {
+		summary[result.Status] = summary[result.Status].(int) + 1
+	}
+	summary["results"] = results
+	c.JSON(http.StatusOK, summary)
 }
 
-// GetGlobalAnalytics retrieves platform-wide analytics
+// GetGlobalAnalytics retrieves platform-wide analytics from the rollups of the activity log
 func (ac *AnalyticsController) GetGlobalAnalytics(c *gin.Context) {
-	// Get user from context to verify authentication
-	_, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	// Get admin from context to verify authentication
+	if _, exists := c.Get("admin"); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin not authenticated"})
 		return
 	}
 
-	now := time.Now()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	weekAgo := today.AddDate(0, 0, -7)
-
-	// Get total users
-	var totalUsers int64
-	ac.DB.Model(&models.User{}).Count(&totalUsers)
-
-	// Get active users today
-	var activeUsersToday int64
-	ac.DB.Model(&models.Analytics{}).Where("last_activity_date >= ?", today).Count(&activeUsersToday)
+	// Today's rollup holds the user counts; it is computed here if the rollup job has not run yet.
+	// While the job is running, the last day it rolled up is served instead.
+	today := models.ActivityDay(time.Now(), time.UTC)
+	var stat models.PlatformDailyStat
+	err := ac.DB.Where("date = ?", today).First(&stat).Error
+	if err == gorm.ErrRecordNotFound {
+		var rolledUp bool
+		rolledUp, err = models.WithRollupLock(ac.DB, func(conn *gorm.DB) error {
+			var err error
+			stat, err = models.RollupPlatformDay(conn, today)
+			return err
+		})
+		if err == nil && !rolledUp {
+			err = ac.DB.Where("date < ?", today).Order("date DESC").First(&stat).Error
+		}
+	}
+	if err == gorm.ErrRecordNotFound {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Global analytics are being computed, try again shortly"})
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error loading platform rollup: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve global analytics"})
+		return
+	}
 
-	// Get active users this week
-	var activeUsersThisWeek int64
-	ac.DB.Model(&models.Analytics{}).Where("last_activity_date >= ?", weekAgo).Count(&activeUsersThisWeek)
+	totals, err := models.LoadPlatformTotals(ac.DB)
+	if err != nil {
+		fmt.Printf("Error loading platform totals: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve global analytics"})
+		return
+	}
 
-	// Get total topics viewed and completed
-	var totalTopicsViewed, totalTopicsCompleted int64
-	ac.DB.Model(&models.Analytics{}).Select("SUM(topics_viewed)").Row().Scan(&totalTopicsViewed)
-	ac.DB.Model(&models.Analytics{}).Select("SUM(topics_completed)").Row().Scan(&totalTopicsCompleted)
+	mostPopularTopics, err := models.LoadPopularTopics(ac.DB, 5)
+	if err != nil {
+		fmt.Printf("Error loading popular topics: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve global analytics"})
+		return
+	}
 
-	// Get average completion rate
+	// Get average completion rate of the per-user rollups
 	var avgCompletionRate float64
-	ac.DB.Model(&models.Analytics{}).Select("AVG(topic_completion_rate)").Row().Scan(&avgCompletionRate)
+	ac.DB.Model(&models.Analytics{}).Select("COALESCE(AVG(topic_completion_rate), 0)").Row().Scan(&avgCompletionRate)
 
-	// Get most popular topics
-	var popularTopics []models.TopicInteraction
-	ac.DB.Model(&models.TopicInteraction{}).
-		Select("topic_name, SUM(view_count) as view_count").
-		Group("topic_name").
-		Order("view_count DESC").
-		Limit(5).
-		Find(&popularTopics)
-
-	// Extract topic names from popular topics
-	mostPopularTopics := make([]string, len(popularTopics))
-	for i, topic := range popularTopics {
-		mostPopularTopics[i] = topic.TopicName
+	// Get average time per activity
+	avgTimePerSession := 0
+	if totals.Activities > 0 {
+		avgTimePerSession = totals.LearningTimeMin / totals.Activities
 	}
 
-	// Get average time per session
-	var avgTimePerSession float64
-	ac.DB.Model(&models.ActivityLog{}).Select("AVG(time_spent)").Row().Scan(&avgTimePerSession)
-
 	// Create response
 	response := models.GlobalAnalyticsResponse{
-		TotalUsers:            int(totalUsers),
-		ActiveUsersToday:      int(activeUsersToday),
-		ActiveUsersThisWeek:   int(activeUsersThisWeek),
-		TotalTopicsViewed:     int(totalTopicsViewed),
-		TotalTopicsCompleted:  int(totalTopicsCompleted),
+		TotalUsers:            stat.TotalUsers,
+		ActiveUsersToday:      stat.ActiveUsers,
+		ActiveUsersThisWeek:   stat.WeeklyActiveUsers,
+		ActiveUsersThisMonth:  stat.MonthlyActiveUsers,
+		TotalTopicsViewed:     totals.TopicViews,
+		TotalTopicsCompleted:  totals.TopicCompletions,
 		AverageCompletionRate: avgCompletionRate,
 		MostPopularTopics:     mostPopularTopics,
-		AverageTimePerSession: int(avgTimePerSession),
+		AverageTimePerSession: avgTimePerSession,
+		ComputedAt:            stat.UpdatedAt,
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-// Helper function to update daily activity
-func (ac *AnalyticsController) updateDailyActivity(tx *gorm.DB, userID uint, topicsViewed, topicsCompleted, learningTimeMin int) error {
-	now := time.Now()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+// GetActiveUsers returns the daily, weekly and monthly active users of every day in a period
+func (ac *AnalyticsController) GetActiveUsers(c *gin.Context) {
+	if _, exists := c.Get("admin"); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin not authenticated"})
+		return
+	}
 
-	var dailyActivity models.DailyActivity
-	result := tx.Where("user_id = ? AND date = ?", userID, today).First(&dailyActivity)
+	days, _ := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultActiveUserDays)))
+	if days <= 0 || days > maxActiveUserDays {
+		days = defaultActiveUserDays
+	}
 
-	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
-		return result.Error
+	today := models.ActivityDay(time.Now(), time.UTC)
+	series, err := models.LoadActiveUsers(ac.DB, today.AddDate(0, 0, 1-days), today)
+	if err != nil {
+		fmt.Printf("Error loading active users: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve active users"})
+		return
 	}
 
-	if result.Error == gorm.ErrRecordNotFound {
-		// Create new daily activity
-		dailyActivity = models.DailyActivity{
-			UserID:          userID,
-			Date:            today,
-			TopicsViewed:    topicsViewed,
-			TopicsCompleted: topicsCompleted,
-			LearningTimeMin: learningTimeMin,
+	c.JSON(http.StatusOK, gin.H{"activeUsers": series})
+}
+
+// GetCohortRetention returns how many of the users who signed up in each recent week came back in
+// the weeks after
+func (ac *AnalyticsController) GetCohortRetention(c *gin.Context) {
+	if _, exists := c.Get("admin"); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin not authenticated"})
+		return
+	}
+
+	weeks, _ := strconv.Atoi(c.DefaultQuery("weeks", strconv.Itoa(defaultCohortWeeks)))
+	if weeks <= 0 || weeks > maxCohortWeeks {
+		weeks = defaultCohortWeeks
+	}
+
+	cohorts, err := models.LoadCohortRetention(ac.DB, weeks, time.Now())
+	if err != nil {
+		fmt.Printf("Error loading cohort retention: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cohort retention"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cohorts": cohorts})
+}
+
+// GetFunnel returns how far the users who signed up in a period got from onboarding to their first
+// completed topic
+func (ac *AnalyticsController) GetFunnel(c *gin.Context) {
+	if _, exists := c.Get("admin"); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin not authenticated"})
+		return
+	}
+
+	// The period is given in days, the last one included; it defaults to the last 90 days
+	today := models.ActivityDay(time.Now(), time.UTC)
+	from, to := today.AddDate(0, 0, -89), today
+	if value := c.Query("from"); value != "" {
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "From must be in YYYY-MM-DD format"})
+			return
 		}
-		return tx.Create(&dailyActivity).Error
-	} else {
-		// Update existing daily activity
-		dailyActivity.TopicsViewed += topicsViewed
-		dailyActivity.TopicsCompleted += topicsCompleted
-		dailyActivity.LearningTimeMin += learningTimeMin
-		return tx.Save(&dailyActivity).Error
+		from = date
+	}
+	if value := c.Query("to"); value != "" {
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "To must be in YYYY-MM-DD format"})
+			return
+		}
+		to = date
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "To must not be before from"})
+		return
+	}
+
+	steps, err := models.LoadFunnel(ac.DB, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		fmt.Printf("Error loading funnel: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve funnel"})
+		return
 	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":  from.Format("2006-01-02"),
+		"to":    to.Format("2006-01-02"),
+		"steps": steps,
+	})
 }
 
-// Helper function to update daily activity with exercises
-func (ac *AnalyticsController) updateDailyActivityWithExercises(tx *gorm.DB, userID uint, topicsViewed, topicsCompleted, learningTimeMin, exercisesDone int) error {
-	now := time.Now()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+// GetTopicDropOff returns the topics learners most often stop learning at without completing them
+func (ac *AnalyticsController) GetTopicDropOff(c *gin.Context) {
+	if _, exists := c.Get("admin"); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin not authenticated"})
+		return
+	}
+
+	inactiveDays, _ := strconv.Atoi(c.DefaultQuery("inactiveDays", strconv.Itoa(defaultDropOffInactiveDays)))
+	if inactiveDays <= 0 {
+		inactiveDays = defaultDropOffInactiveDays
+	}
+	minUsers, _ := strconv.Atoi(c.DefaultQuery("minUsers", strconv.Itoa(defaultDropOffMinUsers)))
+	if minUsers <= 0 {
+		minUsers = defaultDropOffMinUsers
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultDropOffTopics)))
+	if limit <= 0 || limit > maxDropOffTopics {
+		limit = defaultDropOffTopics
+	}
+
+	inactiveSince := models.ActivityDay(time.Now(), time.UTC).AddDate(0, 0, -inactiveDays)
+	topics, err := models.LoadTopicDropOff(ac.DB, inactiveSince, minUsers, limit)
+	if err != nil {
+		fmt.Printf("Error loading topic drop-off: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve topic drop-off"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"inactiveDays": inactiveDays, "topics": topics})
+}
+
+// updateDailyActivity adds to the user's activity on the day of a moment in their timezone
+func (ac *AnalyticsController) updateDailyActivity(tx *gorm.DB, user models.User, at time.Time, topicsViewed, topicsCompleted, learningTimeMin, exercisesDone int) error {
+	day := models.ActivityDay(at, user.Location())
 
 	var dailyActivity models.DailyActivity
-	result := tx.Where("user_id = ? AND date = ?", userID, today).First(&dailyActivity)
+	result := tx.Where("user_id = ? AND date = ?", user.ID, day).First(&dailyActivity)
 
 	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
 		return result.Error
@@ -693,22 +657,22 @@ func (ac *AnalyticsController) updateDailyActivityWithExercises(tx *gorm.DB, use
 	if result.Error == gorm.ErrRecordNotFound {
 		// Create new daily activity
 		dailyActivity = models.DailyActivity{
-			UserID:          userID,
-			Date:            today,
+			UserID:          user.ID,
+			Date:            day,
 			TopicsViewed:    topicsViewed,
 			TopicsCompleted: topicsCompleted,
 			LearningTimeMin: learningTimeMin,
 			ExercisesDone:   exercisesDone,
 		}
 		return tx.Create(&dailyActivity).Error
-	} else {
-		// Update existing daily activity
-		dailyActivity.TopicsViewed += topicsViewed
-		dailyActivity.TopicsCompleted += topicsCompleted
-		dailyActivity.LearningTimeMin += learningTimeMin
-		dailyActivity.ExercisesDone += exercisesDone
-		return tx.Save(&dailyActivity).Error
 	}
+
+	// Update existing daily activity
+	dailyActivity.TopicsViewed += topicsViewed
+	dailyActivity.TopicsCompleted += topicsCompleted
+	dailyActivity.LearningTimeMin += learningTimeMin
+	dailyActivity.ExercisesDone += exercisesDone
+	return tx.Save(&dailyActivity).Error
 }
 
 // Helper function to fill in missing daily activity data
@@ -716,7 +680,7 @@ func (ac *AnalyticsController) fillMissingDailyActivity(dailyActivity []models.D
 	// Create a map to lookup existing activity by date
 	activityMap := make(map[string]models.DailyActivity)
 	for _, activity := range dailyActivity {
-		dateKey := activity.Date.Format("2006-01-02")
+		dateKey := activity.Date.UTC().Format("2006-01-02")
 		activityMap[dateKey] = activity
 	}
 
@@ -740,3 +704,383 @@ func (ac *AnalyticsController) fillMissingDailyActivity(dailyActivity []models.D
 
 	return completeActivity
 }
+
+// parsedAnalyticsEvent is a validated event of a batch with its body decoded
+type parsedAnalyticsEvent struct {
+	AnalyticsEvent
+	topicName  string
+	topic      models.Topic
+	view       TopicViewRequest
+	completion TopicCompletionRequest
+	exercise   ExerciseActivityRequest
+	rating     TopicRatingRequest
+}
+
+// parseAnalyticsEvent decodes the body of an event and checks that the event is plausible
+func parseAnalyticsEvent(event AnalyticsEvent, now time.Time) (parsedAnalyticsEvent, error) {
+	parsed := parsedAnalyticsEvent{AnalyticsEvent: event}
+	parsed.ID = strings.TrimSpace(event.ID)
+	if parsed.ID == "" || len(parsed.ID) > maxEventIDLength {
+		return parsed, fmt.Errorf("Event ID must be between 1 and %d characters", maxEventIDLength)
+	}
+	if event.OccurredAt.IsZero() {
+		return parsed, fmt.Errorf("Event has no occurredAt time")
+	}
+	if event.OccurredAt.After(now.Add(maxEventClockSkew)) {
+		return parsed, fmt.Errorf("Event occurred in the future")
+	}
+	if event.OccurredAt.Before(now.Add(-maxEventAge)) {
+		return parsed, fmt.Errorf("Event is older than %d days", int(maxEventAge.Hours()/24))
+	}
+
+	var request interface{}
+	switch event.Type {
+	case "topic-view":
+		request = &parsed.view
+	case "topic-completion":
+		request = &parsed.completion
+	case "exercise-activity":
+		request = &parsed.exercise
+	case "rate-topic":
+		request = &parsed.rating
+	default:
+		return parsed, fmt.Errorf("Unknown event type %q", event.Type)
+	}
+	if err := json.Unmarshal(event.Data, request); err != nil {
+		return parsed, fmt.Errorf("Invalid event data: %v", err)
+	}
+	if err := binding.Validator.ValidateStruct(request); err != nil {
+		return parsed, err
+	}
+
+	var err error
+	switch event.Type {
+	case "topic-view":
+		parsed.topicName, err = parsed.view.Topic, parsed.view.validate()
+	case "topic-completion":
+		parsed.topicName, err = parsed.completion.Topic, parsed.completion.validate()
+	case "exercise-activity":
+		parsed.topicName, err = parsed.exercise.Topic, parsed.exercise.validate()
+	case "rate-topic":
+		parsed.topicName = parsed.rating.Topic
+	}
+	return parsed, err
+}
+
+// validate checks that a topic view is plausible
+func (r TopicViewRequest) validate() error {
+	return validateLearningMinutes(r.TimeSpent)
+}
+
+// validate checks that a topic completion is plausible
+func (r TopicCompletionRequest) validate() error {
+	if err := validateLearningMinutes(r.TimeToComplete); err != nil {
+		return err
+	}
+	if r.Attempts < 0 || r.Attempts > maxEventAttempts {
+		return fmt.Errorf("Attempts must be between 0 and %d", maxEventAttempts)
+	}
+	if r.Difficulty < 0 || r.Difficulty > 5 {
+		return fmt.Errorf("Difficulty must be between 0 and 5")
+	}
+	for _, score := range []float64{r.SuccessRate, r.QuizScore, r.CodeScore, r.AverageScore} {
+		if err := validateScore(score); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks that exercise activity is plausible
+func (r ExerciseActivityRequest) validate() error {
+	if err := validateLearningMinutes(r.TimeSpent); err != nil {
+		return err
+	}
+	return validateScore(r.Score)
+}
+
+// validateLearningMinutes checks the minutes of learning one event reports
+func validateLearningMinutes(minutes int) error {
+	if minutes < 0 || minutes > maxEventLearningMinutes {
+		return fmt.Errorf("Time spent must be between 0 and %d minutes", maxEventLearningMinutes)
+	}
+	return nil
+}
+
+// validateScore checks a percentage score
+func validateScore(score float64) error {
+	if score < 0 || score > 100 {
+		return fmt.Errorf("Scores must be between 0 and 100")
+	}
+	return nil
+}
+
+// resolveEventTopic resolves the free-text topic of an event against the topic catalog
+func resolveEventTopic(db *gorm.DB, name string) (models.Topic, error) {
+	if models.TopicSlug(name) == "" {
+		return models.Topic{}, fmt.Errorf("Topic must contain letters or digits")
+	}
+
+	topic, err := models.ResolveTopic(db, name)
+	if err != nil {
+		fmt.Printf("Error resolving topic %q: %v\n", name, err)
+		return topic, fmt.Errorf("Failed to resolve topic")
+	}
+	return topic, nil
+}
+
+// applyAnalyticsEvent records a validated event of a batch at the time it occurred
+func (ac *AnalyticsController) applyAnalyticsEvent(tx *gorm.DB, user models.User, event parsedAnalyticsEvent) error {
+	switch event.Type {
+	case "topic-view":
+		return ac.applyTopicView(tx, user, event.topic, event.view, event.OccurredAt)
+	case "topic-completion":
+		return ac.applyTopicCompletion(tx, user, event.topic, event.completion, event.OccurredAt)
+	case "exercise-activity":
+		return ac.applyExerciseActivity(tx, user, event.topic, event.exercise, event.OccurredAt)
+	case "rate-topic":
+		return ac.applyTopicRating(tx, user, event.topic, event.rating, event.OccurredAt)
+	}
+	return fmt.Errorf("unknown event type %q", event.Type)
+}
+
+// applyTopicView records a view of a topic at a given time
+func (ac *AnalyticsController) applyTopicView(tx *gorm.DB, user models.User, topic models.Topic, request TopicViewRequest, at time.Time) error {
+	// Update or create topic interaction
+	var topicInteraction models.TopicInteraction
+	result := tx.Where("user_id = ? AND topic_id = ?", user.ID, topic.ID).First(&topicInteraction)
+
+	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+		return result.Error
+	}
+
+	if result.Error == gorm.ErrRecordNotFound {
+		// Create new topic interaction
+		topicInteraction = models.TopicInteraction{
+			UserID:     user.ID,
+			TopicName:  topic.Name,
+			TopicID:    &topic.ID,
+			ViewCount:  1,
+			TimeSpent:  request.TimeSpent,
+			LastViewed: at,
+		}
+		if err := tx.Create(&topicInteraction).Error; err != nil {
+			return err
+		}
+	} else {
+		// Update existing topic interaction
+		topicInteraction.ViewCount++
+		topicInteraction.TimeSpent += request.TimeSpent
+		if at.After(topicInteraction.LastViewed) {
+			topicInteraction.LastViewed = at
+		}
+
+		if err := tx.Save(&topicInteraction).Error; err != nil {
+			return err
+		}
+	}
+
+	// Create activity log
+	activityLog := models.ActivityLog{
+		UserID:       user.ID,
+		ActivityType: "topic-view",
+		Description:  "Viewed topic: " + topic.Name,
+		TopicName:    topic.Name,
+		TopicID:      &topic.ID,
+		TimeSpent:    request.TimeSpent,
+		Timestamp:    at,
+	}
+
+	if err := tx.Create(&activityLog).Error; err != nil {
+		return err
+	}
+
+	// Update daily activity
+	return ac.updateDailyActivity(tx, user, at, 1, 0, request.TimeSpent, 0)
+}
+
+// applyTopicCompletion records the completion of a topic at a given time
+func (ac *AnalyticsController) applyTopicCompletion(tx *gorm.DB, user models.User, topic models.Topic, request TopicCompletionRequest, at time.Time) error {
+	completedAt := at
+	if request.CompletedAt != "" {
+		parsedTime, err := time.Parse(time.RFC3339, request.CompletedAt)
+		if err == nil {
+			completedAt = parsedTime
+		}
+	}
+
+	// Update or create topic interaction
+	var topicInteraction models.TopicInteraction
+	result := tx.Where("user_id = ? AND topic_id = ?", user.ID, topic.ID).First(&topicInteraction)
+
+	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+		return result.Error
+	}
+
+	if result.Error == gorm.ErrRecordNotFound {
+		// Create new topic interaction
+		topicInteraction = models.TopicInteraction{
+			UserID:      user.ID,
+			TopicName:   topic.Name,
+			TopicID:     &topic.ID,
+			ViewCount:   1,
+			TimeSpent:   request.TimeToComplete,
+			LastViewed:  at,
+			CompletedAt: completedAt,
+			Difficulty:  request.Difficulty,
+			QuizScore:   request.QuizScore,
+			CodeScore:   request.CodeScore,
+		}
+		if err := tx.Create(&topicInteraction).Error; err != nil {
+			return err
+		}
+	} else {
+		// Update existing topic interaction
+		topicInteraction.CompletedAt = completedAt
+		topicInteraction.TimeSpent += request.TimeToComplete
+
+		// Update difficulty if provided
+		if request.Difficulty > 0 {
+			topicInteraction.Difficulty = request.Difficulty
+		}
+
+		// Update scores if provided
+		if request.QuizScore > 0 {
+			topicInteraction.QuizScore = request.QuizScore
+		}
+		if request.CodeScore > 0 {
+			topicInteraction.CodeScore = request.CodeScore
+		}
+
+		if err := tx.Save(&topicInteraction).Error; err != nil {
+			return err
+		}
+	}
+
+	// Create activity log
+	activityLog := models.ActivityLog{
+		UserID:       user.ID,
+		ActivityType: "topic-completion",
+		Description:  "Completed topic: " + topic.Name,
+		TopicName:    topic.Name,
+		TopicID:      &topic.ID,
+		TimeSpent:    request.TimeToComplete,
+		Score:        request.SuccessRate,
+		Timestamp:    at,
+	}
+
+	if err := tx.Create(&activityLog).Error; err != nil {
+		return err
+	}
+
+	// The topic's progress and roadmap steps are completed by grading, when its concepts become
+	// mastered, not by this report
+
+	// Update daily activity
+	return ac.updateDailyActivity(tx, user, at, 0, 1, request.TimeToComplete, 0)
+}
+
+// applyExerciseActivity records an attempt at an exercise at a given time
+func (ac *AnalyticsController) applyExerciseActivity(tx *gorm.DB, user models.User, topic models.Topic, request ExerciseActivityRequest, at time.Time) error {
+	// Create activity log
+	activityType := "exercise-attempt"
+	description := "Attempted exercise in topic: " + topic.Name
+
+	if request.Completed {
+		activityType = "exercise-completion"
+		description = "Completed exercise in topic: " + topic.Name
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:       user.ID,
+		ActivityType: activityType,
+		Description:  description,
+		TopicName:    topic.Name,
+		TopicID:      &topic.ID,
+		ExerciseID:   request.ExerciseID,
+		TimeSpent:    request.TimeSpent,
+		Score:        request.Score,
+		Timestamp:    at,
+	}
+
+	if err := tx.Create(&activityLog).Error; err != nil {
+		return err
+	}
+
+	// Update daily activity
+	exercisesDone := 0
+	if request.Completed {
+		exercisesDone = 1
+	}
+
+	return ac.updateDailyActivity(tx, user, at, 0, 0, request.TimeSpent, exercisesDone)
+}
+
+// applyTopicRating records a rating of a topic at a given time
+func (ac *AnalyticsController) applyTopicRating(tx *gorm.DB, user models.User, topic models.Topic, request TopicRatingRequest, at time.Time) error {
+	// Update topic interaction
+	var topicInteraction models.TopicInteraction
+	result := tx.Where("user_id = ? AND topic_id = ?", user.ID, topic.ID).First(&topicInteraction)
+
+	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+		return result.Error
+	}
+
+	if result.Error == gorm.ErrRecordNotFound {
+		// Create new topic interaction
+		topicInteraction = models.TopicInteraction{
+			UserID:     user.ID,
+			TopicName:  topic.Name,
+			TopicID:    &topic.ID,
+			ViewCount:  1,
+			LastViewed: at,
+			Rating:     request.Rating,
+		}
+		if err := tx.Create(&topicInteraction).Error; err != nil {
+			return err
+		}
+	} else {
+		// Update existing topic interaction
+		topicInteraction.Rating = request.Rating
+
+		if err := tx.Save(&topicInteraction).Error; err != nil {
+			return err
+		}
+	}
+
+	// Create activity log
+	activityLog := models.ActivityLog{
+		UserID:       user.ID,
+		ActivityType: "topic-rating",
+		Description:  "Rated topic: " + topic.Name,
+		TopicName:    topic.Name,
+		TopicID:      &topic.ID,
+		Score:        float64(request.Rating),
+		Timestamp:    at,
+	}
+
+	return tx.Create(&activityLog).Error
+}
+
+// recomputeStreaks brings the user's streaks up to date after their daily activity changed
+func recomputeStreaks(tx *gorm.DB, user models.User) error {
+	_, _, err := models.RecomputeStreaks(tx, user.ID, user.Location(), time.Now())
+	return err
+}
+
+// resolveRequestTopic resolves the free-text topic of a request against the topic catalog,
+// responding with an error if it cannot be resolved
+func resolveRequestTopic(c *gin.Context, db *gorm.DB, name string) (models.Topic, bool) {
+	if models.TopicSlug(name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Topic must contain letters or digits"})
+		return models.Topic{}, false
+	}
+
+	topic, err := models.ResolveTopic(db, name)
+	if err != nil {
+		fmt.Printf("Error resolving topic %q: %v\n", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve topic"})
+		return models.Topic{}, false
+	}
+	return topic, true
+}
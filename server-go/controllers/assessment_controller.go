@@ -0,0 +1,631 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"mentorback/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// assessmentPassingScore is the percentage needed to pass an assessment
+	assessmentPassingScore = 70.0
+	// assessmentSecondsPerQuestion sets the time limit relative to the number of questions
+	assessmentSecondsPerQuestion = 90
+	// assessmentGracePeriod absorbs network latency when a submission arrives right at the deadline
+	assessmentGracePeriod = 30 * time.Second
+	// assessmentMaxQuestions caps the size of a roadmap exam
+	assessmentMaxQuestions = 20
+	// assessmentTopicQuestions is the number of questions in a single-topic exam
+	assessmentTopicQuestions = 10
+	// defaultAssessmentWindowHours is the time between attempts when ASSESSMENT_WINDOW_HOURS is not set
+	defaultAssessmentWindowHours = 24
+)
+
+// AssessmentController handles timed assessments on topics and roadmaps
+type AssessmentController struct {
+	BaseController
+}
+
+// NewAssessmentController creates a new assessment controller
+func NewAssessmentController(base BaseController) *AssessmentController {
+	return &AssessmentController{BaseController: base}
+}
+
+// StartAssessmentRequest represents a request to start an assessment on a roadmap or a topic
+type StartAssessmentRequest struct {
+	RoadmapID *uint  `json:"roadmapId"`
+	Topic     string `json:"topic"`
+}
+
+// SubmitAssessmentRequest maps question IDs to the selected option index
+type SubmitAssessmentRequest struct {
+	Answers map[string]int `json:"answers" binding:"required"`
+}
+
+// AssessmentQuestion is a question as shown while the assessment is running, without the answer key
+type AssessmentQuestion struct {
+	ID       uint     `json:"id"`
+	Topic    string   `json:"topic"`
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+}
+
+// AssessmentQuestionResult is a graded question shown after the assessment is submitted
+type AssessmentQuestionResult struct {
+	AssessmentQuestion
+	SelectedAnswer *int   `json:"selectedAnswer"`
+	CorrectAnswer  int    `json:"correctAnswer"`
+	Correct        bool   `json:"correct"`
+	Explanation    string `json:"explanation,omitempty"`
+}
+
+// StartAssessment assembles an exam from the exercise bank and starts its timer
+func (ac *AssessmentController) StartAssessment(c *gin.Context) {
+	// Get user from context
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	var request StartAssessmentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Work out which topics the exam covers
+	assessment := models.Assessment{
+		UserID:       userData.ID,
+		RoadmapID:    request.RoadmapID,
+		Topic:        request.Topic,
+		Status:       models.AssessmentStatusInProgress,
+		PassingScore: assessmentPassingScore,
+		Answers:      make(models.AssessmentAnswers),
+	}
+
+	var topics []string
+	questionsPerTopic := assessmentTopicQuestions
+
+	if request.RoadmapID != nil {
+		var roadmap models.Roadmap
+		if err := ac.DB.Where("id = ? AND user_id = ?", *request.RoadmapID, userData.ID).
+			Preload("Steps", func(db *gorm.DB) *gorm.DB { return db.Order("\"order\" ASC") }).
+			First(&roadmap).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
+			return
+		}
+		if len(roadmap.Steps) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Roadmap has no steps to assess"})
+			return
+		}
+
+		assessment.Topic = roadmap.Topic
+		for _, step := range roadmap.Steps {
+			topics = append(topics, step.Name)
+		}
+		questionsPerTopic = max(1, min(3, assessmentMaxQuestions/len(topics)))
+	} else if request.Topic != "" {
+		topics = []string{request.Topic}
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either roadmapId or topic is required"})
+		return
+	}
+
+	// Allow one attempt per window, resuming an attempt that is still running
+	previous, err := findAttemptInWindow(ac.DB, userData.ID, request.RoadmapID, assessment.Topic)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check previous attempts"})
+		return
+	}
+	if previous != nil {
+		ac.respondWithPreviousAttempt(c, previous)
+		return
+	}
+
+	items := ac.assembleQuestions(userData.ID, topics, questionsPerTopic)
+	if len(items) == 0 {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Could not assemble questions for this assessment"})
+		return
+	}
+
+	now := time.Now()
+	for _, item := range items {
+		assessment.ExerciseItemIDs = append(assessment.ExerciseItemIDs, int64(item.ID))
+	}
+	assessment.TimeLimitSec = len(items) * assessmentSecondsPerQuestion
+	assessment.StartedAt = now
+	assessment.ExpiresAt = now.Add(time.Duration(assessment.TimeLimitSec) * time.Second)
+
+	// Assembling the questions takes a while, so check the window again with the user's row locked.
+	// Concurrent requests to start the same assessment then wait for each other and only one starts.
+	err = ac.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").First(&models.User{}, userData.ID).Error; err != nil {
+			return err
+		}
+		previous, err = findAttemptInWindow(tx, userData.ID, request.RoadmapID, assessment.Topic)
+		if err != nil || previous != nil {
+			return err
+		}
+		return tx.Create(&assessment).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start assessment"})
+		return
+	}
+	if previous != nil {
+		ac.respondWithPreviousAttempt(c, previous)
+		return
+	}
+
+	ac.respondWithQuestions(c, &assessment)
+}
+
+// respondWithPreviousAttempt resumes an attempt inside the retry window that is still running, or
+// tells when the next attempt is allowed
+func (ac *AssessmentController) respondWithPreviousAttempt(c *gin.Context, previous *models.Assessment) {
+	ac.expireIfOverdue(previous)
+	if previous.Status == models.AssessmentStatusInProgress {
+		ac.respondWithQuestions(c, previous)
+		return
+	}
+
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":      "Only one attempt is allowed per assessment window",
+		"retryAfter": previous.StartedAt.Add(assessmentWindow()),
+	})
+}
+
+// GetAssessment returns a running assessment's questions or a finished assessment's results
+func (ac *AssessmentController) GetAssessment(c *gin.Context) {
+	// Get user from context
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	assessment, ok := ac.findOwnedAssessment(c, userData.ID)
+	if !ok {
+		return
+	}
+
+	ac.expireIfOverdue(assessment)
+	if assessment.Status == models.AssessmentStatusInProgress {
+		ac.respondWithQuestions(c, assessment)
+		return
+	}
+
+	ac.respondWithResults(c, assessment)
+}
+
+// ListAssessments returns the user's assessment history
+func (ac *AssessmentController) ListAssessments(c *gin.Context) {
+	// Get user from context
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	var assessments []models.Assessment
+	if err := ac.DB.Where("user_id = ?", userData.ID).
+		Order("started_at DESC").
+		Find(&assessments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve assessments"})
+		return
+	}
+
+	for i := range assessments {
+		ac.expireIfOverdue(&assessments[i])
+		// Answers are only revealed through the results of a single assessment
+		assessments[i].Answers = nil
+	}
+
+	c.JSON(http.StatusOK, gin.H{"assessments": assessments})
+}
+
+// SubmitAssessment grades an assessment on the server and marks the covered topics mastered on a pass
+func (ac *AssessmentController) SubmitAssessment(c *gin.Context) {
+	// Get user from context
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	var request SubmitAssessmentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	assessment, ok := ac.findOwnedAssessment(c, userData.ID)
+	if !ok {
+		return
+	}
+
+	if assessment.Status != models.AssessmentStatusInProgress {
+		c.JSON(http.StatusConflict, gin.H{"error": "Assessment has already been submitted"})
+		return
+	}
+
+	now := time.Now()
+	if assessment.IsExpired(now, assessmentGracePeriod) {
+		ac.expireIfOverdue(assessment)
+		c.JSON(http.StatusGone, gin.H{"error": "The time limit for this assessment has passed"})
+		return
+	}
+
+	items, err := ac.loadItems(assessment.ExerciseItemIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load assessment questions"})
+		return
+	}
+
+	// Only keep answers to questions that belong to this assessment
+	answers := make(models.AssessmentAnswers)
+	for _, item := range items {
+		key := strconv.FormatUint(uint64(item.ID), 10)
+		if answer, answered := request.Answers[key]; answered {
+			answers[key] = answer
+		}
+	}
+
+	// Grade with the assessment locked, so a concurrent submission of it waits and is then rejected
+	submitted := false
+	err = ac.DB.Transaction(func(tx *gorm.DB) error {
+		var locked models.Assessment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", assessment.ID, models.AssessmentStatusInProgress).
+			Limit(1).Find(&locked).Error; err != nil {
+			return err
+		}
+		if locked.ID == 0 {
+			submitted = true
+			return nil
+		}
+
+		correctCount := 0
+		topicTotals := make(map[string]int)
+		topicCorrect := make(map[string]int)
+
+		for i := range items {
+			key := strconv.FormatUint(uint64(items[i].ID), 10)
+			answer, answered := answers[key]
+			correct := answered && answer == items[i].CorrectAnswer
+
+			topicTotals[items[i].Topic]++
+			if correct {
+				correctCount++
+				topicCorrect[items[i].Topic]++
+			}
+
+			// Feed the learner's first answer back into the bank's quality signals
+			answeredBefore, err := models.HasAnsweredExercise(tx, userData.ID, items[i].ID)
+			if err != nil {
+				return err
+			}
+			if !answeredBefore {
+				if _, err := models.RecordExerciseAnswer(tx, items[i].ID, correct); err != nil {
+					return err
+				}
+			}
+		}
+
+		assessment.TopicScores = make(models.TopicScores)
+		for topic, total := range topicTotals {
+			assessment.TopicScores[topic] = float64(topicCorrect[topic]) / float64(total) * 100
+		}
+
+		assessment.Answers = answers
+		assessment.SubmittedAt = &now
+		assessment.Score = 0
+		if len(items) > 0 {
+			assessment.Score = float64(correctCount) / float64(len(items)) * 100
+		}
+
+		assessment.Status = models.AssessmentStatusFailed
+		if assessment.Score >= assessment.PassingScore {
+			assessment.Status = models.AssessmentStatusPassed
+			if err := ac.markMastered(tx, assessment, now); err != nil {
+				return err
+			}
+		}
+
+		return tx.Save(assessment).Error
+	})
+
+	if err != nil {
+		fmt.Printf("Error grading assessment %d: %v\n", assessment.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grade assessment"})
+		return
+	}
+	if submitted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Assessment has already been submitted"})
+		return
+	}
+
+	ac.respondWithResults(c, assessment)
+}
+
+// markMastered marks the roadmap steps and topics of a passed assessment as mastered. Only topics
+// the assessment asked about and the learner passed on their own score count, so a strong topic
+// does not carry a weak one or one left out of the exam.
+func (ac *AssessmentController) markMastered(tx *gorm.DB, assessment *models.Assessment, at time.Time) error {
+	passed := make(map[string]bool)
+	for topic, score := range assessment.TopicScores {
+		if score >= assessment.PassingScore {
+			passed[models.NormalizeTopicKey(topic)] = true
+		}
+	}
+
+	var steps []models.RoadmapStep
+	query := tx.Joins("JOIN roadmaps ON roadmaps.id = roadmap_steps.roadmap_id").
+		Where("roadmaps.user_id = ? AND roadmaps.deleted_at IS NULL", assessment.UserID)
+	if assessment.RoadmapID != nil {
+		query = query.Where("roadmap_steps.roadmap_id = ?", *assessment.RoadmapID)
+	} else {
+		query = query.Where("LOWER(TRIM(roadmap_steps.name)) = ?", models.NormalizeTopicKey(assessment.Topic))
+	}
+	if err := query.Find(&steps).Error; err != nil {
+		return err
+	}
+
+	topics := make(map[string]bool)
+	ids := make([]uint, 0, len(steps))
+	for _, step := range steps {
+		if passed[models.NormalizeTopicKey(step.Name)] {
+			topics[step.Name] = true
+			ids = append(ids, step.ID)
+		}
+	}
+	if assessment.RoadmapID == nil && passed[models.NormalizeTopicKey(assessment.Topic)] {
+		topics[assessment.Topic] = true
+	}
+
+	if len(ids) > 0 {
+		if err := tx.Model(&models.RoadmapStep{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"completed":    true,
+			"completed_at": gorm.Expr("COALESCE(completed_at, ?)", at),
+			"mastered":     true,
+			"mastered_at":  at,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	for topic := range topics {
+		if err := markTopicMastered(tx, assessment.UserID, topic, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assembleQuestions picks quiz questions for each topic from the bank, generating any that are missing.
+// Questions the learner has already seen the answers of are left out, so the exam tests what they know.
+func (ac *AssessmentController) assembleQuestions(userID uint, topics []string, perTopic int) []models.ExerciseItem {
+	exerciseController := NewExerciseController(ac.BaseController)
+	selected := make(map[uint]bool)
+	var items []models.ExerciseItem
+
+	for _, topic := range topics {
+		if len(items) >= assessmentMaxQuestions {
+			break
+		}
+
+		bankItems, err := models.SelectBankExercises(ac.DB, topic, "quiz", "", userID, perTopic)
+		if err != nil {
+			fmt.Printf("Error loading bank questions for '%s': %v\n", topic, err)
+		}
+
+		topicItems := make([]models.ExerciseItem, 0, perTopic)
+		for _, item := range bankItems {
+			if !selected[item.ID] {
+				topicItems = append(topicItems, item)
+			}
+		}
+
+		// Generate the remaining questions and add them to the bank
+		if missing := perTopic - len(topicItems); missing > 0 {
+			generated, err := exerciseController.generateQuizExercises(topic, "intermediate", missing)
+			if err != nil {
+				fmt.Printf("Error generating assessment questions for '%s': %v\n", topic, err)
+			}
+			for _, exercise := range generated {
+				if exercise.placeholder || len(topicItems) >= perTopic {
+					continue
+				}
+				item, err := exerciseController.storeExercise(topic, exercise)
+				if err != nil || item.Placeholder || item.Retired || selected[item.ID] {
+					continue
+				}
+				// A generated question can match one the learner has already answered
+				answered, err := models.HasAnsweredExercise(ac.DB, userID, item.ID)
+				if err != nil || answered {
+					continue
+				}
+				topicItems = append(topicItems, item)
+				selected[item.ID] = true
+			}
+		}
+
+		for _, item := range topicItems {
+			if len(items) >= assessmentMaxQuestions {
+				break
+			}
+			selected[item.ID] = true
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// findAttemptInWindow returns the most recent attempt on the same roadmap or topic inside the retry window
+func findAttemptInWindow(db *gorm.DB, userID uint, roadmapID *uint, topic string) (*models.Assessment, error) {
+	query := db.Where("user_id = ? AND started_at > ?", userID, time.Now().Add(-assessmentWindow()))
+	if roadmapID != nil {
+		query = query.Where("roadmap_id = ?", *roadmapID)
+	} else {
+		query = query.Where("roadmap_id IS NULL AND topic_key = ?", models.NormalizeTopicKey(topic))
+	}
+
+	var assessment models.Assessment
+	err := query.Order("started_at DESC").First(&assessment).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &assessment, nil
+}
+
+// findOwnedAssessment loads the assessment in the URL and checks it belongs to the user
+func (ac *AssessmentController) findOwnedAssessment(c *gin.Context, userID uint) (*models.Assessment, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assessment ID"})
+		return nil, false
+	}
+
+	var assessment models.Assessment
+	if err := ac.DB.Where("id = ? AND user_id = ?", id, userID).First(&assessment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assessment not found"})
+		return nil, false
+	}
+	return &assessment, true
+}
+
+// expireIfOverdue closes an in-progress assessment whose time limit has passed
+func (ac *AssessmentController) expireIfOverdue(assessment *models.Assessment) {
+	if assessment.Status != models.AssessmentStatusInProgress || !assessment.IsExpired(time.Now(), assessmentGracePeriod) {
+		return
+	}
+
+	// Only an assessment that is still running is expired, never one submitted in the meantime
+	result := ac.DB.Model(&models.Assessment{}).
+		Where("id = ? AND status = ?", assessment.ID, models.AssessmentStatusInProgress).
+		Updates(map[string]interface{}{
+			"status": models.AssessmentStatusExpired,
+			"score":  0,
+		})
+	if result.Error != nil {
+		fmt.Printf("Error expiring assessment %d: %v\n", assessment.ID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		if err := ac.DB.First(assessment, assessment.ID).Error; err != nil {
+			fmt.Printf("Error reloading assessment %d: %v\n", assessment.ID, err)
+		}
+		return
+	}
+	assessment.Status = models.AssessmentStatusExpired
+	assessment.Score = 0
+}
+
+// loadItems loads the exercise items of an assessment in their original order
+func (ac *AssessmentController) loadItems(ids []int64) ([]models.ExerciseItem, error) {
+	var items []models.ExerciseItem
+	if err := ac.DB.Unscoped().Where("id IN ?", []int64(ids)).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.ExerciseItem, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	ordered := make([]models.ExerciseItem, 0, len(ids))
+	for _, id := range ids {
+		if item, ok := byID[uint(id)]; ok {
+			ordered = append(ordered, item)
+		}
+	}
+	return ordered, nil
+}
+
+// respondWithQuestions sends a running assessment without its answer key
+func (ac *AssessmentController) respondWithQuestions(c *gin.Context, assessment *models.Assessment) {
+	items, err := ac.loadItems(assessment.ExerciseItemIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load assessment questions"})
+		return
+	}
+
+	questions := make([]AssessmentQuestion, 0, len(items))
+	for _, item := range items {
+		questions = append(questions, AssessmentQuestion{
+			ID:       item.ID,
+			Topic:    item.Topic,
+			Question: item.Question,
+			Options:  item.Options,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"assessment":       assessment,
+		"questions":        questions,
+		"remainingSeconds": max(0, int(time.Until(assessment.ExpiresAt).Seconds())),
+	})
+}
+
+// respondWithResults sends a finished assessment with the graded questions
+func (ac *AssessmentController) respondWithResults(c *gin.Context, assessment *models.Assessment) {
+	items, err := ac.loadItems(assessment.ExerciseItemIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load assessment questions"})
+		return
+	}
+
+	results := make([]AssessmentQuestionResult, 0, len(items))
+	for _, item := range items {
+		result := AssessmentQuestionResult{
+			AssessmentQuestion: AssessmentQuestion{
+				ID:       item.ID,
+				Topic:    item.Topic,
+				Question: item.Question,
+				Options:  item.Options,
+			},
+			CorrectAnswer: item.CorrectAnswer,
+			Explanation:   item.Explanation,
+		}
+		if answer, answered := assessment.Answers[strconv.FormatUint(uint64(item.ID), 10)]; answered {
+			result.SelectedAnswer = &answer
+			result.Correct = answer == item.CorrectAnswer
+		}
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"assessment": assessment,
+		"results":    results,
+		"passed":     assessment.Status == models.AssessmentStatusPassed,
+	})
+}
+
+// assessmentWindow returns the time a learner must wait between attempts on the same assessment
+func assessmentWindow() time.Duration {
+	hours := defaultAssessmentWindowHours
+	if value := os.Getenv("ASSESSMENT_WINDOW_HOURS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			hours = parsed
+		}
+	}
+	return time.Duration(hours) * time.Hour
+}
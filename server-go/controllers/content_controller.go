@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"mentorback/llm"
 	"mentorback/models"
 
 	"github.com/gin-gonic/gin"
@@ -182,7 +183,7 @@ Response must be strictly in JSON format:
 // parseRecommendedTopics parses the API response into recommended topics
 func (cc *ContentController) parseRecommendedTopics(content string) ([]models.RecommendedTopic, error) {
 	// Extract JSON from response
-	jsonStr := ExtractJSON(content)
+	jsonStr := llm.ExtractJSON(content)
 	if jsonStr == "" {
 		return nil, fmt.Errorf("invalid JSON format for recommended topics")
 	}
@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mentorback/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Export formats
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+)
+
+// exportFlushRows is how many rows are written between flushes of the response
+const exportFlushRows = 500
+
+// ExportController streams analytics data as CSV or NDJSON
+type ExportController struct {
+	BaseController
+}
+
+// NewExportController creates a new export controller
+func NewExportController(base BaseController) *ExportController {
+	return &ExportController{BaseController: base}
+}
+
+// ListExportDatasets lists the datasets that can be exported and their columns
+func (ec *ExportController) ListExportDatasets(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"datasets": models.ExportDatasets,
+		"formats":  []string{exportFormatCSV, exportFormatNDJSON},
+	})
+}
+
+// ExportMyData streams the authenticated user's rows of a dataset. Date ranges are days of the
+// user's timezone.
+func (ec *ExportController) ExportMyData(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	ec.export(c, &userData.ID, userData.Location())
+}
+
+// ExportAllData streams the rows of a dataset of every user, with user IDs pseudonymized. Date
+// ranges are UTC days.
+func (ec *ExportController) ExportAllData(c *gin.Context) {
+	if _, exists := c.Get("admin"); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin not authenticated"})
+		return
+	}
+
+	ec.export(c, nil, time.UTC)
+}
+
+// export streams the rows of the requested dataset, of one user or of every user if userID is nil
+func (ec *ExportController) export(c *gin.Context, userID *uint, location *time.Location) {
+	dataset, ok := models.FindExportDataset(c.Param("dataset"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dataset not found"})
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", exportFormatCSV))
+	if format != exportFormatCSV && format != exportFormatNDJSON {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be csv or ndjson"})
+		return
+	}
+
+	var names []string
+	if value := c.Query("columns"); value != "" {
+		names = strings.Split(value, ",")
+	}
+	columns, err := dataset.SelectColumns(names)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The range is given in days, the last one included
+	var from, to time.Time
+	if value := c.Query("from"); value != "" {
+		date, err := time.ParseInLocation("2006-01-02", value, location)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "From must be in YYYY-MM-DD format"})
+			return
+		}
+		from = date
+	}
+	if value := c.Query("to"); value != "" {
+		date, err := time.ParseInLocation("2006-01-02", value, location)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "To must be in YYYY-MM-DD format"})
+			return
+		}
+		to = date.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "To must not be before from"})
+		return
+	}
+
+	// Daily activity is stored as UTC midnights of the user's days
+	if dataset.DateColumn == "date" {
+		if !from.IsZero() {
+			from = models.ActivityDay(from, location)
+		}
+		if !to.IsZero() {
+			to = models.ActivityDay(to, location)
+		}
+	}
+
+	// Fail before the response starts if user IDs cannot be pseudonymized
+	pseudonymize := userID == nil
+	if pseudonymize {
+		if _, err := models.PseudonymizeUserID(0); err != nil {
+			fmt.Printf("Error exporting %s: %v\n", dataset.Name, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Exports across users are not configured"})
+			return
+		}
+	}
+
+	rows, err := dataset.Query(ec.DB, columns, userID, from, to).Rows()
+	if err != nil {
+		fmt.Printf("Error exporting %s: %v\n", dataset.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data"})
+		return
+	}
+	defer rows.Close()
+
+	filename := fmt.Sprintf("%s-%s.%s", dataset.Name, time.Now().UTC().Format("2006-01-02"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if format == exportFormatCSV {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+	}
+	c.Status(http.StatusOK)
+
+	if err := streamExportRows(c.Writer, format, rows, columns, pseudonymize); err != nil {
+		// The response has started, so the export can only be cut short
+		fmt.Printf("Error streaming %s export: %v\n", dataset.Name, err)
+	}
+}
+
+// streamExportRows writes rows as they are read, flushing the response every so often so that
+// exports of any size are never held in memory
+func streamExportRows(w gin.ResponseWriter, format string, rows *sql.Rows, columns []models.ExportColumn, pseudonymize bool) error {
+	var csvWriter *csv.Writer
+	if format == exportFormatCSV {
+		csvWriter = csv.NewWriter(w)
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = column.Name
+		}
+		if err := csvWriter.Write(header); err != nil {
+			return err
+		}
+	}
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	written := 0
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+
+		row := make([]interface{}, len(columns))
+		for i, column := range columns {
+			value, err := exportValue(values[i], column, pseudonymize)
+			if err != nil {
+				return err
+			}
+			row[i] = value
+		}
+
+		var err error
+		if csvWriter != nil {
+			err = writeCSVRow(csvWriter, row)
+		} else {
+			err = writeNDJSONRow(w, columns, row)
+		}
+		if err != nil {
+			return err
+		}
+
+		written++
+		if written%exportFlushRows == 0 {
+			if csvWriter != nil {
+				csvWriter.Flush()
+			}
+			w.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+// exportValue converts a scanned value to the value written: times as RFC 3339 or, for days, as
+// dates, and user IDs pseudonymized if asked to
+func exportValue(value interface{}, column models.ExportColumn, pseudonymize bool) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if column.UserColumn && pseudonymize {
+		id, err := strconv.ParseUint(fmt.Sprint(value), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return models.PseudonymizeUserID(uint(id))
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		if column.Date {
+			return v.UTC().Format("2006-01-02"), nil
+		}
+		return v.UTC().Format(time.RFC3339), nil
+	case []byte:
+		return string(v), nil
+	}
+	return value, nil
+}
+
+// writeCSVRow writes a row as CSV, with nulls as empty fields. Text that a spreadsheet would run as
+// a formula is prefixed with a quote, as exports contain text learners typed.
+func writeCSVRow(writer *csv.Writer, row []interface{}) error {
+	record := make([]string, len(row))
+	for i, value := range row {
+		switch v := value.(type) {
+		case nil:
+		case string:
+			record[i] = escapeCSVFormula(v)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return writer.Write(record)
+}
+
+// escapeCSVFormula prefixes text starting with a formula character with a quote
+func escapeCSVFormula(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// writeNDJSONRow writes a row as a JSON object on a line of its own, keeping the column order
+func writeNDJSONRow(w io.Writer, columns []models.ExportColumn, row []interface{}) error {
+	var line strings.Builder
+	line.WriteByte('{')
+	for i, column := range columns {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		key, err := json.Marshal(column.Name)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(row[i])
+		if err != nil {
+			return err
+		}
+		line.Write(key)
+		line.WriteByte(':')
+		line.Write(value)
+	}
+	line.WriteString("}\n")
+	_, err := io.WriteString(w, line.String())
+	return err
+}
@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"mentorback/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GoalController handles the user's learning goals
+type GoalController struct {
+	BaseController
+}
+
+// NewGoalController creates a new goal controller
+func NewGoalController(base BaseController) *GoalController {
+	return &GoalController{BaseController: base}
+}
+
+// maxGoalTarget bounds the exercises per week and hours of study a goal may ask for
+const maxGoalTarget = 10000
+
+// CreateGoalRequest represents the body for creating a goal
+type CreateGoalRequest struct {
+	Title     string  `json:"title"`
+	Type      string  `json:"type" binding:"required,oneof=roadmap weekly-exercises study-time"`
+	RoadmapID *uint   `json:"roadmapId"` // Required for roadmap goals
+	Target    float64 `json:"target"`    // Exercises per week or hours of study
+	StartDate string  `json:"startDate"` // YYYY-MM-DD, defaults to today
+	Deadline  string  `json:"deadline" binding:"required"`
+}
+
+// UpdateGoalRequest represents the body for changing a goal. The type and roadmap are fixed.
+type UpdateGoalRequest struct {
+	Title    *string  `json:"title"`
+	Target   *float64 `json:"target"`
+	Deadline *string  `json:"deadline"`
+}
+
+// ListGoals lists the user's goals with their current status
+func (gc *GoalController) ListGoals(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	var goals []models.Goal
+	if err := gc.DB.Where("user_id = ?", userData.ID).Order("deadline ASC").Find(&goals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve goals"})
+		return
+	}
+
+	now := time.Now()
+	for i := range goals {
+		if err := evaluateGoal(gc.DB, &goals[i], now); err != nil {
+			fmt.Printf("Error evaluating goal %d: %v\n", goals[i].ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"goals": goals})
+}
+
+// CreateGoal creates a goal and evaluates it right away
+func (gc *GoalController) CreateGoal(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	var request CreateGoalRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	goal := models.Goal{
+		UserID:    userData.ID,
+		Type:      request.Type,
+		StartDate: models.ActivityDay(now, userData.Location()),
+		Status:    models.GoalStatusOnTrack,
+	}
+
+	if request.StartDate != "" {
+		startDate, err := time.Parse("2006-01-02", request.StartDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Start date must be in YYYY-MM-DD format"})
+			return
+		}
+		goal.StartDate = startDate
+	}
+	deadline, err := parseGoalDeadline(request.Deadline, goal.StartDate, now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	goal.Deadline = deadline
+
+	switch request.Type {
+	case models.GoalTypeRoadmap:
+		if request.RoadmapID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Roadmap goals need a roadmapId"})
+			return
+		}
+		roadmap, err := findOwnedRoadmap(gc.DB, fmt.Sprint(*request.RoadmapID), userData.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
+			return
+		}
+		goal.RoadmapID = &roadmap.ID
+		goal.Title = "Finish the " + roadmap.Topic + " roadmap"
+	default:
+		if err := validateGoalTarget(request.Type, request.Target); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		goal.Target = request.Target
+		goal.Title = defaultGoalTitle(request.Type, request.Target)
+	}
+
+	if title := strings.TrimSpace(request.Title); title != "" {
+		goal.Title = title
+	}
+	if len([]rune(goal.Title)) > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Title must be at most 200 characters"})
+		return
+	}
+
+	if err := gc.DB.Create(&goal).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create goal"})
+		return
+	}
+	if err := evaluateGoal(gc.DB, &goal, now); err != nil {
+		fmt.Printf("Error evaluating goal %d: %v\n", goal.ID, err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"goal": goal})
+}
+
+// GetGoal returns one of the user's goals with its current status
+func (gc *GoalController) GetGoal(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	var goal models.Goal
+	if err := gc.DB.Where("id = ? AND user_id = ?", c.Param("id"), userData.ID).First(&goal).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		return
+	}
+
+	if err := evaluateGoal(gc.DB, &goal, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate goal"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"goal": goal})
+}
+
+// UpdateGoal changes the title, target or deadline of one of the user's goals
+func (gc *GoalController) UpdateGoal(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	var request UpdateGoalRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var goal models.Goal
+	if err := gc.DB.Where("id = ? AND user_id = ?", c.Param("id"), userData.ID).First(&goal).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		return
+	}
+
+	now := time.Now()
+	if request.Title != nil {
+		title := strings.TrimSpace(*request.Title)
+		if title == "" || len([]rune(title)) > 200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Title must be between 1 and 200 characters"})
+			return
+		}
+		goal.Title = title
+	}
+	if request.Target != nil {
+		if goal.Type == models.GoalTypeRoadmap {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Roadmap goals have no target"})
+			return
+		}
+		if err := validateGoalTarget(goal.Type, *request.Target); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		goal.Target = *request.Target
+	}
+	if request.Deadline != nil {
+		deadline, err := parseGoalDeadline(*request.Deadline, goal.StartDate, now)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		goal.Deadline = deadline
+	}
+
+	goal.Evaluate(measureGoal(gc.DB, goal, now), now)
+	if err := gc.DB.Save(&goal).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update goal"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"goal": goal})
+}
+
+// DeleteGoal deletes one of the user's goals
+func (gc *GoalController) DeleteGoal(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	result := gc.DB.Where("id = ? AND user_id = ?", c.Param("id"), userData.ID).Delete(&models.Goal{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete goal"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Goal deleted successfully"})
+}
+
+// evaluateGoal measures a goal against the user's learning activity and stores its status
+func evaluateGoal(db *gorm.DB, goal *models.Goal, now time.Time) error {
+	goal.Evaluate(measureGoal(db, *goal, now), now)
+	return db.Model(goal).UpdateColumns(map[string]interface{}{
+		"status":       goal.Status,
+		"current":      goal.Current,
+		"progress":     goal.Progress,
+		"evaluated_at": goal.EvaluatedAt,
+	}).Error
+}
+
+// measureGoal gathers the daily activity since the start of a goal and, for roadmap goals, the
+// roadmap's completion predicted against the deadline
+func measureGoal(db *gorm.DB, goal models.Goal, now time.Time) models.GoalMeasurement {
+	var m models.GoalMeasurement
+	db.Where("user_id = ? AND date >= ? AND date < ?", goal.UserID, goal.StartDate, goal.End()).
+		Order("date ASC").
+		Find(&m.Activity)
+
+	if goal.Type == models.GoalTypeRoadmap && goal.RoadmapID != nil {
+		steps, err := loadPlanSteps(db, *goal.RoadmapID)
+		if err != nil {
+			return m
+		}
+		completion := models.CalculateRoadmapCompletion(steps)
+		m.CompletedSteps, m.TotalSteps = completion.CompletedSteps, completion.TotalSteps
+
+		deadline := goal.Deadline
+		eta := predictRoadmapCompletion(db, *goal.RoadmapID, steps, learnerPace(db, goal.UserID, now), now, &deadline)
+		m.ETA = &eta
+	}
+	return m
+}
+
+// parseGoalDeadline parses a deadline that must fall on or after the start date and not be over
+func parseGoalDeadline(value string, start, now time.Time) (time.Time, error) {
+	deadline, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return deadline, fmt.Errorf("Deadline must be in YYYY-MM-DD format")
+	}
+	if deadline.Before(start) {
+		return deadline, fmt.Errorf("Deadline must not be before the start date")
+	}
+	if deadline.AddDate(0, 0, 1).Before(now) {
+		return deadline, fmt.Errorf("Deadline must not be in the past")
+	}
+	return deadline, nil
+}
+
+// validateGoalTarget checks the target of an exercise or study-time goal
+func validateGoalTarget(goalType string, target float64) error {
+	if target <= 0 || target > maxGoalTarget {
+		return fmt.Errorf("Target must be between 0 and %d", maxGoalTarget)
+	}
+	if goalType == models.GoalTypeWeeklyExercises && target != float64(int(target)) {
+		return fmt.Errorf("Target must be a whole number of exercises")
+	}
+	return nil
+}
+
+// defaultGoalTitle describes an exercise or study-time goal
+func defaultGoalTitle(goalType string, target float64) string {
+	if goalType == models.GoalTypeWeeklyExercises {
+		return fmt.Sprintf("Complete %d exercises a week", int(target))
+	}
+	return fmt.Sprintf("Study %g hours", target)
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"mentorback/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Pagination of the insight report history
+const (
+	defaultInsightPageSize = 12
+	maxInsightPageSize     = 52
+)
+
+// InsightController serves the weekly insight reports of learners
+type InsightController struct {
+	BaseController
+}
+
+// NewInsightController creates a new insight controller
+func NewInsightController(base BaseController) *InsightController {
+	return &InsightController{BaseController: base}
+}
+
+// InsightSettingsRequest represents a change to the weekly insight settings
+type InsightSettingsRequest struct {
+	OptOut *bool `json:"optOut" binding:"required"`
+}
+
+// ListInsightReports returns the user's weekly reports, the latest first
+func (ic *InsightController) ListInsightReports(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	query := ic.DB.Model(&models.InsightReport{}).Where("user_id = ?", userData.ID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve insight reports"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultInsightPageSize)))
+	if limit <= 0 || limit > maxInsightPageSize {
+		limit = defaultInsightPageSize
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	var reports []models.InsightReport
+	if err := query.Order("week_start DESC").Limit(limit).Offset(offset).Find(&reports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve insight reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reports": reports,
+		"optOut":  userData.InsightsOptOut,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// GetInsightReport returns one of the user's weekly reports
+func (ic *InsightController) GetInsightReport(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	reportID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	var report models.InsightReport
+	if err := ic.DB.Where("id = ? AND user_id = ?", reportID, userData.ID).First(&report).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Insight report not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+// UpdateInsightSettings opts the user out of weekly reports or back in. Past reports are kept.
+func (ic *InsightController) UpdateInsightSettings(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	var request InsightSettingsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ic.DB.Model(&models.User{}).Where("id = ?", userData.ID).
+		UpdateColumn("insights_opt_out", *request.OptOut).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update insight settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "optOut": *request.OptOut})
+}
@@ -8,6 +8,8 @@ import (
 	"strings"
 	"unicode"
 
+	"mentorback/models"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -92,6 +94,9 @@ func (lc *LectureController) GenerateLecture(c *gin.Context) {
 		fmt.Printf("Error generating lecture: %v. Using emergency content.\n", err)
 		// Even when there's an error, generate emergency content instead of returning an error response
 		lecture = createEmergencyLecture(request.Topic, request.Difficulty, modular)
+	} else {
+		// The keywords of a generated lecture are the concepts learners are traced on
+		lc.recordLectureConcepts(request.Topic, lecture.Keywords)
 	}
 
 	// Add topic to the lecture for client-side display
@@ -265,6 +270,26 @@ func (lc *LectureController) GenerateLecture(c *gin.Context) {
 	})
 }
 
+// recordLectureConcepts adds the keywords of a lecture to the concepts of its topic
+func (lc *LectureController) recordLectureConcepts(topicName string, keywords []string) {
+	topic, err := models.ResolveTopic(lc.DB, topicName)
+	if err != nil {
+		fmt.Printf("Error resolving lecture topic '%s': %v\n", topicName, err)
+		return
+	}
+
+	// The topic itself is not one of its concepts
+	var concepts []string
+	for _, keyword := range keywords {
+		if models.TopicSlug(keyword) != topic.Slug {
+			concepts = append(concepts, keyword)
+		}
+	}
+	if _, err := models.RecordTopicConcepts(lc.DB, topic.ID, concepts, "lecture"); err != nil {
+		fmt.Printf("Error recording lecture concepts for '%s': %v\n", topic.Name, err)
+	}
+}
+
 // min returns the smaller of a or b
 func min(a, b int) int {
 	if a < b {
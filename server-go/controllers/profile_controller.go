@@ -24,6 +24,7 @@ func NewProfileController(db *gorm.DB) *ProfileController {
 // UpdateProfileRequest represents the update profile request
 type UpdateProfileRequest struct {
 	DisplayName string `json:"displayName"`
+	Timezone    string `json:"timezone"` // IANA name, e.g. "Europe/Moscow"
 }
 
 // ProfileResponse represents the profile update response
@@ -55,8 +56,29 @@ func (pc *ProfileController) UpdateProfile(c *gin.Context) {
 		userData.DisplayName = request.DisplayName
 	}
 
-	// Save the updated user to database
-	if err := pc.DB.Save(&userData).Error; err != nil {
+	// Update the timezone daily activity and streaks are counted in if provided
+	timezoneChanged := false
+	if request.Timezone != "" {
+		if _, err := models.LoadTimezone(request.Timezone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unknown timezone: " + request.Timezone})
+			return
+		}
+		timezoneChanged = request.Timezone != userData.Timezone
+		userData.Timezone = request.Timezone
+	}
+
+	// Save the updated user to database. Whether the current streak still runs depends on what
+	// day it is in the user's timezone, so a new timezone recomputes the streaks.
+	err := pc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&userData).Error; err != nil {
+			return err
+		}
+		if timezoneChanged {
+			return recomputeStreaks(tx, userData)
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to update profile: " + err.Error()})
 		return
 	}
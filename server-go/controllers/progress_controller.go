@@ -7,6 +7,7 @@ import (
 	"mentorback/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // ProgressController handles progress-related API requests
@@ -19,11 +20,11 @@ func NewProgressController(base BaseController) *ProgressController {
 	return &ProgressController{BaseController: base}
 }
 
-// UpdateTopicProgress request structure
+// UpdateTopicProgress request structure. Completion is not part of it: a topic is completed once
+// the learner masters its concepts.
 type UpdateProgressRequest struct {
 	Topic     string `json:"topic" binding:"required"`
 	Viewed    bool   `json:"viewed"`
-	Completed bool   `json:"completed"`
 	QuizScore int    `json:"quizScore"`
 	CodeScore int    `json:"codeScore"`
 }
@@ -38,17 +39,11 @@ func (pc *ProgressController) GetUserProgress(c *gin.Context) {
 	}
 	userData := user.(models.User)
 
-	// Find or create user progress
-	var userProgress models.UserProgress
-	result := pc.DB.Where("user_id = ?", userData.ID).First(&userProgress)
-
-	// If no progress record exists, return empty progress
-	if result.Error != nil {
-		userProgress = models.UserProgress{
-			UserID:        userData.ID,
-			TopicProgress: make(models.TopicProgressMap),
-			LastActivity:  time.Now(),
-		}
+	// Load the progress in every topic the user has started
+	userProgress, err := models.LoadUserProgress(pc.DB, userData.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve progress"})
+		return
 	}
 
 	// Get user analytics for additional metrics
@@ -98,6 +93,64 @@ func (pc *ProgressController) GetUserProgress(c *gin.Context) {
 		LIMIT 1
 	`, userData.ID).Scan(&lastCompletedTopic)
 
+	// Get the completion of each roadmap
+	var roadmaps []struct {
+		ID             uint              `json:"id"`
+		Topic          string            `json:"topic"`
+		CompletedSteps int               `json:"completedSteps"`
+		TotalSteps     int               `json:"totalSteps"`
+		Percent        float64           `json:"percent"`
+		ETA            models.RoadmapETA `gorm:"-" json:"eta"`
+	}
+
+	pc.DB.Raw(`
+		SELECT
+			roadmaps.id as id,
+			roadmaps.topic as topic,
+			COUNT(CASE WHEN roadmap_steps.completed THEN 1 END) as completed_steps,
+			COUNT(roadmap_steps.id) as total_steps,
+			COALESCE(100.0 * COUNT(CASE WHEN roadmap_steps.completed THEN 1 END) / NULLIF(COUNT(roadmap_steps.id), 0), 0) as percent
+		FROM roadmaps
+		LEFT JOIN roadmap_steps ON roadmap_steps.roadmap_id = roadmaps.id AND roadmap_steps.deleted_at IS NULL
+		WHERE roadmaps.user_id = ? AND roadmaps.deleted_at IS NULL
+		GROUP BY roadmaps.id, roadmaps.topic
+		ORDER BY roadmaps.created_at DESC
+	`, userData.ID).Scan(&roadmaps)
+
+	// Predict when each roadmap will be completed at the learner's pace
+	if len(roadmaps) > 0 {
+		now := time.Now()
+		pace := learnerPace(pc.DB, userData.ID, now)
+
+		var steps []models.RoadmapStep
+		userRoadmaps := pc.DB.Model(&models.Roadmap{}).Select("id").Where("user_id = ?", userData.ID)
+		pc.DB.Where("roadmap_id IN (?)", userRoadmaps).Find(&steps)
+		stepsByRoadmap := make(map[uint][]models.RoadmapStep)
+		for _, step := range steps {
+			stepsByRoadmap[step.RoadmapID] = append(stepsByRoadmap[step.RoadmapID], step)
+		}
+
+		for i := range roadmaps {
+			roadmaps[i].ETA = predictRoadmapCompletion(pc.DB, roadmaps[i].ID, stepsByRoadmap[roadmaps[i].ID], pace, now, nil)
+		}
+	}
+
+	// Get the learner's ability estimates per topic
+	var abilities []models.TopicAbility
+	pc.DB.Where("user_id = ?", userData.ID).Order("last_attempt_at DESC").Find(&abilities)
+
+	// Get the learner's concept mastery in every topic they practiced
+	mastery, err := models.LoadUserMastery(pc.DB, userData.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve mastery"})
+		return
+	}
+
+	// Bring the streak up to date, as it runs out on days without activity
+	if current, longest, err := models.RecomputeStreaks(pc.DB, userData.ID, userData.Location(), time.Now()); err == nil {
+		analytics.StreakDays, analytics.LongestStreak = current, longest
+	}
+
 	// Create the response
 	responseData := gin.H{
 		"progress": userProgress,
@@ -112,8 +165,12 @@ func (pc *ProgressController) GetUserProgress(c *gin.Context) {
 			"averageQuizScore":  analytics.AverageQuizScore,
 			"averageCodeScore":  analytics.AverageCodeScore,
 			"streakDays":        analytics.StreakDays,
+			"longestStreak":     analytics.LongestStreak,
 			"totalLearningTime": analytics.TotalLearningTime,
 		},
+		"abilities": abilities,
+		"mastery":   mastery,
+		"roadmaps":  roadmaps,
 	}
 
 	// Add lastCompletedTopic only if it exists
@@ -143,76 +200,111 @@ func (pc *ProgressController) UpdateTopicProgress(c *gin.Context) {
 		return
 	}
 
-	// Get or create user progress record
-	var userProgress models.UserProgress
-	result := pc.DB.Where("user_id = ?", userData.ID).First(&userProgress)
-
-	if result.Error != nil {
-		// Create new progress record if not found
-		userProgress = models.UserProgress{
-			UserID:        userData.ID,
-			TopicProgress: make(models.TopicProgressMap),
-		}
-	}
-
-	// Update topic progress
+	// Record the update in the topic's event log. Whether the topic is completed is decided by the
+	// learner's concept mastery when exercises are graded, not by the client.
 	now := time.Now()
-	if userProgress.TopicProgress == nil {
-		userProgress.TopicProgress = make(models.TopicProgressMap)
+	update := models.UserTopicProgress{
+		UserID:    userData.ID,
+		Topic:     request.Topic,
+		Viewed:    request.Viewed,
+		QuizScore: request.QuizScore,
+		CodeScore: request.CodeScore,
+	}
+	if request.Viewed {
+		update.LastViewed = &now
 	}
 
-	// Get existing status or create new one
-	status, exists := userProgress.TopicProgress[request.Topic]
-	if !exists {
-		status = models.TopicStatus{
-			LastViewed: now,
+	var mastery models.TopicMastery
+	err := pc.DB.Transaction(func(tx *gorm.DB) error {
+		_, topic, err := recordTopicProgress(tx, update, "progress")
+		if err != nil {
+			return err
 		}
+		mastery, err = models.GetTopicMastery(tx, userData.ID, topic)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update progress record"})
+		return
 	}
 
-	// Update status fields
-	if request.Viewed {
-		status.Viewed = true
-		status.LastViewed = now
+	userProgress, err := models.LoadUserProgress(pc.DB, userData.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve progress"})
+		return
 	}
 
-	if request.Completed && !status.Completed {
-		status.Completed = true
-		status.CompletedAt = now
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Progress updated successfully",
+		"progress": userProgress,
+		"mastery":  mastery,
+	})
+}
+
+// GetTopicProgress gets the progress for a specific topic
+func (pc *ProgressController) GetTopicProgress(c *gin.Context) {
+	// Get user from context
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
 	}
+	userData := user.(models.User)
 
-	if request.QuizScore > status.QuizScore {
-		status.QuizScore = request.QuizScore
+	// Get topic from URL parameter
+	topic := c.Param("topic")
+	if topic == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Topic parameter is required"})
+		return
 	}
 
-	if request.CodeScore > status.CodeScore {
-		status.CodeScore = request.CodeScore
+	// Get the learner's ability estimate for the topic
+	ability, err := models.GetTopicAbility(pc.DB, userData.ID, topic)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve topic ability"})
+		return
 	}
 
-	// Save updated status
-	userProgress.TopicProgress[request.Topic] = status
-	userProgress.LastActivity = now
+	// Find the topic's progress under its catalog topic
+	key, err := topicProgressKey(pc.DB, topic)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve topic"})
+		return
+	}
+	var rows []models.UserTopicProgress
+	pc.DB.Where("user_id = ? AND topic_key = ?", userData.ID, key).Limit(1).Find(&rows)
 
-	// Save to database
-	if result.Error != nil {
-		if err := pc.DB.Create(&userProgress).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create progress record"})
-			return
-		}
-	} else {
-		if err := pc.DB.Save(&userProgress).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update progress record"})
+	// Get the learner's mastery of the topic's concepts
+	mastery := models.TopicMastery{Topic: key, Concepts: []models.ConceptState{}}
+	if found, ok, err := models.FindTopic(pc.DB, topic); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve topic"})
+		return
+	} else if ok {
+		if mastery, err = models.GetTopicMastery(pc.DB, userData.ID, found); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve mastery"})
 			return
 		}
 	}
 
+	// If the topic was not started yet, return empty status
+	status := models.TopicStatus{
+		Viewed:    false,
+		Completed: false,
+	}
+	if len(rows) > 0 {
+		status = rows[0].Status()
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message":  "Progress updated successfully",
-		"progress": userProgress,
+		"topic":   topic,
+		"status":  status,
+		"ability": ability,
+		"mastery": mastery,
 	})
 }
 
-// GetTopicProgress gets the progress for a specific topic
-func (pc *ProgressController) GetTopicProgress(c *gin.Context) {
+// GetTopicTimeline lists the events of the user's progress in a topic, newest first
+func (pc *ProgressController) GetTopicTimeline(c *gin.Context) {
 	// Get user from context
 	user, exists := c.Get("user")
 	if !exists {
@@ -221,45 +313,110 @@ func (pc *ProgressController) GetTopicProgress(c *gin.Context) {
 	}
 	userData := user.(models.User)
 
-	// Get topic from URL parameter
-	topic := c.Param("topic")
-	if topic == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Topic parameter is required"})
+	topic, ok, err := models.FindTopic(pc.DB, c.Param("topic"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve topic"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Topic not found"})
 		return
 	}
 
-	// Find user progress
-	var userProgress models.UserProgress
-	result := pc.DB.Where("user_id = ?", userData.ID).First(&userProgress)
-
-	// If no progress record exists, or topic not found, return empty status
-	if result.Error != nil || userProgress.TopicProgress == nil {
-		c.JSON(http.StatusOK, gin.H{
-			"topic": topic,
-			"status": models.TopicStatus{
-				Viewed:    false,
-				Completed: false,
-			},
-		})
+	events, err := models.LoadProgressEvents(pc.DB, userData.ID, topic.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve progress history"})
 		return
 	}
+	timeline := make([]models.ProgressEvent, 0, len(events))
+	for i := len(events) - 1; i >= 0; i-- {
+		timeline = append(timeline, events[i])
+	}
+
+	var progress models.UserTopicProgress
+	progress.Derive(events)
 
-	// Get topic status
-	status, exists := userProgress.TopicProgress[topic]
+	c.JSON(http.StatusOK, gin.H{
+		"topic":    topic,
+		"status":   progress.Status(),
+		"timeline": timeline,
+	})
+}
+
+// UndoProgressEvent undoes an accidental completion of a topic, reopening the roadmap steps it
+// completed. Only the completion that is currently in effect can be undone, and topics mastered in
+// an assessment stay completed.
+func (pc *ProgressController) UndoProgressEvent(c *gin.Context) {
+	// Get user from context
+	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusOK, gin.H{
-			"topic": topic,
-			"status": models.TopicStatus{
-				Viewed:    false,
-				Completed: false,
-			},
-		})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	var event models.ProgressEvent
+	if err := pc.DB.Where("id = ? AND user_id = ?", c.Param("id"), userData.ID).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Progress event not found"})
+		return
+	}
+	if event.Type != models.ProgressEventCompleted {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only completions can be undone"})
+		return
+	}
+
+	var topic models.Topic
+	if err := pc.DB.First(&topic, event.TopicID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Topic not found"})
+		return
+	}
+
+	var row models.UserTopicProgress
+	var conflict string
+	err := pc.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		row, err = models.LockTopicProgress(tx, userData.ID, topic)
+		if err != nil {
+			return err
+		}
+		if row.Mastered {
+			conflict = "A topic mastered in an assessment cannot be marked as not completed"
+			return nil
+		}
+
+		// The latest completion or uncompletion is the one in effect
+		var latest models.ProgressEvent
+		if err := tx.Where("user_id = ? AND topic_id = ? AND type IN ?", userData.ID, topic.ID,
+			[]string{models.ProgressEventCompleted, models.ProgressEventUncompleted}).
+			Order("occurred_at DESC, id DESC").
+			First(&latest).Error; err != nil {
+			return err
+		}
+		if latest.ID != event.ID {
+			conflict = "Only the current completion of a topic can be undone"
+			return nil
+		}
+
+		undo := models.NewProgressEvent(models.ProgressEventUncompleted, "undo", time.Now())
+		undo.UndoesEventID = &event.ID
+		if err := models.AppendProgressEvents(tx, &row, undo); err != nil {
+			return err
+		}
+		return uncompleteStepsForTopic(tx, userData.ID, topic.Name, &event.OccurredAt)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to undo completion"})
+		return
+	}
+	if conflict != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": conflict})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"topic":  topic,
-		"status": status,
+		"message": "Completion undone",
+		"topic":   row.Topic,
+		"status":  row.Status(),
 	})
 }
 
@@ -270,3 +427,281 @@ func calculateCompletionRate(progress models.UserProgress) float64 {
 	}
 	return float64(progress.CompletedTopics) / float64(progress.TotalTopics) * 100
 }
+
+// markTopicMastered marks a topic as mastered and completed in the user's progress
+func markTopicMastered(tx *gorm.DB, userID uint, topic string, at time.Time) error {
+	_, _, err := recordTopicProgress(tx, models.UserTopicProgress{
+		UserID:      userID,
+		Topic:       topic,
+		Completed:   true,
+		CompletedAt: &at,
+		Mastered:    true,
+		MasteredAt:  &at,
+	}, "assessment")
+	return err
+}
+
+// setTopicCompleted marks a topic as completed or not completed in the user's progress. source
+// names what decided it, e.g. "mastery" or "roadmap". A topic that is no longer completed also
+// reopens the steps of the user's roadmaps that cover it.
+func setTopicCompleted(tx *gorm.DB, userID uint, topic string, completed bool, at time.Time, source string) error {
+	if completed {
+		_, _, err := recordTopicProgress(tx, models.UserTopicProgress{
+			UserID:      userID,
+			Topic:       topic,
+			Completed:   true,
+			CompletedAt: &at,
+		}, source)
+		return err
+	}
+
+	found, ok, err := models.FindTopic(tx, topic)
+	if err != nil || !ok {
+		return err
+	}
+	row, err := models.LockTopicProgress(tx, userID, found)
+	if err != nil || !row.Completed {
+		return err
+	}
+	if err := models.AppendProgressEvents(tx, &row, models.NewProgressEvent(models.ProgressEventUncompleted, source, at)); err != nil {
+		return err
+	}
+	return uncompleteStepsForTopic(tx, userID, found.Name, nil)
+}
+
+// updateConceptMastery applies a graded attempt to the learner's mastery of the concepts it practices,
+// or of the topic as a whole when it names none, and completes the topic once it is mastered.
+// score is the attempt result in the range 0-1.
+func updateConceptMastery(tx *gorm.DB, userID uint, topicName string, concepts []string, exerciseType string, score float64, at time.Time) (models.TopicMastery, error) {
+	topic, err := models.ResolveTopic(tx, topicName)
+	if err != nil {
+		return models.TopicMastery{}, err
+	}
+	if len(concepts) == 0 {
+		concepts = []string{topic.Name}
+	}
+
+	slugs, err := models.RecordTopicConcepts(tx, topic.ID, concepts, "exercise")
+	if err != nil {
+		return models.TopicMastery{}, err
+	}
+	before, err := models.GetTopicMastery(tx, userID, topic)
+	if err != nil {
+		return models.TopicMastery{}, err
+	}
+	guess := models.ExerciseGuessRate(exerciseType)
+	for _, slug := range slugs {
+		if _, err := models.UpdateConceptMastery(tx, userID, topic.ID, slug, score, guess); err != nil {
+			return models.TopicMastery{}, err
+		}
+	}
+
+	return completeMasteredTopic(tx, userID, topic, before.Mastered, at)
+}
+
+// currentTopicMastery returns the learner's mastery of a topic without changing it, or an empty
+// mastery for topics that are not in the catalog
+func currentTopicMastery(tx *gorm.DB, userID uint, topicName string) (models.TopicMastery, error) {
+	topic, ok, err := models.FindTopic(tx, topicName)
+	if err != nil || !ok {
+		return models.TopicMastery{Topic: models.TopicSlug(topicName), Concepts: []models.ConceptState{}}, err
+	}
+	return models.GetTopicMastery(tx, userID, topic)
+}
+
+// completeMasteredTopic evaluates the learner's mastery of a topic and, when an attempt makes every
+// assessed concept mastered, marks the topic and the roadmap steps that cover it as completed.
+// Only that transition completes the topic, so a completion the learner undid stays undone until
+// they master the topic again.
+func completeMasteredTopic(tx *gorm.DB, userID uint, topic models.Topic, wasMastered bool, at time.Time) (models.TopicMastery, error) {
+	mastery, err := models.GetTopicMastery(tx, userID, topic)
+	if err != nil || !mastery.Mastered || wasMastered {
+		return mastery, err
+	}
+
+	if err := setTopicCompleted(tx, userID, topic.Name, true, at, "mastery"); err != nil {
+		return mastery, err
+	}
+	return mastery, completeStepsForTopic(tx, userID, topic.Name, at)
+}
+
+// recordTopicProgress records progress in a topic of the catalog as events and returns the progress
+// derived from them, along with the catalog topic it resolved. Every view and score is recorded,
+// while completion and mastery are only recorded when they change. The progress row stays locked
+// until the transaction ends, so concurrent updates append their events one after another.
+func recordTopicProgress(tx *gorm.DB, progress models.UserTopicProgress, source string) (models.UserTopicProgress, models.Topic, error) {
+	topic, err := models.ResolveTopic(tx, progress.Topic)
+	if err != nil {
+		return progress, topic, err
+	}
+	row, err := models.LockTopicProgress(tx, progress.UserID, topic)
+	if err != nil {
+		return row, topic, err
+	}
+
+	now := time.Now()
+	at := func(t *time.Time) time.Time {
+		if t != nil {
+			return *t
+		}
+		return now
+	}
+
+	var events []models.ProgressEvent
+	if progress.Viewed {
+		events = append(events, models.NewProgressEvent(models.ProgressEventViewed, source, at(progress.LastViewed)))
+	}
+	if progress.QuizScore > 0 {
+		events = append(events, models.NewScoreEvent(models.ProgressEventQuizScore, source, progress.QuizScore, now))
+	}
+	if progress.CodeScore > 0 {
+		events = append(events, models.NewScoreEvent(models.ProgressEventCodeScore, source, progress.CodeScore, now))
+	}
+	if progress.Completed && !row.Completed {
+		events = append(events, models.NewProgressEvent(models.ProgressEventCompleted, source, at(progress.CompletedAt)))
+	}
+	if progress.Mastered && !row.Mastered {
+		events = append(events, models.NewProgressEvent(models.ProgressEventMastered, source, at(progress.MasteredAt)))
+	}
+	if len(events) == 0 {
+		return row, topic, nil
+	}
+
+	return row, topic, models.AppendProgressEvents(tx, &row, events...)
+}
+
+// topicProgressKey returns the key a topic's progress is stored under: the slug of its catalog
+// topic, or of the name itself for topics that are not in the catalog yet
+func topicProgressKey(db *gorm.DB, topic string) (string, error) {
+	found, ok, err := models.FindTopic(db, topic)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return found.Slug, nil
+	}
+	return models.TopicSlug(topic), nil
+}
+
+// completeStepsForTopic marks the steps of the user's roadmaps that cover a topic as completed,
+// matching step names against the topic catalog
+func completeStepsForTopic(tx *gorm.DB, userID uint, topic string, at time.Time) error {
+	roadmaps := tx.Model(&models.Roadmap{}).Select("id").Where("user_id = ?", userID)
+	var steps []models.RoadmapStep
+	if err := tx.Where("roadmap_id IN (?) AND completed = ?", roadmaps, false).Find(&steps).Error; err != nil {
+		return err
+	}
+	ids, err := topicStepIDs(tx, steps, topic)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	return tx.Model(&models.RoadmapStep{}).
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{
+			"completed":    true,
+			"completed_at": at,
+		}).Error
+}
+
+// uncompleteStepsForTopic reopens the steps of the user's roadmaps that cover a topic, or only
+// those completed along with it at completedAt if that is given. Steps mastered in an assessment
+// stay completed.
+func uncompleteStepsForTopic(tx *gorm.DB, userID uint, topic string, completedAt *time.Time) error {
+	roadmaps := tx.Model(&models.Roadmap{}).Select("id").Where("user_id = ?", userID)
+	query := tx.Where("roadmap_id IN (?) AND completed AND NOT mastered", roadmaps)
+	if completedAt != nil {
+		query = query.Where("completed_at = ?", *completedAt)
+	}
+	var steps []models.RoadmapStep
+	if err := query.Find(&steps).Error; err != nil {
+		return err
+	}
+	ids, err := topicStepIDs(tx, steps, topic)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	return tx.Model(&models.RoadmapStep{}).
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{
+			"completed":    false,
+			"completed_at": nil,
+		}).Error
+}
+
+// topicStepIDs returns the IDs of the steps whose names refer to a topic, matching them against
+// the topic catalog
+func topicStepIDs(tx *gorm.DB, steps []models.RoadmapStep, topic string) ([]uint, error) {
+	if len(steps) == 0 {
+		return nil, nil
+	}
+	catalog, err := models.LoadTopicCatalog(tx)
+	if err != nil {
+		return nil, err
+	}
+	target, known := catalog.Match(topic)
+	slug := models.TopicSlug(topic)
+
+	var ids []uint
+	for _, step := range steps {
+		match, ok := catalog.Match(step.Name)
+		if (known && ok && match.ID == target.ID) || models.TopicSlug(step.Name) == slug {
+			ids = append(ids, step.ID)
+		}
+	}
+	return ids, nil
+}
+
+// learnerPace estimates the user's pace from recent daily activity and completed roadmap steps
+func learnerPace(db *gorm.DB, userID uint, now time.Time) models.LearningPace {
+	var activity []models.DailyActivity
+	db.Where("user_id = ? AND date >= ?", userID, now.AddDate(0, 0, -7*models.PaceWindowWeeks)).Find(&activity)
+
+	var steps []models.RoadmapStep
+	roadmaps := db.Model(&models.Roadmap{}).Select("id").Where("user_id = ?", userID)
+	db.Where("roadmap_id IN (?) AND completed = ?", roadmaps, true).Find(&steps)
+
+	// Steps completed before completion times were recorded fall back to their topic's completion
+	var topics []models.UserTopicProgress
+	db.Where("user_id = ? AND completed_at IS NOT NULL", userID).Find(&topics)
+	topicCompletedAt := make(map[string]time.Time, len(topics))
+	for _, topic := range topics {
+		topicCompletedAt[topic.TopicKey] = *topic.CompletedAt
+	}
+	catalog, err := models.LoadTopicCatalog(db)
+	if err != nil {
+		catalog = &models.TopicCatalog{}
+	}
+
+	completions := make([]models.StepCompletion, 0, len(steps))
+	for _, step := range steps {
+		var at time.Time
+		if step.CompletedAt != nil {
+			at = *step.CompletedAt
+		} else if topic, ok := catalog.Match(step.Name); ok {
+			at = topicCompletedAt[topic.Slug]
+		}
+		if !at.IsZero() {
+			completions = append(completions, models.StepCompletion{Hours: models.StepHours(step), At: at})
+		}
+	}
+
+	return models.EstimateLearningPace(activity, completions, now)
+}
+
+// predictRoadmapCompletion predicts when a roadmap is completed. The roadmap's study plan provides
+// the target date unless one is given and, for learners without recent activity, the pace.
+func predictRoadmapCompletion(db *gorm.DB, roadmapID uint, steps []models.RoadmapStep, pace models.LearningPace, now time.Time, target *time.Time) models.RoadmapETA {
+	var plans []models.StudyPlan
+	if db.Where("roadmap_id = ?", roadmapID).Limit(1).Find(&plans); len(plans) > 0 {
+		if target == nil {
+			target = plans[0].TargetDate
+		}
+		if pace.Basis == "none" {
+			pace.WeeklyHours = plans[0].Availability.WeeklyHours()
+			pace.Basis = "plan"
+		}
+	}
+	return models.PredictRoadmapCompletion(steps, pace, now, target)
+}
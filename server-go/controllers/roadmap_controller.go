@@ -1,14 +1,23 @@
 package controllers
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"mentorback/llm"
 	"mentorback/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
 )
 
 // RoadmapController handles roadmap generation requests
@@ -26,6 +35,62 @@ type RoadmapRequest struct {
 	Topic string `json:"topic" binding:"required"`
 }
 
+// UpdateRoadmapRequest represents the body for renaming a roadmap
+type UpdateRoadmapRequest struct {
+	Topic string `json:"topic" binding:"required,max=100"`
+}
+
+// RoadmapStepRequest represents the body for adding or editing a roadmap step.
+// Fields left out of an edit are not changed.
+type RoadmapStepRequest struct {
+	Name           *string               `json:"name" binding:"omitempty,max=200"`
+	Description    *string               `json:"description"`
+	EstimatedHours *float64              `json:"estimatedHours"`
+	Objectives     []string              `json:"objectives"`
+	Resources      []models.StepResource `json:"resources"`
+	Prerequisites  *[]uint               `json:"prerequisites"` // IDs of steps in the same roadmap
+	Position       int                   `json:"position"`      // 1-based position for a new step, appended if 0
+}
+
+// ReorderStepsRequest represents the new order of all steps in a roadmap
+type ReorderStepsRequest struct {
+	StepIDs []uint `json:"stepIds" binding:"required"`
+}
+
+// RoadmapSummary represents a saved roadmap in the roadmap list
+type RoadmapSummary struct {
+	ID        uint                     `json:"id"`
+	Topic     string                   `json:"topic"`
+	CreatedAt time.Time                `json:"createdAt"`
+	UpdatedAt time.Time                `json:"updatedAt"`
+	Progress  models.RoadmapCompletion `json:"progress"`
+}
+
+// GeneratedRoadmapStep represents a roadmap step as returned by the language model
+type GeneratedRoadmapStep struct {
+	ID             string                `json:"id"`
+	Name           string                `json:"name"`
+	Description    string                `json:"description"`
+	EstimatedHours float64               `json:"estimatedHours"`
+	Objectives     []string              `json:"objectives"`
+	Resources      []models.StepResource `json:"resources"`
+	Prerequisites  []string              `json:"prerequisites"`
+}
+
+// GeneratedRoadmap represents the structured roadmap returned by the language model
+type GeneratedRoadmap struct {
+	Steps []GeneratedRoadmapStep `json:"steps"`
+}
+
+const (
+	// maxRoadmapGenerationAttempts is how often an unusable roadmap is regenerated before giving up
+	maxRoadmapGenerationAttempts = 2
+	// maxStepEstimatedHours caps implausible time estimates from the language model
+	maxStepEstimatedHours = 200.0
+	// maxRoadmapDocumentBytes limits the size of imported roadmap documents
+	maxRoadmapDocumentBytes = 1 << 20
+)
+
 // GenerateRoadmap generates a roadmap for a topic
 func (rc *RoadmapController) GenerateRoadmap(c *gin.Context) {
 	var request RoadmapRequest
@@ -34,142 +99,1340 @@ func (rc *RoadmapController) GenerateRoadmap(c *gin.Context) {
 		return
 	}
 
-	// Track if we should generate a new roadmap
-	shouldGenerateNew := true
-	var roadmapSteps []string
-
 	// Get user from context (if it exists)
-	var userData models.User
 	var userID uint
 
+	// Roadmaps about the same catalog topic are the same roadmap, however the topic is spelled
+	sameTopic := func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ? AND topic = ?", userID, request.Topic)
+	}
+
 	// Check if we already have a saved roadmap for this topic and user
 	user, exists := c.Get("user")
 	if exists {
-		userData = user.(models.User)
+		userData := user.(models.User)
 		userID = userData.ID
+		if topic, err := models.ResolveTopic(rc.DB, request.Topic); err == nil {
+			sameTopic = func(db *gorm.DB) *gorm.DB {
+				return db.Where("user_id = ? AND topic_id = ?", userID, topic.ID)
+			}
+		}
 
 		// Check for existing roadmap
 		var existingRoadmap models.Roadmap
-		var steps []models.RoadmapStep
-
-		result := rc.DB.Where("user_id = ? AND topic = ?", userID, request.Topic).
+		result := sameTopic(rc.DB).
 			Order("created_at DESC").
 			First(&existingRoadmap)
 
 		if result.Error == nil {
-			// If roadmap exists and was created less than 7 days ago, use it
-			sevenDaysAgo := time.Now().Add(-7 * 24 * time.Hour)
-			if existingRoadmap.CreatedAt.After(sevenDaysAgo) {
-				fmt.Println("INFO: Using existing roadmap for topic:", request.Topic)
-				rc.DB.Where("roadmap_id = ?", existingRoadmap.ID).
-					Order("\"order\" ASC").
-					Find(&steps)
-
-				if len(steps) > 0 {
-					for _, step := range steps {
-						roadmapSteps = append(roadmapSteps, step.Name)
-					}
-					shouldGenerateNew = false
-				} else {
-					fmt.Println("INFO: Existing roadmap has no steps, generating new one")
+			steps, _, err := loadRoadmapGraph(rc.DB, existingRoadmap.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load roadmap steps"})
+				return
+			}
+
+			if len(steps) > 0 {
+				// Use the roadmap as is if it or its last regeneration is less than 7 days old,
+				// or while a regeneration is waiting for the user
+				sevenDaysAgo := time.Now().Add(-7 * 24 * time.Hour)
+				refreshedAt := existingRoadmap.CreatedAt
+				var revision models.RoadmapRevision
+				hasRevision := rc.DB.Where("roadmap_id = ?", existingRoadmap.ID).
+					Order("created_at DESC").
+					First(&revision).Error == nil
+				if hasRevision && revision.CreatedAt.After(refreshedAt) {
+					refreshedAt = revision.CreatedAt
+				}
+
+				if refreshedAt.After(sevenDaysAgo) || (hasRevision && revision.Status == models.RoadmapRevisionPending) {
+					fmt.Println("INFO: Returning existing roadmap with", len(steps), "steps")
+					rc.respondWithRoadmap(c, existingRoadmap)
+					return
 				}
+
+				// Older roadmaps are regenerated as a proposal the user can merge or discard,
+				// so completed steps are never wiped
+				fmt.Println("INFO: Proposing regenerated roadmap for topic:", request.Topic)
+				if _, err := rc.proposeRevision(existingRoadmap, steps); err != nil {
+					fmt.Println("ERROR: Failed to regenerate roadmap:", err)
+				}
+				rc.respondWithRoadmap(c, existingRoadmap)
+				return
 			}
+
+			fmt.Println("INFO: Existing roadmap has no steps, generating new one")
 		}
 	}
 
-	// Если у нас есть существующий контент и не нужно генерировать новый
-	if !shouldGenerateNew {
-		fmt.Println("INFO: Returning existing roadmap with", len(roadmapSteps), "steps")
-		c.JSON(http.StatusOK, gin.H{"roadmap": roadmapSteps})
+	// Generate new roadmap
+	fmt.Println("INFO: Generating new roadmap for topic:", request.Topic)
+	steps, edges, err := rc.generateRoadmapGraph(request.Topic)
+	if err != nil {
+		fmt.Println("ERROR: Failed to generate roadmap:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating roadmap: " + err.Error()})
+		return
+	}
+
+	if !exists {
+		c.JSON(http.StatusOK, roadmapResponse(0, steps, edges))
 		return
 	}
 
-	// Generate new roadmap
-	fmt.Println("INFO: Generating new roadmap for topic:", request.Topic)
+	// The new roadmap replaces the old ones for the same topic in one transaction, so they are kept
+	// if it cannot be saved
+	roadmap := models.Roadmap{
+		Topic:  request.Topic,
+		UserID: userID,
+	}
+	err = rc.DB.Transaction(func(tx *gorm.DB) error {
+		var oldRoadmaps []models.Roadmap
+		if err := sameTopic(tx).Find(&oldRoadmaps).Error; err != nil {
+			return err
+		}
+		for _, oldRoadmap := range oldRoadmaps {
+			if err := deleteRoadmap(tx, oldRoadmap); err != nil {
+				return err
+			}
+		}
+		return saveRoadmapGraph(tx, &roadmap, steps, edges)
+	})
+	if err != nil {
+		// The roadmap is still useful to the learner, so return it without an ID
+		fmt.Println("ERROR: Failed to save roadmap to database:", err)
+		c.JSON(http.StatusOK, roadmapResponse(0, steps, edges))
+		return
+	}
 
-	// Create prompt
-	prompt := fmt.Sprintf(`You are an AI learning assistant. Your task is to create a clear and structured roadmap for learning "%s".
+	fmt.Println("INFO: Saved new roadmap with", len(steps), "steps to database")
+	c.JSON(http.StatusOK, roadmapResponse(roadmap.ID, steps, edges))
+}
 
-Response format:
-Step name
-Step name
-Step name
-Step name
-Step name
+// CompleteStep marks a roadmap step as completed along with its topic
+func (rc *RoadmapController) CompleteStep(c *gin.Context) {
+	rc.setStepCompleted(c, true)
+}
 
-Example for "HTML":
-HTML Basics
-Semantic Markup
-Forms and Input
-CSS Integration
-Practice
+// UncompleteStep marks a roadmap step as not completed along with its topic and the other steps
+// that cover the topic
+func (rc *RoadmapController) UncompleteStep(c *gin.Context) {
+	rc.setStepCompleted(c, false)
+}
 
-Use that response format
-Don't add text like "Here's the roadmap"
-Don't use **asterisks**
-Numbering 1-18, step — max 15 characters`, request.Topic)
+// setStepCompleted updates the completion of a step owned by the user and returns the roadmap completion
+func (rc *RoadmapController) setStepCompleted(c *gin.Context, completed bool) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
 
-	// Call OpenAI API
-	content, err := rc.CallOpenAI(prompt)
+	roadmap, err := findOwnedRoadmap(rc.DB, c.Param("id"), userData.ID)
 	if err != nil {
-		fmt.Println("ERROR: Failed to generate roadmap:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating roadmap: " + err.Error()})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
 		return
 	}
 
-	// Parse roadmap steps
-	steps := strings.Split(strings.TrimSpace(content), "\n")
-	// Filter out empty steps
-	roadmapSteps = []string{} // Очищаем на всякий случай
-	for _, step := range steps {
-		if step != "" {
-			roadmapSteps = append(roadmapSteps, step)
+	var step models.RoadmapStep
+	if err := rc.DB.Where("id = ? AND roadmap_id = ?", c.Param("stepId"), roadmap.ID).First(&step).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap step not found"})
+		return
+	}
+
+	if !completed && step.Mastered {
+		c.JSON(http.StatusConflict, gin.H{"error": "A mastered step cannot be marked as not completed"})
+		return
+	}
+
+	if step.Completed != completed {
+		now := time.Now()
+		step.Completed = completed
+		step.CompletedAt = nil
+		if completed {
+			step.CompletedAt = &now
+		}
+
+		err = rc.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&step).Error; err != nil {
+				return err
+			}
+			return setTopicCompleted(tx, userData.ID, step.Name, completed, now, "roadmap")
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update roadmap step"})
+			return
 		}
 	}
 
-	// If no steps were generated, return an error
-	if len(roadmapSteps) == 0 {
-		fmt.Println("ERROR: No roadmap steps were generated")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "No roadmap steps were generated"})
+	var steps []models.RoadmapStep
+	rc.DB.Where("roadmap_id = ?", roadmap.ID).Find(&steps)
+
+	c.JSON(http.StatusOK, gin.H{
+		"step":     step,
+		"progress": models.CalculateRoadmapCompletion(steps),
+	})
+}
+
+// ListRoadmaps lists the user's saved roadmaps with their completion
+func (rc *RoadmapController) ListRoadmaps(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
+	userData := user.(models.User)
 
-	// Сначала отправляем ответ клиенту
-	fmt.Println("INFO: Returning roadmap with", len(roadmapSteps), "steps")
-	c.JSON(http.StatusOK, gin.H{"roadmap": roadmapSteps})
+	var roadmaps []models.Roadmap
+	if err := rc.DB.Where("user_id = ?", userData.ID).
+		Preload("Steps").
+		Order("created_at DESC").
+		Find(&roadmaps).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve roadmaps"})
+		return
+	}
 
-	// Затем, если пользователь аутентифицирован, сохраняем в базу данных
-	if exists {
-		// Delete old roadmaps for the same topic to save space
-		var oldRoadmaps []models.Roadmap
-		rc.DB.Where("user_id = ? AND topic = ?", userID, request.Topic).Find(&oldRoadmaps)
+	summaries := make([]RoadmapSummary, 0, len(roadmaps))
+	for _, roadmap := range roadmaps {
+		summaries = append(summaries, RoadmapSummary{
+			ID:        roadmap.ID,
+			Topic:     roadmap.Topic,
+			CreatedAt: roadmap.CreatedAt,
+			UpdatedAt: roadmap.UpdatedAt,
+			Progress:  models.CalculateRoadmapCompletion(roadmap.Steps),
+		})
+	}
 
-		for _, oldRoadmap := range oldRoadmaps {
-			rc.DB.Where("roadmap_id = ?", oldRoadmap.ID).Delete(&models.RoadmapStep{})
-			rc.DB.Delete(&oldRoadmap)
+	c.JSON(http.StatusOK, gin.H{"roadmaps": summaries})
+}
+
+// GetRoadmap returns one of the user's roadmaps with its steps
+func (rc *RoadmapController) GetRoadmap(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	roadmap, err := findOwnedRoadmap(rc.DB, c.Param("id"), userData.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
+		return
+	}
+
+	rc.respondWithRoadmap(c, roadmap)
+}
+
+// UpdateRoadmap renames one of the user's roadmaps
+func (rc *RoadmapController) UpdateRoadmap(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	var request UpdateRoadmapRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roadmap, err := findOwnedRoadmap(rc.DB, c.Param("id"), userData.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
+		return
+	}
+
+	topic := strings.TrimSpace(request.Topic)
+	if topic == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Topic cannot be empty"})
+		return
+	}
+
+	roadmap.Topic = topic
+	if err := rc.DB.Save(&roadmap).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update roadmap"})
+		return
+	}
+
+	rc.respondWithRoadmap(c, roadmap)
+}
+
+// DeleteRoadmap deletes one of the user's roadmaps with its steps
+func (rc *RoadmapController) DeleteRoadmap(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	roadmap, err := findOwnedRoadmap(rc.DB, c.Param("id"), userData.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
+		return
+	}
+
+	err = rc.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteRoadmap(tx, roadmap)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete roadmap"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Roadmap deleted successfully"})
+}
+
+// AddStep adds a step to one of the user's roadmaps
+func (rc *RoadmapController) AddStep(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	var request RoadmapStepRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Name == nil || strings.TrimSpace(*request.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Step name is required"})
+		return
+	}
+
+	roadmap, err := findOwnedRoadmap(rc.DB, c.Param("id"), userData.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
+		return
+	}
+
+	steps, _, err := loadRoadmapGraph(rc.DB, roadmap.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load roadmap steps"})
+		return
+	}
+
+	step := models.RoadmapStep{RoadmapID: roadmap.ID, Key: nextStepKey(steps)}
+	applyStepRequest(&step, request)
+
+	// A new step has no dependents yet, so its prerequisites cannot create a cycle
+	prerequisiteIDs, err := validPrerequisites(steps, 0, request.Prerequisites)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Insert the step at the requested position, or after the last step
+	position := request.Position
+	if position <= 0 || position > len(steps)+1 {
+		position = len(steps) + 1
+	}
+
+	// The step cannot be inserted before its prerequisites
+	keys := make([]string, 0, len(steps)+1)
+	keysByID := make(map[uint]string, len(steps))
+	for _, existing := range steps {
+		keysByID[existing.ID] = models.StepKey(existing)
+		keys = append(keys, models.StepKey(existing))
+	}
+	keys = append(keys[:position-1], append([]string{step.Key}, keys[position-1:]...)...)
+	newEdges := make([]models.RoadmapGraphEdge, 0, len(prerequisiteIDs))
+	for _, id := range prerequisiteIDs {
+		newEdges = append(newEdges, models.RoadmapGraphEdge{From: keysByID[id], To: step.Key})
+	}
+	if !prerequisitesInOrder(keys, newEdges) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A step cannot come before its prerequisites"})
+		return
+	}
+
+	err = rc.DB.Transaction(func(tx *gorm.DB) error {
+		step.Order = position
+		if err := tx.Create(&step).Error; err != nil {
+			return err
+		}
+		if err := replacePrerequisites(tx, roadmap.ID, step.ID, prerequisiteIDs); err != nil {
+			return err
+		}
+
+		ordered := make([]uint, 0, len(steps)+1)
+		for _, existing := range steps {
+			if len(ordered) == position-1 {
+				ordered = append(ordered, step.ID)
+			}
+			ordered = append(ordered, existing.ID)
 		}
+		if len(ordered) < len(steps)+1 {
+			ordered = append(ordered, step.ID)
+		}
+		return renumberSteps(tx, ordered)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add roadmap step"})
+		return
+	}
+
+	rc.respondWithRoadmap(c, roadmap)
+}
+
+// UpdateStep edits a step of one of the user's roadmaps
+func (rc *RoadmapController) UpdateStep(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	var request RoadmapStepRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Name != nil && strings.TrimSpace(*request.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Step name cannot be empty"})
+		return
+	}
+
+	roadmap, err := findOwnedRoadmap(rc.DB, c.Param("id"), userData.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
+		return
+	}
+
+	steps, edges, err := loadRoadmapGraph(rc.DB, roadmap.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load roadmap steps"})
+		return
+	}
 
-		// Create new roadmap
-		roadmap := models.Roadmap{
-			Topic:  request.Topic,
-			UserID: userID,
+	stepID, _ := strconv.ParseUint(c.Param("stepId"), 10, 64)
+	index := -1
+	for i, existing := range steps {
+		if existing.ID == uint(stepID) {
+			index = i
 		}
+	}
+	if index < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap step not found"})
+		return
+	}
 
-		if err := rc.DB.Create(&roadmap).Error; err != nil {
-			fmt.Println("ERROR: Failed to save roadmap to database:", err)
-			return // Не возвращаем ошибку клиенту, т.к. ответ уже отправлен
+	step := steps[index]
+	applyStepRequest(&step, request)
+
+	var prerequisiteIDs []uint
+	if request.Prerequisites != nil {
+		prerequisiteIDs, err = validPrerequisites(steps, step.ID, request.Prerequisites)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
-		// Create roadmap steps
-		for i, stepName := range roadmapSteps {
-			roadmapStep := models.RoadmapStep{
-				Name:      stepName,
-				Order:     i + 1,
-				RoadmapID: roadmap.ID,
+		// Replace the step's incoming edges and make sure the graph is still acyclic
+		key := models.StepKey(step)
+		keysByID := make(map[uint]string, len(steps))
+		keys := make([]string, 0, len(steps))
+		for _, existing := range steps {
+			keysByID[existing.ID] = models.StepKey(existing)
+			keys = append(keys, models.StepKey(existing))
+		}
+		updatedEdges := make([]models.RoadmapGraphEdge, 0, len(edges)+len(prerequisiteIDs))
+		for _, edge := range edges {
+			if edge.To != key {
+				updatedEdges = append(updatedEdges, edge)
 			}
-			rc.DB.Create(&roadmapStep)
 		}
+		for _, id := range prerequisiteIDs {
+			updatedEdges = append(updatedEdges, models.RoadmapGraphEdge{From: keysByID[id], To: key})
+		}
+		if _, _, err := models.SortRoadmapDAG(keys, updatedEdges); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "These prerequisites would create a cycle"})
+			return
+		}
+		// The step keeps its position, so its prerequisites must already come before it
+		if !prerequisitesInOrder(keys, updatedEdges) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A step cannot come before its prerequisites"})
+			return
+		}
+	}
+
+	err = rc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&step).Error; err != nil {
+			return err
+		}
+		if request.Prerequisites != nil {
+			return replacePrerequisites(tx, roadmap.ID, step.ID, prerequisiteIDs)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update roadmap step"})
+		return
+	}
+
+	rc.respondWithRoadmap(c, roadmap)
+}
+
+// DeleteStep removes a step from one of the user's roadmaps and renumbers the rest
+func (rc *RoadmapController) DeleteStep(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	roadmap, err := findOwnedRoadmap(rc.DB, c.Param("id"), userData.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
+		return
+	}
+
+	var steps []models.RoadmapStep
+	if err := rc.DB.Where("roadmap_id = ?", roadmap.ID).Order("\"order\" ASC").Find(&steps).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load roadmap steps"})
+		return
+	}
+
+	stepID, _ := strconv.ParseUint(c.Param("stepId"), 10, 64)
+	remaining := make([]uint, 0, len(steps))
+	found := false
+	for _, step := range steps {
+		if step.ID == uint(stepID) {
+			found = true
+			continue
+		}
+		remaining = append(remaining, step.ID)
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap step not found"})
+		return
+	}
+
+	err = rc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("step_id = ? OR prerequisite_id = ?", stepID, stepID).
+			Delete(&models.RoadmapStepPrerequisite{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.RoadmapStep{}, stepID).Error; err != nil {
+			return err
+		}
+		return renumberSteps(tx, remaining)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete roadmap step"})
+		return
+	}
+
+	rc.respondWithRoadmap(c, roadmap)
+}
+
+// ReorderSteps sets the order of all steps in one of the user's roadmaps
+func (rc *RoadmapController) ReorderSteps(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	var request ReorderStepsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roadmap, err := findOwnedRoadmap(rc.DB, c.Param("id"), userData.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
+		return
+	}
+
+	steps, edges, err := loadRoadmapGraph(rc.DB, roadmap.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load roadmap steps"})
+		return
+	}
+
+	// The new order must list every step of the roadmap exactly once
+	keysByID := make(map[uint]string, len(steps))
+	for _, step := range steps {
+		keysByID[step.ID] = models.StepKey(step)
+	}
+	keys := make([]string, 0, len(request.StepIDs))
+	listed := make(map[string]bool, len(request.StepIDs))
+	for _, id := range request.StepIDs {
+		key, ok := keysByID[id]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Step %d does not belong to this roadmap", id)})
+			return
+		}
+		if listed[key] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Step %d is listed more than once", id)})
+			return
+		}
+		listed[key] = true
+		keys = append(keys, key)
+	}
+	if len(keys) != len(steps) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The new order must include every step of the roadmap"})
+		return
+	}
+
+	// Steps cannot be moved before their prerequisites
+	if !prerequisitesInOrder(keys, edges) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A step cannot come before its prerequisites"})
+		return
+	}
+
+	if err := rc.DB.Transaction(func(tx *gorm.DB) error {
+		return renumberSteps(tx, request.StepIDs)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder roadmap steps"})
+		return
+	}
+
+	rc.respondWithRoadmap(c, roadmap)
+}
+
+// RegenerateRoadmap generates a new version of one of the user's roadmaps as a merge proposal
+func (rc *RoadmapController) RegenerateRoadmap(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	roadmap, err := findOwnedRoadmap(rc.DB, c.Param("id"), userData.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
+		return
+	}
+
+	var steps []models.RoadmapStep
+	if err := rc.DB.Where("roadmap_id = ?", roadmap.ID).Order("\"order\" ASC").Find(&steps).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load roadmap steps"})
+		return
+	}
+
+	if _, err := rc.proposeRevision(roadmap, steps); err != nil {
+		fmt.Println("ERROR: Failed to regenerate roadmap:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error regenerating roadmap: " + err.Error()})
+		return
+	}
+
+	rc.respondWithRoadmap(c, roadmap)
+}
+
+// AcceptRevision merges a regeneration proposal into the roadmap
+func (rc *RoadmapController) AcceptRevision(c *gin.Context) {
+	rc.resolveRevision(c, models.RoadmapRevisionAccepted)
+}
+
+// DiscardRevision rejects a regeneration proposal and leaves the roadmap unchanged
+func (rc *RoadmapController) DiscardRevision(c *gin.Context) {
+	rc.resolveRevision(c, models.RoadmapRevisionDiscarded)
+}
+
+// resolveRevision accepts or discards a pending revision of one of the user's roadmaps
+func (rc *RoadmapController) resolveRevision(c *gin.Context, status models.RoadmapRevisionStatus) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	roadmap, err := findOwnedRoadmap(rc.DB, c.Param("id"), userData.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
+		return
+	}
+
+	var revision models.RoadmapRevision
+	if err := rc.DB.Where("id = ? AND roadmap_id = ?", c.Param("revisionId"), roadmap.ID).First(&revision).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap revision not found"})
+		return
+	}
+	if revision.Status != models.RoadmapRevisionPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Roadmap revision has already been " + string(revision.Status)})
+		return
+	}
+
+	err = rc.DB.Transaction(func(tx *gorm.DB) error {
+		if status == models.RoadmapRevisionAccepted {
+			if err := applyRoadmapRevision(tx, roadmap, revision); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		revision.Status = status
+		revision.ResolvedAt = &now
+		return tx.Save(&revision).Error
+	})
+	if err != nil {
+		fmt.Printf("Error resolving roadmap revision %d: %v\n", revision.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update roadmap revision"})
+		return
+	}
+
+	rc.respondWithRoadmap(c, roadmap)
+}
+
+// proposeRevision regenerates a roadmap and stores the merge with its current steps as a pending
+// revision, replacing any earlier pending one
+func (rc *RoadmapController) proposeRevision(roadmap models.Roadmap, steps []models.RoadmapStep) (models.RoadmapRevision, error) {
+	generated, edges, err := rc.generateRoadmapGraph(roadmap.Topic)
+	if err != nil {
+		return models.RoadmapRevision{}, err
+	}
+
+	revision := models.RoadmapRevision{
+		RoadmapID: roadmap.ID,
+		UserID:    roadmap.UserID,
+		Status:    models.RoadmapRevisionPending,
+		Proposal:  models.BuildRoadmapProposal(steps, generated, edges),
+	}
+
+	err = rc.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.RoadmapRevision{}).
+			Where("roadmap_id = ? AND status = ?", roadmap.ID, models.RoadmapRevisionPending).
+			Updates(map[string]interface{}{
+				"status":      models.RoadmapRevisionDiscarded,
+				"resolved_at": now,
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&revision).Error
+	})
+	return revision, err
+}
+
+// ExportRoadmap downloads one of the user's roadmaps as a JSON or YAML document or a Markdown checklist
+func (rc *RoadmapController) ExportRoadmap(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	roadmap, err := findOwnedRoadmap(rc.DB, c.Param("id"), userData.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
+		return
+	}
+
+	steps, edges, err := loadRoadmapGraph(rc.DB, roadmap.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load roadmap steps"})
+		return
+	}
+
+	document := models.NewRoadmapDocument(roadmap, steps, edges)
+	filename := slugUnsafeChars.ReplaceAllString(strings.ToLower(roadmap.Topic), "-")
+	filename = strings.Trim(filename, "-")
+	if filename == "" {
+		filename = fmt.Sprintf("roadmap-%d", roadmap.ID)
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+		c.IndentedJSON(http.StatusOK, document)
+	case "yaml":
+		out, err := yaml.Marshal(document)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export roadmap"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".yaml"))
+		c.Data(http.StatusOK, "application/yaml; charset=utf-8", out)
+	case "markdown":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".md"))
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(document.Markdown()))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be json, yaml or markdown"})
+	}
+}
+
+// ImportRoadmap creates a roadmap for the user from a JSON or YAML roadmap document
+func (rc *RoadmapController) ImportRoadmap(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxRoadmapDocumentBytes))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Roadmap document is too large"})
+		return
+	}
+
+	// The format comes from the query, then the content type, and defaults to JSON
+	format := c.Query("format")
+	if format == "" {
+		format = "json"
+		if strings.Contains(c.ContentType(), "yaml") {
+			format = "yaml"
+		}
+	}
+
+	var document models.RoadmapDocument
+	switch format {
+	case "json":
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&document)
+	case "yaml":
+		decoder := yaml.NewDecoder(bytes.NewReader(body))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&document)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be json or yaml"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Invalid roadmap document",
+			"fields": []models.DocumentFieldError{{Field: "", Message: err.Error()}},
+		})
+		return
+	}
+
+	if fieldErrors := document.Validate(); len(fieldErrors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  "Invalid roadmap document",
+			"fields": fieldErrors,
+		})
+		return
+	}
+
+	steps, edges := document.RoadmapSteps()
+	roadmap := models.Roadmap{
+		Topic:  strings.TrimSpace(document.Topic),
+		UserID: userData.ID,
+	}
+	if err := saveRoadmapGraph(rc.DB, &roadmap, steps, edges); err != nil {
+		fmt.Println("ERROR: Failed to import roadmap:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import roadmap"})
+		return
+	}
+
+	response := roadmapResponse(roadmap.ID, steps, edges)
+	response["topic"] = roadmap.Topic
+	c.JSON(http.StatusCreated, response)
+}
+
+// respondWithRoadmap returns a roadmap with its current steps and graph
+func (rc *RoadmapController) respondWithRoadmap(c *gin.Context, roadmap models.Roadmap) {
+	steps, edges, err := loadRoadmapGraph(rc.DB, roadmap.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load roadmap steps"})
+		return
+	}
+
+	response := roadmapResponse(roadmap.ID, steps, edges)
+	response["topic"] = roadmap.Topic
+	response["steps"] = steps
+
+	now := time.Now()
+	response["eta"] = predictRoadmapCompletion(rc.DB, roadmap.ID, steps, learnerPace(rc.DB, roadmap.UserID, now), now, nil)
+
+	// Include a regeneration waiting for the user's decision
+	var revision models.RoadmapRevision
+	if err := rc.DB.Where("roadmap_id = ? AND status = ?", roadmap.ID, models.RoadmapRevisionPending).
+		Order("created_at DESC").
+		First(&revision).Error; err == nil {
+		response["revision"] = revision
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// generateRoadmapGraph asks the language model for a structured roadmap, retrying once if it is unusable
+func (rc *RoadmapController) generateRoadmapGraph(topic string) ([]models.RoadmapStep, []models.RoadmapGraphEdge, error) {
+	prompt := fmt.Sprintf(`You are an AI learning assistant. Your task is to create a clear and structured roadmap for learning "%s".
+
+The roadmap is a graph: a step may only start after its prerequisites are finished, and steps without a
+dependency between them can be studied in parallel.
+
+Respond with a JSON object in exactly this format:
+{
+  "steps": [
+    {
+      "id": "s1",
+      "name": "HTML Basics",
+      "description": "What the step covers in one or two sentences",
+      "estimatedHours": 4,
+      "objectives": ["Explain the structure of an HTML document", "Use headings, paragraphs and links"],
+      "resources": [
+        {"title": "MDN: Getting started with HTML", "url": "https://developer.mozilla.org/en-US/docs/Learn/HTML", "type": "documentation"}
+      ],
+      "prerequisites": []
+    },
+    {
+      "id": "s2",
+      "name": "Semantic Markup",
+      "description": "...",
+      "estimatedHours": 3,
+      "objectives": ["..."],
+      "resources": [],
+      "prerequisites": ["s1"]
+    }
+  ]
+}
+
+IMPORTANT:
+- Create between 6 and 18 steps
+- Step names are short, at most 40 characters, without numbering or **asterisks**
+- "prerequisites" only contains ids of other steps in this roadmap and must not form a cycle
+- "estimatedHours" is a number
+- Resource "type" is one of: article, video, documentation, course, book, practice
+- Only return the JSON object, without text like "Here's the roadmap"`, topic)
+
+	var lastErr error
+	for attempt := 1; attempt <= maxRoadmapGenerationAttempts; attempt++ {
+		content, err := rc.CallOpenAI(prompt)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		steps, edges, err := parseRoadmapGraph(content)
+		if err == nil {
+			return steps, edges, nil
+		}
+
+		fmt.Printf("WARNING: Unusable roadmap on attempt %d: %v\n", attempt, err)
+		lastErr = err
+	}
+
+	return nil, nil, lastErr
+}
+
+// parseRoadmapGraph turns the language model response into ordered steps and validated prerequisite edges
+func parseRoadmapGraph(content string) ([]models.RoadmapStep, []models.RoadmapGraphEdge, error) {
+	cleanedJSON := llm.CleanupJSONResponse(content)
+
+	var generated GeneratedRoadmap
+	if err := json.Unmarshal([]byte(cleanedJSON), &generated); err != nil || len(generated.Steps) == 0 {
+		// Accept a bare array of steps as well
+		if arrayErr := json.Unmarshal([]byte(cleanedJSON), &generated.Steps); arrayErr != nil {
+			return nil, nil, fmt.Errorf("invalid roadmap JSON: %v", err)
+		}
+	}
+
+	// Give every step a unique key, remembering which key each generated id now refers to
+	keys := make([]string, 0, len(generated.Steps))
+	keyByID := make(map[string]string, len(generated.Steps))
+	stepsByKey := make(map[string]models.RoadmapStep, len(generated.Steps))
+	for i, g := range generated.Steps {
+		name := truncateRunes(strings.TrimSpace(strings.Trim(g.Name, "*")), 200)
+		if name == "" {
+			continue
+		}
+
+		key := truncateRunes(strings.TrimSpace(g.ID), 50)
+		for suffix := i + 1; key == "" || stepsByKey[key].Name != ""; suffix++ {
+			key = fmt.Sprintf("s%d", suffix)
+		}
+		if _, seen := keyByID[g.ID]; g.ID != "" && !seen {
+			keyByID[g.ID] = key
+		}
+
+		resources := make(models.StepResources, 0, len(g.Resources))
+		for _, resource := range g.Resources {
+			if strings.TrimSpace(resource.Title) != "" {
+				resources = append(resources, resource)
+			}
+		}
+
+		objectives := make([]string, 0, len(g.Objectives))
+		for _, objective := range g.Objectives {
+			if objective = strings.TrimSpace(objective); objective != "" {
+				objectives = append(objectives, objective)
+			}
+		}
+
+		keys = append(keys, key)
+		stepsByKey[key] = models.RoadmapStep{
+			Name:           name,
+			Key:            key,
+			Description:    strings.TrimSpace(g.Description),
+			EstimatedHours: math.Max(0, math.Min(g.EstimatedHours, maxStepEstimatedHours)),
+			Objectives:     pq.StringArray(objectives),
+			Resources:      resources,
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, nil, fmt.Errorf("no roadmap steps were generated")
+	}
+
+	// Keep only edges between known steps, dropping self-references and duplicates
+	var edges []models.RoadmapGraphEdge
+	seenEdges := make(map[models.RoadmapGraphEdge]bool)
+	for _, g := range generated.Steps {
+		to, ok := keyByID[g.ID]
+		if !ok {
+			continue
+		}
+		for _, prerequisite := range g.Prerequisites {
+			from, ok := keyByID[prerequisite]
+			edge := models.RoadmapGraphEdge{From: from, To: to}
+			if !ok || from == to || seenEdges[edge] {
+				continue
+			}
+			seenEdges[edge] = true
+			edges = append(edges, edge)
+		}
+	}
+
+	sortedKeys, _, err := models.SortRoadmapDAG(keys, edges)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	steps := make([]models.RoadmapStep, 0, len(sortedKeys))
+	for i, key := range sortedKeys {
+		step := stepsByKey[key]
+		step.Order = i + 1
+		steps = append(steps, step)
+	}
+
+	return steps, edges, nil
+}
+
+// saveRoadmapGraph stores a roadmap with its steps and prerequisite edges in one transaction
+func saveRoadmapGraph(db *gorm.DB, roadmap *models.Roadmap, steps []models.RoadmapStep, edges []models.RoadmapGraphEdge) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(roadmap).Error; err != nil {
+			return err
+		}
+
+		stepIDs := make(map[string]uint, len(steps))
+		for i := range steps {
+			steps[i].RoadmapID = roadmap.ID
+			if err := tx.Create(&steps[i]).Error; err != nil {
+				return err
+			}
+			stepIDs[steps[i].Key] = steps[i].ID
+		}
+
+		for _, edge := range edges {
+			prerequisite := models.RoadmapStepPrerequisite{
+				RoadmapID:      roadmap.ID,
+				StepID:         stepIDs[edge.To],
+				PrerequisiteID: stepIDs[edge.From],
+			}
+			if err := tx.Create(&prerequisite).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// deleteRoadmap deletes a roadmap with its steps and the study plans and goals that follow it
+func deleteRoadmap(tx *gorm.DB, roadmap models.Roadmap) error {
+	plans := tx.Model(&models.StudyPlan{}).Select("id").Where("roadmap_id = ?", roadmap.ID)
+	if err := tx.Unscoped().Where("plan_id IN (?)", plans).Delete(&models.StudySession{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("roadmap_id = ?", roadmap.ID).Delete(&models.StudyPlan{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("roadmap_id = ?", roadmap.ID).Delete(&models.Goal{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("roadmap_id = ?", roadmap.ID).Delete(&models.RoadmapStepPrerequisite{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("roadmap_id = ?", roadmap.ID).Delete(&models.RoadmapStep{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&roadmap).Error
+}
+
+// loadRoadmapGraph loads the ordered steps and prerequisite edges of a roadmap
+func loadRoadmapGraph(db *gorm.DB, roadmapID uint) ([]models.RoadmapStep, []models.RoadmapGraphEdge, error) {
+	var steps []models.RoadmapStep
+	if err := db.Where("roadmap_id = ?", roadmapID).
+		Order("\"order\" ASC").
+		Find(&steps).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var prerequisites []models.RoadmapStepPrerequisite
+	if err := db.Where("roadmap_id = ?", roadmapID).Find(&prerequisites).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return steps, models.PrerequisiteEdges(steps, prerequisites), nil
+}
+
+// findOwnedRoadmap loads a roadmap by ID if it belongs to the user
+func findOwnedRoadmap(db *gorm.DB, roadmapID string, userID uint) (models.Roadmap, error) {
+	var roadmap models.Roadmap
+	err := db.Where("id = ? AND user_id = ?", roadmapID, userID).First(&roadmap).Error
+	return roadmap, err
+}
+
+// roadmapResponse builds the roadmap response: the step names in order for existing clients and the graph for layout
+func roadmapResponse(roadmapID uint, steps []models.RoadmapStep, edges []models.RoadmapGraphEdge) gin.H {
+	names := make([]string, 0, len(steps))
+	for _, step := range steps {
+		names = append(names, step.Name)
+	}
+
+	response := gin.H{
+		"roadmap":  names,
+		"graph":    models.BuildRoadmapGraph(steps, edges),
+		"progress": models.CalculateRoadmapCompletion(steps),
+	}
+	if roadmapID != 0 {
+		response["id"] = roadmapID
+	}
+	return response
+}
+
+// applyRoadmapRevision merges an accepted revision into the roadmap. Kept steps keep their IDs and
+// completion, proposed steps are added, proposed removals are deleted, and steps added after the
+// proposal was made are kept at the end.
+func applyRoadmapRevision(tx *gorm.DB, roadmap models.Roadmap, revision models.RoadmapRevision) error {
+	var steps []models.RoadmapStep
+	if err := tx.Where("roadmap_id = ?", roadmap.ID).Order("\"order\" ASC").Find(&steps).Error; err != nil {
+		return err
+	}
+	stepsByID := make(map[uint]models.RoadmapStep, len(steps))
+	for _, step := range steps {
+		stepsByID[step.ID] = step
+	}
+
+	ordered := make([]uint, 0, len(revision.Proposal.Steps))
+	idsByKey := make(map[string]uint, len(revision.Proposal.Steps))
+	placed := make(map[uint]bool, len(steps))
+
+	for _, proposed := range revision.Proposal.Steps {
+		existing, ok := stepsByID[proposed.ExistingStepID]
+		if proposed.Action == models.ProposedStepKeep && ok && !placed[existing.ID] {
+			// Keep the learner's step, filling in the details it is missing
+			existing.Key = proposed.Key
+			if existing.Description == "" {
+				existing.Description = proposed.Description
+			}
+			if existing.EstimatedHours == 0 {
+				existing.EstimatedHours = proposed.EstimatedHours
+			}
+			if len(existing.Objectives) == 0 {
+				existing.Objectives = pq.StringArray(proposed.Objectives)
+			}
+			if len(existing.Resources) == 0 {
+				existing.Resources = proposed.Resources
+			}
+			if err := tx.Save(&existing).Error; err != nil {
+				return err
+			}
+
+			placed[existing.ID] = true
+			idsByKey[proposed.Key] = existing.ID
+			ordered = append(ordered, existing.ID)
+			continue
+		}
+
+		// New steps, and kept steps that were deleted since the proposal, are created
+		step := models.RoadmapStep{
+			RoadmapID:      roadmap.ID,
+			Key:            proposed.Key,
+			Name:           proposed.Name,
+			Description:    proposed.Description,
+			EstimatedHours: proposed.EstimatedHours,
+			Objectives:     pq.StringArray(proposed.Objectives),
+			Resources:      proposed.Resources,
+			Order:          len(ordered) + 1,
+		}
+		if err := tx.Create(&step).Error; err != nil {
+			return err
+		}
+		idsByKey[proposed.Key] = step.ID
+		ordered = append(ordered, step.ID)
+	}
+
+	removed := make(map[uint]bool, len(revision.Proposal.Removed))
+	for _, step := range revision.Proposal.Removed {
+		removed[step.StepID] = true
+	}
+
+	var removedIDs []uint
+	for _, step := range steps {
+		if placed[step.ID] {
+			continue
+		}
+		if removed[step.ID] && !step.Completed {
+			removedIDs = append(removedIDs, step.ID)
+			continue
+		}
+
+		// Steps the proposal does not know about stay, with a key that cannot clash with the proposal
+		if _, clash := idsByKey[models.StepKey(step)]; clash {
+			step.Key = fmt.Sprintf("step-%d", step.ID)
+			if err := tx.Save(&step).Error; err != nil {
+				return err
+			}
+		}
+		ordered = append(ordered, step.ID)
+	}
+
+	if len(removedIDs) > 0 {
+		if err := tx.Where("id IN ?", removedIDs).Delete(&models.RoadmapStep{}).Error; err != nil {
+			return err
+		}
+	}
+
+	// The proposal's edges replace the old prerequisites
+	if err := tx.Unscoped().Where("roadmap_id = ?", roadmap.ID).Delete(&models.RoadmapStepPrerequisite{}).Error; err != nil {
+		return err
+	}
+	for _, edge := range revision.Proposal.Edges {
+		from, okFrom := idsByKey[edge.From]
+		to, okTo := idsByKey[edge.To]
+		if !okFrom || !okTo || from == to {
+			continue
+		}
+		prerequisite := models.RoadmapStepPrerequisite{
+			RoadmapID:      roadmap.ID,
+			StepID:         to,
+			PrerequisiteID: from,
+		}
+		if err := tx.Create(&prerequisite).Error; err != nil {
+			return err
+		}
+	}
+
+	return renumberSteps(tx, ordered)
+}
+
+// applyStepRequest copies the fields present in a step request onto a step
+func applyStepRequest(step *models.RoadmapStep, request RoadmapStepRequest) {
+	if request.Name != nil {
+		step.Name = truncateRunes(strings.TrimSpace(*request.Name), 200)
+	}
+	if request.Description != nil {
+		step.Description = strings.TrimSpace(*request.Description)
+	}
+	if request.EstimatedHours != nil {
+		step.EstimatedHours = math.Max(0, math.Min(*request.EstimatedHours, maxStepEstimatedHours))
+	}
+	if request.Objectives != nil {
+		step.Objectives = pq.StringArray(request.Objectives)
+	}
+	if request.Resources != nil {
+		step.Resources = models.StepResources(request.Resources)
+	}
+}
+
+// validPrerequisites checks that prerequisite IDs refer to other steps of the roadmap and removes duplicates
+func validPrerequisites(steps []models.RoadmapStep, stepID uint, prerequisites *[]uint) ([]uint, error) {
+	if prerequisites == nil {
+		return nil, nil
+	}
+
+	known := make(map[uint]bool, len(steps))
+	for _, step := range steps {
+		known[step.ID] = true
+	}
+
+	seen := make(map[uint]bool, len(*prerequisites))
+	ids := make([]uint, 0, len(*prerequisites))
+	for _, id := range *prerequisites {
+		if id == stepID {
+			return nil, fmt.Errorf("a step cannot be its own prerequisite")
+		}
+		if !known[id] {
+			return nil, fmt.Errorf("prerequisite step %d does not belong to this roadmap", id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// prerequisitesInOrder reports whether every step comes after its prerequisites in an order of
+// step keys
+func prerequisitesInOrder(keys []string, edges []models.RoadmapGraphEdge) bool {
+	position := make(map[string]int, len(keys))
+	for i, key := range keys {
+		position[key] = i
+	}
+	for _, edge := range edges {
+		if position[edge.From] > position[edge.To] {
+			return false
+		}
+	}
+	return true
+}
+
+// replacePrerequisites replaces the prerequisite edges leading into a step
+func replacePrerequisites(tx *gorm.DB, roadmapID, stepID uint, prerequisiteIDs []uint) error {
+	if err := tx.Unscoped().Where("step_id = ?", stepID).Delete(&models.RoadmapStepPrerequisite{}).Error; err != nil {
+		return err
+	}
+
+	for _, id := range prerequisiteIDs {
+		prerequisite := models.RoadmapStepPrerequisite{
+			RoadmapID:      roadmapID,
+			StepID:         stepID,
+			PrerequisiteID: id,
+		}
+		if err := tx.Create(&prerequisite).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renumberSteps sets the order of steps to their position in the list, starting at 1
+func renumberSteps(tx *gorm.DB, stepIDs []uint) error {
+	for i, id := range stepIDs {
+		if err := tx.Model(&models.RoadmapStep{}).Where("id = ?", id).Update("order", i+1).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextStepKey returns a step key that is not used by any of the steps
+func nextStepKey(steps []models.RoadmapStep) string {
+	used := make(map[string]bool, len(steps))
+	for _, step := range steps {
+		used[models.StepKey(step)] = true
+	}
+
+	for n := len(steps) + 1; ; n++ {
+		key := fmt.Sprintf("s%d", n)
+		if !used[key] {
+			return key
+		}
+	}
+}
 
-		fmt.Println("INFO: Saved new roadmap with", len(roadmapSteps), "steps to database")
+// truncateRunes shortens a string to at most n characters
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
 	}
+	return strings.TrimSpace(string(runes[:n]))
 }
@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"mentorback/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// similarityRecordThreshold is the lowest pairwise score that is stored
+	similarityRecordThreshold = 0.3
+	// similarityFlagThreshold is the score at which a pair is flagged for review
+	similarityFlagThreshold = 0.8
+	// similarityMinFingerprints skips submissions too short to compare meaningfully
+	similarityMinFingerprints = 5
+	// similarityMaxComparisons caps how many earlier submissions a new one is compared against
+	similarityMaxComparisons = 500
+)
+
+// SimilarityController handles the review of suspiciously similar code submissions
+type SimilarityController struct {
+	DB *gorm.DB
+}
+
+// NewSimilarityController creates a new similarity controller
+func NewSimilarityController(db *gorm.DB) *SimilarityController {
+	return &SimilarityController{DB: db}
+}
+
+// ReviewSimilarityRequest represents a reviewer's verdict on a flagged pair
+type ReviewSimilarityRequest struct {
+	Status models.SimilarityReviewStatus `json:"status" binding:"required,oneof=confirmed dismissed"`
+	Note   string                        `json:"note"`
+}
+
+// SimilarityReviewUser is the learner information shown next to a flagged submission
+type SimilarityReviewUser struct {
+	ID          uint   `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"displayName"`
+}
+
+// SimilarityReviewItem is a flagged pair with both submissions and the exercise they answer
+type SimilarityReviewItem struct {
+	models.SubmissionSimilarity
+	Exercise models.ExerciseItem    `json:"exercise"`
+	AttemptA models.ExerciseAttempt `json:"attemptA"`
+	AttemptB models.ExerciseAttempt `json:"attemptB"`
+	UserA    SimilarityReviewUser   `json:"userA"`
+	UserB    SimilarityReviewUser   `json:"userB"`
+}
+
+// GetFlaggedSubmissions lists the flagged submission pairs for review
+func (sc *SimilarityController) GetFlaggedSubmissions(c *gin.Context) {
+	query, ok := sc.reviewScope(c)
+	if !ok {
+		return
+	}
+
+	status := c.DefaultQuery("status", string(models.SimilarityReviewPending))
+	if status != "all" {
+		query = query.Where("review_status = ?", status)
+	}
+
+	if minScore, err := strconv.ParseFloat(c.Query("minScore"), 64); err == nil {
+		query = query.Where("score >= ?", minScore)
+	}
+
+	var pairs []models.SubmissionSimilarity
+	if err := query.Where("flagged = ?", true).
+		Order("score DESC").
+		Limit(100).
+		Find(&pairs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve flagged submissions"})
+		return
+	}
+
+	items, err := sc.loadReviewItems(pairs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load submission details"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"pairs": items}})
+}
+
+// ReviewSubmission records the reviewer's verdict on a flagged pair
+func (sc *SimilarityController) ReviewSubmission(c *gin.Context) {
+	query, ok := sc.reviewScope(c)
+	if !ok {
+		return
+	}
+
+	var request ReviewSimilarityRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var pair models.SubmissionSimilarity
+	if err := query.Where("id = ?", c.Param("id")).First(&pair).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Submission pair not found"})
+		return
+	}
+
+	admin := c.MustGet("admin").(models.Admin)
+	now := time.Now()
+	pair.ReviewStatus = request.Status
+	pair.ReviewNote = request.Note
+	pair.ReviewerID = &admin.ID
+	pair.ReviewedAt = &now
+
+	if err := sc.DB.Save(&pair).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save review"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": pair})
+}
+
+// reviewScope limits submission pairs to admins, as a pair shows two learners' code
+func (sc *SimilarityController) reviewScope(c *gin.Context) (*gorm.DB, bool) {
+	adminValue, exists := c.Get("admin")
+	if !exists {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Admin privileges required"})
+		return nil, false
+	}
+	if _, ok := adminValue.(models.Admin); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Invalid admin data"})
+		return nil, false
+	}
+	return sc.DB.Model(&models.SubmissionSimilarity{}), true
+}
+
+// loadReviewItems attaches the exercises, attempts and users to a list of pairs
+func (sc *SimilarityController) loadReviewItems(pairs []models.SubmissionSimilarity) ([]SimilarityReviewItem, error) {
+	items := make([]SimilarityReviewItem, 0, len(pairs))
+	if len(pairs) == 0 {
+		return items, nil
+	}
+
+	var attemptIDs, userIDs, exerciseIDs []uint
+	for _, pair := range pairs {
+		attemptIDs = append(attemptIDs, pair.AttemptAID, pair.AttemptBID)
+		userIDs = append(userIDs, pair.UserAID, pair.UserBID)
+		exerciseIDs = append(exerciseIDs, pair.ExerciseItemID)
+	}
+
+	var attempts []models.ExerciseAttempt
+	if err := sc.DB.Where("id IN ?", attemptIDs).Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err := sc.DB.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	var exercises []models.ExerciseItem
+	if err := sc.DB.Unscoped().Where("id IN ?", exerciseIDs).Find(&exercises).Error; err != nil {
+		return nil, err
+	}
+
+	attemptsByID := make(map[uint]models.ExerciseAttempt, len(attempts))
+	for _, attempt := range attempts {
+		attemptsByID[attempt.ID] = attempt
+	}
+	usersByID := make(map[uint]SimilarityReviewUser, len(users))
+	for _, user := range users {
+		usersByID[user.ID] = SimilarityReviewUser{ID: user.ID, Username: user.Username, DisplayName: user.DisplayName}
+	}
+	exercisesByID := make(map[uint]models.ExerciseItem, len(exercises))
+	for _, exercise := range exercises {
+		exercisesByID[exercise.ID] = exercise
+	}
+
+	for _, pair := range pairs {
+		items = append(items, SimilarityReviewItem{
+			SubmissionSimilarity: pair,
+			Exercise:             exercisesByID[pair.ExerciseItemID],
+			AttemptA:             attemptsByID[pair.AttemptAID],
+			AttemptB:             attemptsByID[pair.AttemptBID],
+			UserA:                usersByID[pair.UserAID],
+			UserB:                usersByID[pair.UserBID],
+		})
+	}
+	return items, nil
+}
+
+// recordSubmissionSimilarities compares a new code submission with other users' latest
+// submissions for the same exercise and stores the similar pairs
+func recordSubmissionSimilarities(tx *gorm.DB, attempt *models.ExerciseAttempt) error {
+	if attempt.ExerciseItemID == nil || len(attempt.Fingerprints) < similarityMinFingerprints {
+		return nil
+	}
+
+	var others []models.ExerciseAttempt
+	if err := tx.Where("exercise_item_id = ? AND user_id <> ? AND id <> ? AND code <> ''",
+		*attempt.ExerciseItemID, attempt.UserID, attempt.ID).
+		Order("created_at DESC").
+		Limit(similarityMaxComparisons).
+		Find(&others).Error; err != nil {
+		return err
+	}
+
+	compared := make(map[uint]bool)
+	for _, other := range others {
+		// Only the latest submission of each user counts
+		if compared[other.UserID] || len(other.Fingerprints) < similarityMinFingerprints {
+			continue
+		}
+		compared[other.UserID] = true
+
+		score := models.FingerprintSimilarity(attempt.Fingerprints, other.Fingerprints)
+		if score < similarityRecordThreshold {
+			continue
+		}
+
+		pair := models.SubmissionSimilarity{
+			ExerciseItemID: *attempt.ExerciseItemID,
+			AttemptAID:     other.ID,
+			AttemptBID:     attempt.ID,
+			UserAID:        other.UserID,
+			UserBID:        attempt.UserID,
+			Score:          score,
+			Flagged:        score >= similarityFlagThreshold,
+			ReviewStatus:   models.SimilarityReviewPending,
+		}
+		if err := tx.Create(&pair).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,402 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"mentorback/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// StudyPlanController handles study schedules and the calendar feed
+type StudyPlanController struct {
+	BaseController
+}
+
+// NewStudyPlanController creates a new study plan controller
+func NewStudyPlanController(base BaseController) *StudyPlanController {
+	return &StudyPlanController{BaseController: base}
+}
+
+// defaultSessionStartHour is the local hour study sessions start unless the user picks another
+const defaultSessionStartHour = 18
+
+// StudyPlanRequest represents the body for creating or updating a study plan
+type StudyPlanRequest struct {
+	Timezone         string             `json:"timezone" binding:"required"`
+	Availability     map[string]float64 `json:"availability" binding:"required"` // Hours per weekday, e.g. {"monday": 1.5}
+	TargetDate       string             `json:"targetDate"`                      // YYYY-MM-DD, optional
+	SessionStartHour *int               `json:"sessionStartHour" binding:"omitempty,min=0,max=23"`
+}
+
+// SavePlan creates or updates the study plan of one of the user's roadmaps and schedules it
+func (pc *StudyPlanController) SavePlan(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	var request StudyPlanRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roadmap, err := findOwnedRoadmap(pc.DB, c.Param("id"), userData.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
+		return
+	}
+
+	loc, err := models.LoadTimezone(request.Timezone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown timezone: " + request.Timezone})
+		return
+	}
+
+	availability := make(models.WeeklyAvailability)
+	for day, hours := range request.Availability {
+		day = strings.ToLower(strings.TrimSpace(day))
+		if !isWeekday(day) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown weekday: " + day})
+			return
+		}
+		if hours < 0 || hours > 16 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Hours per day must be between 0 and 16"})
+			return
+		}
+		availability[day] = hours
+	}
+	if availability.WeeklyHours() <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one weekday needs available hours"})
+		return
+	}
+
+	var targetDate *time.Time
+	if request.TargetDate != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", request.TargetDate, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Target date must be in YYYY-MM-DD format"})
+			return
+		}
+		if parsed.AddDate(0, 0, 1).Before(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Target date must not be in the past"})
+			return
+		}
+		targetDate = &parsed
+	}
+
+	var plan models.StudyPlan
+	pc.DB.Where("roadmap_id = ?", roadmap.ID).First(&plan)
+	plan.UserID = userData.ID
+	plan.RoadmapID = roadmap.ID
+	plan.Timezone = loc.String()
+	plan.Availability = availability
+	plan.TargetDate = targetDate
+	if request.SessionStartHour != nil {
+		plan.SessionStartHour = *request.SessionStartHour
+	} else if plan.ID == 0 {
+		plan.SessionStartHour = defaultSessionStartHour
+	}
+
+	steps, edges, err := loadRoadmapGraph(pc.DB, roadmap.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load roadmap steps"})
+		return
+	}
+
+	// Changing the availability always replaces the upcoming sessions
+	err = pc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&plan).Error; err != nil {
+			return err
+		}
+		return reschedulePlan(tx, &plan, steps, edges, time.Now())
+	})
+	if err != nil {
+		fmt.Printf("Error scheduling study plan for roadmap %d: %v\n", roadmap.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule study plan"})
+		return
+	}
+
+	pc.respondWithPlan(c, plan, steps)
+}
+
+// GetPlan returns the study plan of one of the user's roadmaps, rescheduling it if steps are late
+func (pc *StudyPlanController) GetPlan(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	roadmap, err := findOwnedRoadmap(pc.DB, c.Param("id"), userData.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
+		return
+	}
+
+	var plan models.StudyPlan
+	if err := pc.DB.Where("roadmap_id = ?", roadmap.ID).First(&plan).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This roadmap has no study plan"})
+		return
+	}
+
+	steps, err := refreshPlan(pc.DB, &plan)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reschedule study plan"})
+		return
+	}
+
+	pc.respondWithPlan(c, plan, steps)
+}
+
+// DeletePlan removes the study plan of one of the user's roadmaps
+func (pc *StudyPlanController) DeletePlan(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	roadmap, err := findOwnedRoadmap(pc.DB, c.Param("id"), userData.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
+		return
+	}
+
+	err = pc.DB.Transaction(func(tx *gorm.DB) error {
+		var plan models.StudyPlan
+		if err := tx.Where("roadmap_id = ?", roadmap.ID).First(&plan).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("plan_id = ?", plan.ID).Delete(&models.StudySession{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&plan).Error
+	})
+	if err == gorm.ErrRecordNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This roadmap has no study plan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete study plan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Study plan deleted successfully"})
+}
+
+// RotateCalendarToken issues a new secret calendar feed URL, invalidating the old one
+func (pc *StudyPlanController) RotateCalendarToken(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData := user.(models.User)
+
+	token, err := newCalendarToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar token"})
+		return
+	}
+
+	var feed models.CalendarFeed
+	pc.DB.Where("user_id = ?", userData.ID).First(&feed)
+	feed.UserID = userData.ID
+	feed.Token = token
+	if err := pc.DB.Save(&feed).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save calendar token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"feedUrl": calendarFeedURL(c, feed.Token)})
+}
+
+// GetCalendarFeed serves all of a user's study sessions as an iCalendar feed, identified by the secret token.
+// Anyone with the URL can read the feed, so it serves the sessions as scheduled and leaves
+// rescheduling to the learner's own requests.
+func (pc *StudyPlanController) GetCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	var feed models.CalendarFeed
+	if token == "" || pc.DB.Where("token = ?", token).First(&feed).Error != nil {
+		c.String(http.StatusNotFound, "Calendar not found")
+		return
+	}
+
+	var plans []models.StudyPlan
+	if err := pc.DB.Where("user_id = ?", feed.UserID).Find(&plans).Error; err != nil {
+		c.String(http.StatusInternalServerError, "Failed to load study plans")
+		return
+	}
+
+	var sessions []models.StudySession
+	descriptions := make(map[uint]string)
+	for i := range plans {
+		steps, err := loadPlanSteps(pc.DB, plans[i].RoadmapID)
+		if err != nil {
+			c.String(http.StatusInternalServerError, "Failed to load study plans")
+			return
+		}
+
+		var roadmap models.Roadmap
+		pc.DB.Select("topic").Where("id = ?", plans[i].RoadmapID).First(&roadmap)
+		for _, step := range steps {
+			descriptions[step.ID] = strings.TrimSpace(roadmap.Topic + "\n\n" + step.Description)
+		}
+
+		var planSessions []models.StudySession
+		pc.DB.Where("plan_id = ?", plans[i].ID).Order("starts_at ASC").Find(&planSessions)
+		sessions = append(sessions, planSessions...)
+	}
+
+	c.Header("Content-Disposition", `inline; filename="study-plan.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(models.StudySessionsICS("Study plan", sessions, descriptions)))
+}
+
+// respondWithPlan returns a plan with its sessions and how it compares with the target date
+func (pc *StudyPlanController) respondWithPlan(c *gin.Context, plan models.StudyPlan, steps []models.RoadmapStep) {
+	var sessions []models.StudySession
+	if err := pc.DB.Where("plan_id = ?", plan.ID).Order("starts_at ASC").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load study sessions"})
+		return
+	}
+
+	remainingHours := 0.0
+	for _, step := range steps {
+		if !step.Completed {
+			remainingHours += models.StepHours(step)
+		}
+	}
+
+	summary := gin.H{
+		"remainingHours":  remainingHours,
+		"weeklyHours":     plan.Availability.WeeklyHours(),
+		"estimatedFinish": plan.EstimatedFinish,
+	}
+	if plan.TargetDate != nil {
+		deadline := plan.TargetDate.AddDate(0, 0, 1)
+		summary["onTrack"] = plan.EstimatedFinish == nil || !plan.EstimatedFinish.After(deadline)
+
+		// Weekly hours that would be needed to finish by the target date
+		weeksLeft := time.Until(deadline).Hours() / (24 * 7)
+		if weeksLeft > 0 {
+			summary["requiredWeeklyHours"] = math.Round(remainingHours/weeksLeft*10) / 10
+		}
+	}
+
+	response := gin.H{
+		"plan":     plan,
+		"sessions": sessions,
+		"summary":  summary,
+	}
+
+	var feed models.CalendarFeed
+	if err := pc.DB.Where("user_id = ?", plan.UserID).First(&feed).Error; err == nil {
+		response["feedUrl"] = calendarFeedURL(c, feed.Token)
+	} else if token, err := newCalendarToken(); err == nil {
+		feed = models.CalendarFeed{UserID: plan.UserID, Token: token}
+		if pc.DB.Create(&feed).Error == nil {
+			response["feedUrl"] = calendarFeedURL(c, feed.Token)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// refreshPlan reschedules a plan whose upcoming sessions no longer match its roadmap and returns the roadmap steps
+func refreshPlan(db *gorm.DB, plan *models.StudyPlan) ([]models.RoadmapStep, error) {
+	steps, edges, err := loadRoadmapGraph(db, plan.RoadmapID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []models.StudySession
+	if err := db.Where("plan_id = ?", plan.ID).Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !models.NeedsReschedule(sessions, steps, now) {
+		return steps, nil
+	}
+
+	fmt.Printf("INFO: Rescheduling study plan %d\n", plan.ID)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return reschedulePlan(tx, plan, steps, edges, now)
+	})
+	return steps, err
+}
+
+// reschedulePlan replaces the upcoming sessions of a plan, keeping past sessions as history
+func reschedulePlan(tx *gorm.DB, plan *models.StudyPlan, steps []models.RoadmapStep, edges []models.RoadmapGraphEdge, now time.Time) error {
+	if err := tx.Unscoped().Where("plan_id = ? AND starts_at >= ?", plan.ID, now).
+		Delete(&models.StudySession{}).Error; err != nil {
+		return err
+	}
+
+	sessions, finish := plan.ScheduleStudySessions(steps, edges, now)
+	if len(sessions) > 0 {
+		if err := tx.Create(&sessions).Error; err != nil {
+			return err
+		}
+		plan.EstimatedFinish = &finish
+	} else {
+		plan.EstimatedFinish = nil
+	}
+
+	plan.ScheduledAt = now
+	return tx.Save(plan).Error
+}
+
+// loadPlanSteps loads the steps of a roadmap in their order
+func loadPlanSteps(db *gorm.DB, roadmapID uint) ([]models.RoadmapStep, error) {
+	var steps []models.RoadmapStep
+	err := db.Where("roadmap_id = ?", roadmapID).Order("\"order\" ASC").Find(&steps).Error
+	return steps, err
+}
+
+// isWeekday reports whether a name is a lowercase weekday
+func isWeekday(name string) bool {
+	for _, day := range models.Weekdays {
+		if day == name {
+			return true
+		}
+	}
+	return false
+}
+
+// newCalendarToken creates a random secret for a calendar feed URL
+func newCalendarToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// calendarFeedURL builds the absolute URL of a calendar feed from the current request
+func calendarFeedURL(c *gin.Context, token string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	prefix := "/en"
+	if strings.HasPrefix(c.Request.URL.Path, "/ru/") {
+		prefix = "/ru"
+	}
+	return fmt.Sprintf("%s://%s%s/api/public/calendar/%s.ics", scheme, c.Request.Host, prefix, token)
+}
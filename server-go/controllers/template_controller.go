@@ -0,0 +1,387 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mentorback/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TemplateController handles publishing, browsing and forking roadmap templates
+type TemplateController struct {
+	BaseController
+}
+
+// NewTemplateController creates a new template controller
+func NewTemplateController(base BaseController) *TemplateController {
+	return &TemplateController{BaseController: base}
+}
+
+// PublishTemplateRequest represents the body for publishing a roadmap as a template
+type PublishTemplateRequest struct {
+	Title       string   `json:"title" binding:"max=200"`
+	Slug        string   `json:"slug" binding:"max=120"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags" binding:"max=10"`
+}
+
+// RateTemplateRequest represents the body for rating a template
+type RateTemplateRequest struct {
+	Rating  int    `json:"rating" binding:"required,min=1,max=5"`
+	Comment string `json:"comment"`
+}
+
+// slugUnsafeChars matches everything that is not allowed in a template slug
+var slugUnsafeChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// likeEscaper escapes the wildcards of a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+const (
+	defaultTemplatePageSize = 20
+	maxTemplatePageSize     = 50
+)
+
+// PublishRoadmap publishes a roadmap as a public template, or refreshes the template it was published as.
+// Only the learner who owns a roadmap may publish it, as the template makes it public.
+func (tc *TemplateController) PublishRoadmap(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only learners can publish their roadmaps"})
+		return
+	}
+
+	var request PublishTemplateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var roadmap models.Roadmap
+	if err := tc.DB.Where("id = ? AND user_id = ?", c.Param("id"), userData.ID).First(&roadmap).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roadmap not found"})
+		return
+	}
+
+	steps, edges, err := loadRoadmapGraph(tc.DB, roadmap.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load roadmap steps"})
+		return
+	}
+	if len(steps) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A roadmap without steps cannot be published"})
+		return
+	}
+
+	// Republishing the same roadmap updates its template instead of creating another one
+	var template models.RoadmapTemplate
+	isNew := tc.DB.Where("source_roadmap_id = ? AND author_id = ?", roadmap.ID, userData.ID).
+		First(&template).Error != nil
+
+	title := strings.TrimSpace(request.Title)
+	if title == "" {
+		title = template.Title
+	}
+	if title == "" {
+		title = roadmap.Topic
+	}
+
+	template.Title = title
+	template.Topic = roadmap.Topic
+	template.AuthorID = userData.ID
+	template.AuthorName = userData.DisplayName
+	template.SourceRoadmapID = &roadmap.ID
+	template.Snapshot = models.NewTemplateSnapshot(steps, edges)
+	template.StepCount = len(steps)
+	template.EstimatedHours = 0
+	for _, step := range steps {
+		template.EstimatedHours += step.EstimatedHours
+	}
+	if request.Description != "" || isNew {
+		template.Description = strings.TrimSpace(request.Description)
+	}
+	if request.Tags != nil || isNew {
+		template.Tags = normalizeTags(request.Tags)
+	}
+
+	if isNew || request.Slug != "" {
+		base := request.Slug
+		if base == "" {
+			base = title
+		}
+		slug, err := tc.uniqueSlug(base, template.ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		template.Slug = slug
+	}
+
+	if err := tc.DB.Save(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish roadmap"})
+		return
+	}
+
+	status := http.StatusOK
+	if isNew {
+		status = http.StatusCreated
+	}
+	c.JSON(status, templateResponse(template))
+}
+
+// ListTemplates browses and searches published templates
+func (tc *TemplateController) ListTemplates(c *gin.Context) {
+	query := tc.DB.Model(&models.RoadmapTemplate{}).Omit("snapshot")
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		// Wildcards in the search are matched literally
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(q)) + "%"
+		query = query.Where(`LOWER(title) LIKE ? ESCAPE '\' OR LOWER(topic) LIKE ? ESCAPE '\' OR LOWER(description) LIKE ? ESCAPE '\' OR ? = ANY(tags)`,
+			pattern, pattern, pattern, strings.ToLower(q))
+	}
+	if tag := strings.TrimSpace(c.Query("tag")); tag != "" {
+		query = query.Where("? = ANY(tags)", strings.ToLower(tag))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve templates"})
+		return
+	}
+
+	switch c.DefaultQuery("sort", "popular") {
+	case "rating":
+		query = query.Order("average_rating DESC").Order("rating_count DESC")
+	case "recent":
+		query = query.Order("created_at DESC")
+	default:
+		query = query.Order("fork_count DESC").Order("average_rating DESC")
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultTemplatePageSize)))
+	if limit <= 0 || limit > maxTemplatePageSize {
+		limit = defaultTemplatePageSize
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	var templates []models.RoadmapTemplate
+	if err := query.Limit(limit).Offset(offset).Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates": templates,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// GetTemplate returns a published template with its roadmap graph
+func (tc *TemplateController) GetTemplate(c *gin.Context) {
+	template, err := tc.findTemplate(c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, templateResponse(template))
+}
+
+// ForkTemplate copies a template into the caller's roadmaps with fresh progress
+func (tc *TemplateController) ForkTemplate(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only learners can fork roadmaps"})
+		return
+	}
+
+	template, err := tc.findTemplate(c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	roadmap := models.Roadmap{
+		Topic:      template.Topic,
+		UserID:     userData.ID,
+		TemplateID: &template.ID,
+	}
+	steps := template.Snapshot.RoadmapSteps()
+	edges := template.Snapshot.Edges
+
+	err = tc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveRoadmapGraph(tx, &roadmap, steps, edges); err != nil {
+			return err
+		}
+		return tx.Model(&models.RoadmapTemplate{}).Where("id = ?", template.ID).
+			UpdateColumn("fork_count", gorm.Expr("fork_count + 1")).Error
+	})
+	if err != nil {
+		fmt.Printf("Error forking template %s: %v\n", template.Slug, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fork template"})
+		return
+	}
+
+	response := roadmapResponse(roadmap.ID, steps, edges)
+	response["topic"] = roadmap.Topic
+	response["templateId"] = template.ID
+	c.JSON(http.StatusCreated, response)
+}
+
+// RateTemplate records the caller's rating of a template and updates its average
+func (tc *TemplateController) RateTemplate(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userData, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only learners can rate roadmaps"})
+		return
+	}
+
+	var request RateTemplateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := tc.findTemplate(c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	if template.AuthorID == userData.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot rate your own template"})
+		return
+	}
+
+	err = tc.DB.Transaction(func(tx *gorm.DB) error {
+		// A learner's first ratings may arrive at the same time, so the rating is an upsert
+		rating := models.RoadmapTemplateRating{
+			TemplateID: template.ID,
+			UserID:     userData.ID,
+			Rating:     request.Rating,
+			Comment:    strings.TrimSpace(request.Comment),
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "template_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rating", "comment", "updated_at", "deleted_at"}),
+		}).Create(&rating).Error; err != nil {
+			return err
+		}
+
+		// Lock the template so concurrent ratings recompute its average one after another
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").First(&models.RoadmapTemplate{}, template.ID).Error; err != nil {
+			return err
+		}
+
+		var stats struct {
+			RatingCount   int
+			AverageRating float64
+		}
+		if err := tx.Model(&models.RoadmapTemplateRating{}).
+			Select("COUNT(*) as rating_count, COALESCE(AVG(rating), 0) as average_rating").
+			Where("template_id = ?", template.ID).
+			Scan(&stats).Error; err != nil {
+			return err
+		}
+
+		template.RatingCount = stats.RatingCount
+		template.AverageRating = stats.AverageRating
+		return tx.Model(&models.RoadmapTemplate{}).Where("id = ?", template.ID).Updates(map[string]interface{}{
+			"rating_count":   stats.RatingCount,
+			"average_rating": stats.AverageRating,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rate template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rating":        request.Rating,
+		"ratingCount":   template.RatingCount,
+		"averageRating": template.AverageRating,
+	})
+}
+
+// findTemplate loads a template by slug
+func (tc *TemplateController) findTemplate(slug string) (models.RoadmapTemplate, error) {
+	var template models.RoadmapTemplate
+	err := tc.DB.Where("slug = ?", strings.ToLower(slug)).First(&template).Error
+	return template, err
+}
+
+// uniqueSlug turns text into a slug that no other template uses, adding a number if needed
+func (tc *TemplateController) uniqueSlug(text string, templateID uint) (string, error) {
+	base := strings.Trim(slugUnsafeChars.ReplaceAllString(strings.ToLower(text), "-"), "-")
+	if len(base) > 100 {
+		base = strings.Trim(base[:100], "-")
+	}
+	if base == "" {
+		return "", fmt.Errorf("slug must contain letters or digits")
+	}
+
+	slug := base
+	for n := 2; ; n++ {
+		var count int64
+		if err := tc.DB.Unscoped().Model(&models.RoadmapTemplate{}).
+			Where("slug = ? AND id <> ?", slug, templateID).
+			Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// templateResponse returns a template together with the graph of its roadmap
+func templateResponse(template models.RoadmapTemplate) gin.H {
+	return gin.H{
+		"template": template,
+		"graph":    models.BuildRoadmapGraph(template.Snapshot.RoadmapSteps(), template.Snapshot.Edges),
+	}
+}
+
+// normalizeTags lowercases, trims and deduplicates tags
+func normalizeTags(tags []string) pq.StringArray {
+	seen := make(map[string]bool, len(tags))
+	normalized := make(pq.StringArray, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		if tag == "" || len(tag) > 50 || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mentorback/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// TopicController handles the topic catalog
+type TopicController struct {
+	BaseController
+}
+
+// NewTopicController creates a new topic controller
+func NewTopicController(base BaseController) *TopicController {
+	return &TopicController{BaseController: base}
+}
+
+const (
+	defaultTopicPageSize = 50
+	maxTopicPageSize     = 200
+)
+
+// UpdateTopicRequest represents the body for curating a catalog topic
+type UpdateTopicRequest struct {
+	Name    *string  `json:"name"`
+	Aliases []string `json:"aliases"` // Replaces the aliases when set
+	Tags    []string `json:"tags"`    // Replaces the tags when set
+	Parent  *string  `json:"parent"`  // Slug of the parent topic, or "" to make it a top-level topic
+}
+
+// ListTopics lists catalog topics, optionally filtered by search text, tag or parent
+func (tc *TopicController) ListTopics(c *gin.Context) {
+	query := tc.DB.Model(&models.Topic{})
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(name) LIKE ? OR slug LIKE ? OR ? = ANY(aliases)", pattern, pattern, models.TopicSlug(q))
+	}
+	if tag := strings.TrimSpace(c.Query("tag")); tag != "" {
+		query = query.Where("? = ANY(tags)", strings.ToLower(tag))
+	}
+	if parent := strings.TrimSpace(c.Query("parent")); parent != "" {
+		parentTopic, ok, err := models.FindTopic(tc.DB, parent)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve topics"})
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Parent topic not found"})
+			return
+		}
+		query = query.Where("parent_id = ?", parentTopic.ID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve topics"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultTopicPageSize)))
+	if limit <= 0 || limit > maxTopicPageSize {
+		limit = defaultTopicPageSize
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	var topics []models.Topic
+	if err := query.Order("name ASC").Limit(limit).Offset(offset).Find(&topics).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve topics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"topics": topics,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GetTopic returns a catalog topic with its parent and children. The slug may also be an alias.
+func (tc *TopicController) GetTopic(c *gin.Context) {
+	topic, err := tc.findTopic(c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Topic not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"topic": topic})
+}
+
+// ResolveTopic returns the catalog topic a free-text name refers to, without adding it to the catalog
+func (tc *TopicController) ResolveTopic(c *gin.Context) {
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name parameter is required"})
+		return
+	}
+
+	topic, ok, err := models.FindTopic(tc.DB, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve topic"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No topic matches this name"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"topic": topic})
+}
+
+// UpdateTopic lets admins curate a catalog topic: its name, aliases, tags and parent
+func (tc *TopicController) UpdateTopic(c *gin.Context) {
+	var request UpdateTopicRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	topic, err := tc.findTopic(c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Topic not found"})
+		return
+	}
+
+	if request.Name != nil {
+		name := models.TopicName(*request.Name)
+		if name == "" || len([]rune(name)) > 200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name must be between 1 and 200 characters"})
+			return
+		}
+		topic.Name = name
+	}
+
+	if request.Aliases != nil {
+		aliases := make(pq.StringArray, 0, len(request.Aliases))
+		seen := map[string]bool{topic.Slug: true}
+		for _, alias := range request.Aliases {
+			slug := models.TopicSlug(alias)
+			if slug == "" || seen[slug] {
+				continue
+			}
+			seen[slug] = true
+			aliases = append(aliases, slug)
+		}
+
+		// An alias must not point to two topics
+		var conflicts []models.Topic
+		if len(aliases) > 0 {
+			tc.DB.Where("id <> ? AND (slug IN ? OR aliases && ?)", topic.ID, []string(aliases), aliases).Find(&conflicts)
+		}
+		if len(conflicts) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "An alias is already used by topic " + conflicts[0].Slug})
+			return
+		}
+		topic.Aliases = aliases
+	}
+
+	if request.Tags != nil {
+		topic.Tags = normalizeTags(request.Tags)
+	}
+
+	if request.Parent != nil {
+		if *request.Parent == "" {
+			topic.ParentID = nil
+		} else {
+			parent, err := tc.findTopic(*request.Parent)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Parent topic not found"})
+				return
+			}
+			if tc.isDescendant(parent, topic.ID) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A topic cannot be nested under itself or one of its subtopics"})
+				return
+			}
+			topic.ParentID = &parent.ID
+		}
+	}
+
+	topic.Parent = nil
+	topic.Children = nil
+	if err := tc.DB.Save(&topic).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update topic"})
+		return
+	}
+
+	updated, err := tc.findTopic(topic.Slug)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load topic"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"topic": updated})
+}
+
+// findTopic loads a topic by its slug or an alias, with its parent and children
+func (tc *TopicController) findTopic(slug string) (models.Topic, error) {
+	slug = models.TopicSlug(slug)
+	var topics []models.Topic
+	if err := tc.DB.Preload("Parent").
+		Preload("Children", func(db *gorm.DB) *gorm.DB { return db.Order("name ASC") }).
+		Where("slug = ? OR ? = ANY(aliases)", slug, slug).
+		Order("id ASC").
+		Find(&topics).Error; err != nil {
+		return models.Topic{}, err
+	}
+	for _, topic := range topics {
+		if topic.Slug == slug {
+			return topic, nil
+		}
+	}
+	if len(topics) == 0 {
+		return models.Topic{}, gorm.ErrRecordNotFound
+	}
+	return topics[0], nil
+}
+
+// isDescendant reports whether a topic is the topic with the given ID or nested under it
+func (tc *TopicController) isDescendant(topic models.Topic, ancestorID uint) bool {
+	seen := make(map[uint]bool)
+	for {
+		if topic.ID == ancestorID {
+			return true
+		}
+		if topic.ParentID == nil || seen[topic.ID] {
+			return false
+		}
+		seen[topic.ID] = true
+		var parent models.Topic
+		if err := tc.DB.First(&parent, *topic.ParentID).Error; err != nil {
+			return false
+		}
+		topic = parent
+	}
+}
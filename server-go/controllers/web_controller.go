@@ -71,7 +71,6 @@ func (wc *WebController) RegisterRoutes(router *gin.Engine) {
 	router.POST("/api/analytics/topic-completion", wc.AnalyticsController.TrackTopicCompletion)
 	router.POST("/api/analytics/exercise-activity", wc.AnalyticsController.TrackExerciseActivity)
 	router.POST("/api/analytics/rate-topic", wc.AnalyticsController.RateTopic)
-	router.GET("/api/analytics/global", wc.AnalyticsController.GetGlobalAnalytics)
 }
 
 // PersonalizedContent forwards to the content controller
@@ -148,8 +147,3 @@ func (wc *WebController) TrackExerciseActivity(c *gin.Context) {
 func (wc *WebController) RateTopic(c *gin.Context) {
 	wc.AnalyticsController.RateTopic(c)
 }
-
-// GetGlobalAnalytics forwards to the analytics controller
-func (wc *WebController) GetGlobalAnalytics(c *gin.Context) {
-	wc.AnalyticsController.GetGlobalAnalytics(c)
-}
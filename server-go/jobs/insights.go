@@ -0,0 +1,238 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"mentorback/llm"
+	"mentorback/models"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Schedule of the weekly insight reports
+const (
+	insightsInterval          = time.Hour // Weeks end at different times in different timezones
+	insightsBatchSize         = 50
+	maxInsightRecommendations = 5
+	insightsAttemptTimeout    = 10 * time.Minute // How long a claimed report may take before it can be claimed again
+	insightsRetryDelay        = time.Hour        // Wait after a failed report, doubled after every further failure
+	maxInsightsRetryDelay     = 24 * time.Hour
+)
+
+// WeeklyInsights writes every active learner a report on their last week once it is over in their
+// timezone, unless they opted out
+type WeeklyInsights struct {
+	DB *gorm.DB
+	// Generate sends a prompt to the LLM and returns its answer
+	Generate func(prompt string) (string, error)
+}
+
+// NewWeeklyInsights creates the weekly insights job, generating reports with the LLM the
+// controllers use
+func NewWeeklyInsights(db *gorm.DB) *WeeklyInsights {
+	return &WeeklyInsights{DB: db, Generate: llm.CallOpenAI}
+}
+
+// Start writes the due reports right away and then every hour until the context is done
+func (w *WeeklyInsights) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(insightsInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := w.RunOnce(ctx); err != nil {
+				log.Printf("Weekly insights failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce writes the reports that are due. A learner's week is claimed before its report is
+// generated, so several servers can run the job at once, and a report that fails is retried after
+// a backoff that grows with every failure.
+func (w *WeeklyInsights) RunOnce(ctx context.Context) error {
+	now := time.Now()
+	var afterID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		users, err := models.PendingInsightUsers(w.DB, afterID, now, insightsBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			return nil
+		}
+
+		for _, user := range users {
+			if err := w.writeReport(user, now); err != nil {
+				log.Printf("Failed to write weekly insights for user %d: %v", user.ID, err)
+			}
+		}
+		afterID = users[len(users)-1].ID
+	}
+}
+
+// writeReport writes a user's report on their last week unless it exists, they did not learn or
+// the week is claimed by another attempt or waiting for a retry
+func (w *WeeklyInsights) writeReport(user models.User, now time.Time) error {
+	week := models.LastInsightWeek(user, now)
+
+	var existing int64
+	if err := w.DB.Model(&models.InsightReport{}).Where("user_id = ? AND week_start = ?", user.ID, week).Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
+	}
+
+	stats, err := models.LoadInsightStats(w.DB, user.ID, week, now)
+	if err != nil {
+		return err
+	}
+	if !stats.HasActivity() {
+		return nil
+	}
+
+	claimedAt := time.Now()
+	attempt, err := models.ClaimInsightWeek(w.DB, user.ID, week, claimedAt, claimedAt.Add(insightsAttemptTimeout))
+	if err != nil || attempt == 0 {
+		return err
+	}
+
+	report, err := w.generateReport(user, week, stats)
+	if err == nil {
+		err = models.SaveInsightReport(w.DB, &report)
+	}
+	if err != nil {
+		retryAfter := time.Now().Add(insightsRetryAfter(attempt))
+		if failErr := models.FailInsightWeek(w.DB, user.ID, week, retryAfter, err); failErr != nil {
+			log.Printf("Failed to record the failed weekly insights of user %d: %v", user.ID, failErr)
+		}
+		return fmt.Errorf("attempt %d, retrying after %s: %w", attempt, retryAfter.Format(time.RFC3339), err)
+	}
+	return nil
+}
+
+// insightsRetryAfter is how long to wait after the given attempt at a learner's report failed
+func insightsRetryAfter(attempt int) time.Duration {
+	delay := insightsRetryDelay
+	for i := 1; i < attempt && delay < maxInsightsRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxInsightsRetryDelay)
+}
+
+// generateReport has the LLM write a user's report on a week
+func (w *WeeklyInsights) generateReport(user models.User, week time.Time, stats models.InsightStats) (models.InsightReport, error) {
+	response, err := w.Generate(insightPrompt(user, week, stats))
+	if err != nil {
+		return models.InsightReport{}, err
+	}
+
+	var generated struct {
+		Summary         string   `json:"summary"`
+		Recommendations []string `json:"recommendations"`
+	}
+	if err := json.Unmarshal([]byte(llm.CleanupJSONResponse(response)), &generated); err != nil {
+		return models.InsightReport{}, fmt.Errorf("parsing report: %w", err)
+	}
+	generated.Summary = strings.TrimSpace(generated.Summary)
+	if generated.Summary == "" {
+		return models.InsightReport{}, fmt.Errorf("empty report returned")
+	}
+
+	recommendations := make([]string, 0, len(generated.Recommendations))
+	for _, recommendation := range generated.Recommendations {
+		if recommendation = strings.TrimSpace(recommendation); recommendation != "" {
+			recommendations = append(recommendations, recommendation)
+		}
+	}
+	if len(recommendations) > maxInsightRecommendations {
+		recommendations = recommendations[:maxInsightRecommendations]
+	}
+
+	return models.InsightReport{
+		UserID:          user.ID,
+		WeekStart:       week,
+		Summary:         generated.Summary,
+		Recommendations: pq.StringArray(recommendations),
+		Stats:           stats,
+	}, nil
+}
+
+// insightPrompt asks the LLM to interpret a learner's week
+func insightPrompt(user models.User, week time.Time, stats models.InsightStats) string {
+	days := make([]string, len(stats.Days))
+	for i, day := range stats.Days {
+		days[i] = fmt.Sprintf("- %s: %d min", day.Date.Format("Monday"), day.LearningTimeMin)
+	}
+
+	weakest := []string{"- none"}
+	if len(stats.WeakestTopics) > 0 {
+		weakest = weakest[:0]
+		for _, topic := range stats.WeakestTopics {
+			// A score of zero means the learner has no score of that kind
+			var scores []string
+			if topic.QuizScore > 0 {
+				scores = append(scores, fmt.Sprintf("quiz %.0f%%", topic.QuizScore))
+			}
+			if topic.CodeScore > 0 {
+				scores = append(scores, fmt.Sprintf("code %.0f%%", topic.CodeScore))
+			}
+			weakest = append(weakest, fmt.Sprintf("- %s (%s)", topic.TopicName, strings.Join(scores, ", ")))
+		}
+	}
+
+	stalled := []string{"- none"}
+	if len(stats.StalledRoadmaps) > 0 {
+		stalled = stalled[:0]
+		for _, roadmap := range stats.StalledRoadmaps {
+			stalled = append(stalled, fmt.Sprintf("- %s (%d of %d steps completed)", roadmap.Topic, roadmap.CompletedSteps, roadmap.TotalSteps))
+		}
+	}
+
+	interests := strings.Join(user.OnboardingData.Interests, ", ")
+	goals := strings.Join(user.OnboardingData.Goals, ", ")
+
+	return fmt.Sprintf(`You are a learning mentor writing a weekly progress report for %s.
+Their interests: %s
+Their goals: %s
+
+Their learning in the week of %s:
+- Active days: %d of 7
+- Learning time: %d minutes (the week before: %d minutes)
+- Topics viewed: %d, topics completed: %d, exercises done: %d
+
+Learning time per day:
+%s
+
+Topics with their lowest scores:
+%s
+
+Roadmaps without progress for two weeks:
+%s
+
+Write a short, encouraging, personal report of 3-4 sentences that interprets these numbers, and give 2-4 concrete recommendations for the coming week.
+Only refer to the data above; do not invent topics or numbers.
+Respond with JSON only, in this format:
+{"summary": "...", "recommendations": ["...", "..."]}`,
+		user.DisplayName, interests, goals, week.Format("January 2, 2006"),
+		stats.ActiveDays, stats.LearningTimeMin, stats.PreviousLearningMin,
+		stats.TopicsViewed, stats.TopicsCompleted, stats.ExercisesDone,
+		strings.Join(days, "\n"), strings.Join(weakest, "\n"), strings.Join(stalled, "\n"))
+}
@@ -0,0 +1,127 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"mentorback/models"
+
+	"gorm.io/gorm"
+)
+
+// Defaults of the analytics rollup
+const (
+	defaultRollupInterval = time.Minute
+	rollupBatchSize       = 200            // Users recomputed per transaction
+	rollupStaleAfter      = 24 * time.Hour // Users are recomputed at least this often, new activity or not
+	rollupRecentDays      = 8              // Platform days recomputed on every run, as events may arrive a week late
+	rollupBackfillDays    = 31             // Missing older platform days filled in per run
+)
+
+// AnalyticsRollup recomputes every user's analytics and the platform's daily aggregates from the
+// activity log on a schedule. Each run only recomputes what changed, in batches that can be
+// repeated without changing the result.
+type AnalyticsRollup struct {
+	DB       *gorm.DB
+	Interval time.Duration
+}
+
+// NewAnalyticsRollup creates the rollup job, running every ANALYTICS_ROLLUP_INTERVAL (a duration
+// such as "5m") or every minute if that is not set
+func NewAnalyticsRollup(db *gorm.DB) (*AnalyticsRollup, error) {
+	interval := defaultRollupInterval
+	if value := os.Getenv("ANALYTICS_ROLLUP_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("ANALYTICS_ROLLUP_INTERVAL must be a positive duration, got %q", value)
+		}
+		interval = parsed
+	}
+	return &AnalyticsRollup{DB: db, Interval: interval}, nil
+}
+
+// Start runs the rollup right away and then on every interval until the context is done
+func (r *AnalyticsRollup) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+
+		for {
+			if err := r.RunOnce(ctx); err != nil {
+				log.Printf("Analytics rollup failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce rolls up the users with new activity and the recent and missing platform days. It does
+// nothing if another server is already rolling up.
+func (r *AnalyticsRollup) RunOnce(ctx context.Context) error {
+	_, err := models.WithRollupLock(r.DB, func(conn *gorm.DB) error {
+		if err := r.rollupUsers(ctx, conn); err != nil {
+			return err
+		}
+		return r.rollupPlatform(ctx, conn)
+	})
+	return err
+}
+
+// rollupUsers recomputes the analytics of every user with activity since their last rollup
+func (r *AnalyticsRollup) rollupUsers(ctx context.Context, db *gorm.DB) error {
+	// Activity logged while a batch runs is newer than the batch's start, so the next run picks it up
+	start := time.Now()
+	var afterID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		ids, err := models.PendingAnalyticsRollups(db, afterID, start.Add(-rollupStaleAfter), rollupBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := models.RollupUserAnalytics(db, ids, time.Now()); err != nil {
+			return fmt.Errorf("rolling up users %d to %d: %w", ids[0], ids[len(ids)-1], err)
+		}
+		afterID = ids[len(ids)-1]
+	}
+}
+
+// rollupPlatform recomputes the last days of platform activity and fills in older days that were
+// never rolled up
+func (r *AnalyticsRollup) rollupPlatform(ctx context.Context, db *gorm.DB) error {
+	today := models.ActivityDay(time.Now(), time.UTC)
+	firstRecent := today.AddDate(0, 0, 1-rollupRecentDays)
+
+	missing, err := models.MissingPlatformDays(db, firstRecent, rollupBackfillDays)
+	if err != nil {
+		return err
+	}
+
+	days := missing
+	for day := firstRecent; !day.After(today); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	for _, day := range days {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := models.RollupPlatformDay(db, day); err != nil {
+			return fmt.Errorf("rolling up %s: %w", day.Format("2006-01-02"), err)
+		}
+	}
+	return nil
+}
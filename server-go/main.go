@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"mentorback/config"
+	"mentorback/jobs"
+	"mentorback/migrations"
 	"mentorback/models"
 	"mentorback/routes"
 
@@ -27,13 +32,41 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	// Auto-migrate the database
+	// "migrate" manages the migrations instead of starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	// Apply pending migrations
 	if err := config.MigrateDatabase(db); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
-	// Fix existing null values in OnboardingData
-	fixNullValues(db)
+	// Create the first admin from the environment if there is none
+	if err := models.EnsureSuperAdmin(db); err != nil {
+		log.Printf("Warning: Failed to create admin: %v", err)
+	}
+
+	// "rollup" recomputes the analytics rollups once instead of starting the server
+	rollup, err := jobs.NewAnalyticsRollup(db)
+	if err != nil {
+		log.Fatalf("Failed to configure analytics rollup: %v", err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rollup" {
+		if err := rollup.RunOnce(context.Background()); err != nil {
+			log.Fatalf("Analytics rollup failed: %v", err)
+		}
+		return
+	}
+
+	// Keep the analytics rollups up to date in the background
+	rollup.Start(context.Background())
+
+	// Write learners their weekly insight reports in the background
+	jobs.NewWeeklyInsights(db).Start(context.Background())
 
 	// Set up Gin router
 	router := gin.Default()
@@ -63,6 +96,7 @@ func main() {
 	routes.RegisterOnboardingRoutes(router, db)
 	routes.RegisterWebRoutes(router, db)
 	routes.RegisterRuWebRoutes(router, db)
+	routes.RegisterAdminRoutes(router, db)
 
 	// Get port from environment variable
 	port := os.Getenv("PORT")
@@ -77,29 +111,52 @@ func main() {
 	}
 }
 
-// fixNullValues fixes null values in OnboardingData for existing users
-func fixNullValues(db *gorm.DB) {
-	var users []models.User
-	result := db.Find(&users)
-	if result.Error != nil {
-		log.Printf("Error fetching users to fix null values: %v", result.Error)
-		return
+// runMigrate runs the migrate subcommand:
+//
+//	migrate up            apply every pending migration
+//	migrate down [steps]  roll back the last migration, or the given number of them
+//	migrate status        list the migrations and when they were applied
+func runMigrate(db *gorm.DB, args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
 	}
 
-	for _, user := range users {
-		// Ensure empty slices are initialized
-		if user.OnboardingData.Interests == nil {
-			user.OnboardingData.Interests = []string{}
+	switch command {
+	case "up":
+		applied, err := migrations.Up(db)
+		if err != nil {
+			return err
 		}
-		if user.OnboardingData.Goals == nil {
-			user.OnboardingData.Goals = []string{}
+		log.Printf("Applied %d migrations", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("steps must be a positive number, got %q", args[1])
+			}
+			steps = n
 		}
-
-		// Save the updated user
-		if err := db.Save(&user).Error; err != nil {
-			log.Printf("Error fixing null values for user %d: %v", user.ID, err)
+		rolledBack, err := migrations.Down(db, steps)
+		if err != nil {
+			return err
+		}
+		log.Printf("Rolled back %d migrations", rolledBack)
+	case "status":
+		statuses, err := migrations.List(db)
+		if err != nil {
+			return err
 		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d  %-30s  %s\n", status.Version, status.Name, applied)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", command)
 	}
-
-	log.Printf("Fixed null values for %d users", len(users))
+	return nil
 }
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"mentorback/models"
+)
+
+// AdminOnly middleware restricts routes to admins only
+func AdminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get the user type from context
+		userType, exists := c.Get("userType")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			c.Abort()
+			return
+		}
+
+		// Check if the user is an admin
+		admin, exists := c.Get("admin")
+		if userType != "admin" || !exists {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Admin privileges required"})
+			c.Abort()
+			return
+		}
+		if _, ok := admin.(models.Admin); !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Invalid admin data"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -17,7 +17,7 @@ import (
 // JWTClaims represents the claims in a JWT token
 type JWTClaims struct {
 	UserID uint   `json:"userId"`
-	Type   string `json:"type"` // "user" or "mentor"
+	Type   string `json:"type"` // "user", "mentor" or "admin"
 	jwt.RegisteredClaims
 }
 
@@ -78,6 +78,19 @@ func Auth(db *gorm.DB) gin.HandlerFunc {
 			c.Set("user", mentor) // For backward compatibility
 			c.Set("mentor", mentor)
 			c.Set("userType", "mentor")
+		} else if userType == "admin" {
+			// Find the admin in the database
+			var admin models.Admin
+			result := db.First(&admin, claims.UserID)
+			if result.Error != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin not found"})
+				c.Abort()
+				return
+			}
+
+			// Admins are not users, so they are only set as admin
+			c.Set("admin", admin)
+			c.Set("userType", "admin")
 		} else {
 			// Find the user in the database
 			var user models.User
@@ -158,6 +171,20 @@ func OptionalAuth(db *gorm.DB) gin.HandlerFunc {
 			c.Set("user", mentor) // For backward compatibility
 			c.Set("mentor", mentor)
 			c.Set("userType", "mentor")
+		} else if userType == "admin" {
+			// Find the admin in the database
+			var admin models.Admin
+			result := db.First(&admin, claims.UserID)
+			if result.Error != nil {
+				// Admin not found, continue without authentication
+				fmt.Printf("WARNING: Admin not found for optional auth: %v\n", result.Error)
+				c.Next()
+				return
+			}
+
+			// Admins are not users, so they are only set as admin
+			c.Set("admin", admin)
+			c.Set("userType", "admin")
 		} else {
 			// Find the user in the database
 			var user models.User
@@ -219,7 +246,7 @@ func GenerateToken(userID uint, userType string) (string, error) {
 	// Set token claims
 	claims := JWTClaims{
 		UserID: userID,
-		Type:   userType, // "user" or "mentor"
+		Type:   userType, // "user", "mentor" or "admin"
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 30)), // 30 days
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"mentorback/models"
+
+	"gorm.io/gorm"
+)
+
+// dataMigrations are the migrations that move data with Go code. Those that replace data cannot be
+// rolled back, so their Down is nil and rolling back stops at them; their comments say what is lost.
+var dataMigrations = []Migration{
+	{
+		// Move topic progress out of the former per-user JSONB map. Irreversible: the map and the
+		// counters next to it are dropped.
+		Version: 4,
+		Name:    "topic_progress_table",
+		Up:      models.MigrateTopicProgressMap,
+	},
+	{
+		// Resolve free-text topics of existing rows against the topic catalog. Irreversible: rows
+		// of a user about the same topic are merged and take the canonical topic name.
+		Version: 5,
+		Name:    "topic_catalog",
+		Up:      models.MigrateTopicCatalog,
+	},
+	{
+		// Give topic progress recorded before the event log a history. The progress rows are
+		// derived from the events and do not change, so rolling back only removes the events.
+		Version: 6,
+		Name:    "progress_events",
+		Up:      models.MigrateProgressEvents,
+		Down: func(tx *gorm.DB) error {
+			return tx.Where("source = ?", "migration").Delete(&models.ProgressEvent{}).Error
+		},
+	},
+	{
+		// Rebuild daily activity from the activity logs on the days of the users' timezones and
+		// recompute their streaks. Irreversible: the days the activity was bucketed into before are
+		// not kept.
+		Version: 7,
+		Name:    "activity_days",
+		Up:      models.MigrateActivityDays,
+	},
+}
@@ -0,0 +1,276 @@
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sql/*.sql
+var sqlFiles embed.FS
+
+// lockKey is the Postgres advisory lock held while migrating, so servers booting at the same time
+// apply each migration once
+const lockKey = 4820250418
+
+// Migration is one versioned change to the database
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error // Nil when the migration cannot be rolled back
+}
+
+// SchemaMigration records a migration that was applied
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"size:200;not null" json:"name"`
+	AppliedAt time.Time `gorm:"not null" json:"appliedAt"`
+}
+
+// TableName overrides the table name
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status is a migration and when it was applied, if it was
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// All returns every migration ordered by version: the SQL files under sql/, named
+// <version>_<name>.up.sql with an optional .down.sql, and the data migrations written in Go
+func All() ([]Migration, error) {
+	byVersion := make(map[int]*Migration)
+	for _, m := range dataMigrations {
+		m := m
+		if _, ok := byVersion[m.Version]; ok {
+			return nil, fmt.Errorf("migration %d is defined twice", m.Version)
+		}
+		byVersion[m.Version] = &m
+	}
+
+	files, err := fs.Glob(sqlFiles, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		version, name, direction, err := parseFileName(path.Base(file))
+		if err != nil {
+			return nil, err
+		}
+		content, err := sqlFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d is named both %q and %q", version, m.Name, name)
+		}
+		if direction == "up" {
+			if m.Up != nil {
+				return nil, fmt.Errorf("migration %d is defined twice", version)
+			}
+			m.Up = execSQL(string(content))
+		} else {
+			m.Down = execSQL(string(content))
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %d %s has no up migration", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies every pending migration in order and returns how many it applied
+func Up(db *gorm.DB) (int, error) {
+	migrations, err := All()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = withLock(db, func(conn *gorm.DB) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if applied[m.Version] {
+				continue
+			}
+			fmt.Printf("INFO: Applying migration %04d %s\n", m.Version, m.Name)
+			if err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.Up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+			}); err != nil {
+				return fmt.Errorf("migration %04d %s: %w", m.Version, m.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down rolls back the given number of most recently applied migrations
+func Down(db *gorm.DB, steps int) (int, error) {
+	migrations, err := All()
+	if err != nil {
+		return 0, err
+	}
+	known := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	count := 0
+	err = withLock(db, func(conn *gorm.DB) error {
+		var applied []SchemaMigration
+		if err := conn.Order("version DESC").Limit(steps).Find(&applied).Error; err != nil {
+			return err
+		}
+		for _, record := range applied {
+			m, ok := known[record.Version]
+			if !ok {
+				return fmt.Errorf("migration %04d %s is applied but unknown to this build", record.Version, record.Name)
+			}
+			if m.Down == nil {
+				return fmt.Errorf("migration %04d %s cannot be rolled back", m.Version, m.Name)
+			}
+			fmt.Printf("INFO: Rolling back migration %04d %s\n", m.Version, m.Name)
+			if err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{}, m.Version).Error
+			}); err != nil {
+				return fmt.Errorf("migration %04d %s: %w", m.Version, m.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// List returns every migration with when it was applied
+func List(db *gorm.DB) ([]Status, error) {
+	migrations, err := All()
+	if err != nil {
+		return nil, err
+	}
+	if err := createTable(db); err != nil {
+		return nil, err
+	}
+
+	var applied []SchemaMigration
+	if err := db.Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[int]time.Time, len(applied))
+	for _, record := range applied {
+		appliedAt[record.Version] = record.AppliedAt
+	}
+
+	statuses := make([]Status, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = Status{Version: m.Version, Name: m.Name}
+		if at, ok := appliedAt[m.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// withLock runs fn on a single connection that holds the migration advisory lock. Advisory locks
+// belong to a session, so the lock and the migrations must share the connection.
+func withLock(db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	return db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", lockKey)
+
+		if err := createTable(conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+// createTable creates the table of applied migrations if it is missing
+func createTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS "schema_migrations" (
+		"version" bigint PRIMARY KEY,
+		"name" varchar(200) NOT NULL,
+		"applied_at" timestamptz NOT NULL
+	)`).Error
+}
+
+// appliedVersions returns the versions of the applied migrations
+func appliedVersions(db *gorm.DB) (map[int]bool, error) {
+	var versions []int
+	if err := db.Model(&SchemaMigration{}).Pluck("version", &versions).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]bool, len(versions))
+	for _, version := range versions {
+		applied[version] = true
+	}
+	return applied, nil
+}
+
+// parseFileName splits a migration file name such as 0001_initial_schema.up.sql
+func parseFileName(name string) (version int, migration, direction string, err error) {
+	base := strings.TrimSuffix(name, ".sql")
+	switch {
+	case strings.HasSuffix(base, ".up"):
+		direction = "up"
+	case strings.HasSuffix(base, ".down"):
+		direction = "down"
+	default:
+		return 0, "", "", fmt.Errorf("migration file %s must end in .up.sql or .down.sql", name)
+	}
+	base = strings.TrimSuffix(base, "."+direction)
+
+	prefix, migration, ok := strings.Cut(base, "_")
+	if !ok || migration == "" {
+		return 0, "", "", fmt.Errorf("migration file %s must be named <version>_<name>", name)
+	}
+	version, err = strconv.Atoi(prefix)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("migration file %s has no valid version", name)
+	}
+	return version, migration, direction, nil
+}
+
+// execSQL runs the statements of a migration file. Without arguments Postgres receives them through
+// the simple query protocol, which accepts several statements at once.
+func execSQL(statements string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Exec(statements).Error
+	}
+}
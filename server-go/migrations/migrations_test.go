@@ -0,0 +1,177 @@
+package migrations
+
+import (
+	"os"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestAllOrdersMigrations(t *testing.T) {
+	migrations, err := All()
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	if len(migrations) == 0 || migrations[0].Version != 1 || migrations[0].Name != "initial_schema" {
+		t.Fatalf("first migration is not 0001 initial_schema: %+v", migrations[0])
+	}
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			t.Errorf("migration %d comes after %d", migrations[i].Version, migrations[i-1].Version)
+		}
+	}
+}
+
+func TestParseFileName(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   int
+		migration string
+		direction string
+		wantErr   bool
+	}{
+		{name: "0001_initial_schema.up.sql", version: 1, migration: "initial_schema", direction: "up"},
+		{name: "0012_hint_usage_index.down.sql", version: 12, migration: "hint_usage_index", direction: "down"},
+		{name: "0003_learning_features.sql", wantErr: true},
+		{name: "initial_schema.up.sql", wantErr: true},
+		{name: "0000_nothing.up.sql", wantErr: true},
+		{name: "0004_.up.sql", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, migration, direction, err := parseFileName(tt.name)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got version %d", version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if version != tt.version || migration != tt.migration || direction != tt.direction {
+				t.Errorf("got %d %q %q, want %d %q %q", version, migration, direction, tt.version, tt.migration, tt.direction)
+			}
+		})
+	}
+}
+
+// TestUpFromBaselineSchema applies every migration to a database AutoMigrate set up before
+// migrations existed, with data in it. It resets the public schema of TEST_DATABASE_URL, so that
+// must point to a database used for nothing else.
+func TestUpFromBaselineSchema(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	exec := func(sql string, args ...interface{}) {
+		t.Helper()
+		if err := db.Exec(sql, args...).Error; err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	exec("DROP SCHEMA public CASCADE")
+	exec("CREATE SCHEMA public")
+
+	baseline, err := sqlFiles.ReadFile("sql/0001_initial_schema.up.sql")
+	if err != nil {
+		t.Fatalf("reading the baseline schema: %v", err)
+	}
+	exec(string(baseline))
+
+	exec(`INSERT INTO users (created_at, updated_at, username, password, onboarding_data) VALUES (now(), now(), 'ada', 'x', NULL)`)
+	exec(`INSERT INTO user_progresses (created_at, updated_at, user_id, topic_progress, total_topics, completed_topics, viewed_topics)
+		VALUES (now(), now(), 1, '{"Go Basics": {"viewed": true, "completed": true, "quizScore": 80, "codeScore": 0, "lastViewed": "2024-03-01T10:00:00Z"}}', 1, 1, 1)`)
+	exec(`INSERT INTO roadmaps (created_at, updated_at, topic, user_id) VALUES (now(), now(), 'Go', 1)`)
+	exec(`INSERT INTO roadmap_steps (created_at, updated_at, name, "order", roadmap_id, completed) VALUES (now(), now(), 'Go Basics', 1, 1, true)`)
+	exec(`INSERT INTO analytics (created_at, updated_at, user_id, topics_viewed) VALUES (now(), now(), 1, 1)`)
+	exec(`INSERT INTO activity_logs (created_at, updated_at, user_id, activity_type, topic_name, time_spent, timestamp)
+		VALUES (now(), now(), 1, 'topic-view', 'Go Basics', 20, '2024-03-01T10:00:00Z')`)
+	exec(`INSERT INTO daily_activities (created_at, updated_at, user_id, date, learning_time_min, topics_viewed)
+		VALUES (now(), now(), 1, '2024-03-01T00:00:00Z', 20, 1)`)
+
+	migrations, err := All()
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	applied, err := Up(db)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("applied %d migrations, want %d", applied, len(migrations))
+	}
+
+	columns := map[string][]string{
+		"users":         {"timezone", "insights_opt_out"},
+		"roadmaps":      {"topic_id", "template_id"},
+		"roadmap_steps": {"key", "completed_at", "mastered"},
+		"analytics":     {"longest_streak", "rolled_up_at"},
+		"activity_logs": {"topic_id"},
+	}
+	for table, names := range columns {
+		for _, name := range names {
+			if !db.Migrator().HasColumn(table, name) {
+				t.Errorf("%s.%s is missing", table, name)
+			}
+		}
+	}
+	if db.Migrator().HasColumn("user_progresses", "topic_progress") {
+		t.Error("user_progresses.topic_progress was not dropped")
+	}
+
+	var progress struct {
+		Topic     string
+		Completed bool
+		QuizScore int
+	}
+	if err := db.Table("user_topic_progress").Select("topic, completed, quiz_score").Where("user_id = ?", 1).Scan(&progress).Error; err != nil {
+		t.Fatalf("reading topic progress: %v", err)
+	}
+	if !progress.Completed || progress.QuizScore != 80 {
+		t.Errorf("topic progress was not moved: %+v", progress)
+	}
+
+	var events int64
+	if err := db.Table("progress_events").Where("user_id = ?", 1).Count(&events).Error; err != nil {
+		t.Fatalf("counting progress events: %v", err)
+	}
+	if events == 0 {
+		t.Error("no progress events were written for the moved progress")
+	}
+
+	var day struct {
+		LearningTimeMin int
+		TopicsViewed    int
+	}
+	if err := db.Table("daily_activities").Select("learning_time_min, topics_viewed").
+		Where("user_id = ? AND date = ?", 1, "2024-03-01").Scan(&day).Error; err != nil {
+		t.Fatalf("reading daily activity: %v", err)
+	}
+	if day.LearningTimeMin != 20 || day.TopicsViewed != 1 {
+		t.Errorf("daily activity was not rebuilt from the activity logs: %+v", day)
+	}
+
+	var interests string
+	if err := db.Table("users").Select("onboarding_data->>'interests'").Where("id = ?", 1).Scan(&interests).Error; err != nil {
+		t.Fatalf("reading onboarding data: %v", err)
+	}
+	if interests != "[]" {
+		t.Errorf("onboarding interests = %q, want []", interests)
+	}
+
+	applied, err = Up(db)
+	if err != nil {
+		t.Fatalf("second Up: %v", err)
+	}
+	if applied != 0 {
+		t.Errorf("second Up applied %d migrations", applied)
+	}
+}
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS "daily_activities";
+DROP TABLE IF EXISTS "activity_logs";
+DROP TABLE IF EXISTS "topic_interactions";
+DROP TABLE IF EXISTS "analytics";
+DROP TABLE IF EXISTS "user_progresses";
+DROP TABLE IF EXISTS "personalized_contents";
+DROP TABLE IF EXISTS "chat_messages";
+DROP TABLE IF EXISTS "chat_sessions";
+DROP TABLE IF EXISTS "roadmap_steps";
+DROP TABLE IF EXISTS "roadmaps";
+DROP TABLE IF EXISTS "admins";
+DROP TABLE IF EXISTS "mentors";
+DROP TABLE IF EXISTS "users";
@@ -0,0 +1,240 @@
+-- The schema as AutoMigrate created it before versioned migrations. Tables and indexes are only
+-- created if they are missing, so databases AutoMigrate set up adopt it unchanged. Everything added
+-- since is added by the migrations that follow.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "username" varchar(50) NOT NULL,
+    "email" varchar(100),
+    "phone" varchar(15),
+    "password" varchar(100) NOT NULL,
+    "display_name" varchar(100),
+    "avatar_url" varchar(255),
+    "onboarding_data" jsonb,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_phone" ON "users" ("phone");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_username" ON "users" ("username");
+
+CREATE TABLE IF NOT EXISTS "mentors" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint,
+    "username" varchar(50) NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "email" varchar(100) NOT NULL,
+    "password" varchar(100) NOT NULL,
+    "skills" text[],
+    "experience" varchar(20) NOT NULL,
+    "rating" decimal NOT NULL DEFAULT 0,
+    "reviews" bigint NOT NULL DEFAULT 0,
+    "hourly_rate" decimal NOT NULL,
+    "avatar" text,
+    "available" boolean NOT NULL DEFAULT true,
+    "bio" text,
+    "social_links" jsonb,
+    "specializations" text[],
+    "languages" text[] DEFAULT '{English}',
+    "timezone" text,
+    "verified" boolean NOT NULL DEFAULT false,
+    "display_name" varchar(100),
+    "avatar_url" varchar(255),
+    "phone" varchar(15),
+    "is_mentor" boolean NOT NULL DEFAULT true,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_mentors_deleted_at" ON "mentors" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_mentors_email" ON "mentors" ("email");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_mentors_username" ON "mentors" ("username");
+
+CREATE TABLE IF NOT EXISTS "admins" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "username" varchar(50) NOT NULL,
+    "email" varchar(100) NOT NULL,
+    "password" varchar(100) NOT NULL,
+    "role" varchar(20) NOT NULL DEFAULT 'admin',
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_admins_deleted_at" ON "admins" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_admins_email" ON "admins" ("email");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_admins_username" ON "admins" ("username");
+
+CREATE TABLE IF NOT EXISTS "roadmaps" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "topic" varchar(100) NOT NULL,
+    "user_id" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_roadmaps_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_roadmaps_deleted_at" ON "roadmaps" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "roadmap_steps" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "name" varchar(100) NOT NULL,
+    "order" bigint NOT NULL,
+    "roadmap_id" bigint,
+    "completed" boolean NOT NULL DEFAULT false,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_roadmaps_steps" FOREIGN KEY ("roadmap_id") REFERENCES "roadmaps"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_roadmap_steps_deleted_at" ON "roadmap_steps" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "chat_sessions" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint,
+    "mentor_id" bigint,
+    "type" varchar(20) NOT NULL DEFAULT 'ai',
+    "title" varchar(100),
+    "is_active" boolean NOT NULL DEFAULT true,
+    "last_access" timestamptz NOT NULL,
+    "last_message" varchar(255),
+    "unread_count" bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_chat_sessions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"),
+    CONSTRAINT "fk_chat_sessions_mentor" FOREIGN KEY ("mentor_id") REFERENCES "mentors"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_chat_sessions_deleted_at" ON "chat_sessions" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "chat_messages" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "session_id" bigint,
+    "content" text NOT NULL,
+    "sender_id" bigint,
+    "sender_type" varchar(20) NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT 'sent',
+    "timestamp" timestamptz NOT NULL,
+    "is_read" boolean NOT NULL DEFAULT false,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_chat_sessions_messages" FOREIGN KEY ("session_id") REFERENCES "chat_sessions"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_chat_messages_deleted_at" ON "chat_messages" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "personalized_contents" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint,
+    "content_type" varchar(50) NOT NULL,
+    "recommended_topics" jsonb,
+    "content" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_personalized_contents_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_personalized_contents_deleted_at" ON "personalized_contents" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "user_progresses" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "topic_progress" jsonb,
+    "total_topics" bigint,
+    "completed_topics" bigint,
+    "viewed_topics" bigint,
+    "last_activity" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_user_progresses_deleted_at" ON "user_progresses" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_user_progresses_user_id" ON "user_progresses" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "analytics" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "topics_viewed" bigint,
+    "topics_completed" bigint,
+    "total_learning_time" bigint,
+    "streak_days" bigint,
+    "last_activity_date" timestamptz,
+    "exercises_completed" bigint,
+    "exercises_attempted" bigint,
+    "average_quiz_score" decimal,
+    "average_code_score" decimal,
+    "last_topic_accessed" text,
+    "topic_completion_rate" decimal,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_analytics_deleted_at" ON "analytics" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_analytics_user_id" ON "analytics" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "topic_interactions" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "topic_name" text,
+    "view_count" bigint,
+    "time_spent" bigint,
+    "last_viewed" timestamptz,
+    "completed_at" timestamptz,
+    "rating" bigint,
+    "difficulty" bigint,
+    "quiz_score" decimal,
+    "code_score" decimal,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_topic_interactions_deleted_at" ON "topic_interactions" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_topic_interactions_topic_name" ON "topic_interactions" ("topic_name");
+CREATE INDEX IF NOT EXISTS "idx_topic_interactions_user_id" ON "topic_interactions" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "activity_logs" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "activity_type" text,
+    "description" text,
+    "topic_name" text,
+    "exercise_id" text,
+    "time_spent" bigint,
+    "score" decimal,
+    "timestamp" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_activity_logs_deleted_at" ON "activity_logs" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_activity_logs_user_id" ON "activity_logs" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "daily_activities" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "date" timestamptz,
+    "learning_time_min" bigint,
+    "topics_viewed" bigint,
+    "topics_completed" bigint,
+    "exercises_done" bigint,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_daily_activities_date" ON "daily_activities" ("date");
+CREATE INDEX IF NOT EXISTS "idx_daily_activities_deleted_at" ON "daily_activities" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_daily_activities_user_id" ON "daily_activities" ("user_id");
@@ -0,0 +1,14 @@
+-- Users saved before onboarding data was always serialized have no onboarding data or null lists,
+-- which cannot be scanned. This replaces the fix that used to re-save every user on boot.
+
+UPDATE "users"
+SET "onboarding_data" = '{"age": "", "experience": "", "interests": [], "goals": [], "learningStyle": "", "completed": false}'::jsonb
+WHERE "onboarding_data" IS NULL OR jsonb_typeof("onboarding_data") <> 'object';
+
+UPDATE "users"
+SET "onboarding_data" = jsonb_set("onboarding_data", '{interests}', '[]'::jsonb)
+WHERE jsonb_typeof(COALESCE("onboarding_data"->'interests', 'null'::jsonb)) <> 'array';
+
+UPDATE "users"
+SET "onboarding_data" = jsonb_set("onboarding_data", '{goals}', '[]'::jsonb)
+WHERE jsonb_typeof(COALESCE("onboarding_data"->'goals', 'null'::jsonb)) <> 'array';
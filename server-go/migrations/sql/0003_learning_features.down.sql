@@ -0,0 +1,35 @@
+ALTER TABLE "activity_logs" DROP COLUMN IF EXISTS "topic_id";
+ALTER TABLE "topic_interactions" DROP COLUMN IF EXISTS "topic_id";
+ALTER TABLE "analytics" DROP COLUMN IF EXISTS "longest_streak";
+DROP TABLE IF EXISTS "submission_similarities";
+DROP TABLE IF EXISTS "assessments";
+DROP TABLE IF EXISTS "exercise_reports";
+DROP TABLE IF EXISTS "hint_usages";
+DROP TABLE IF EXISTS "exercise_items";
+DROP TABLE IF EXISTS "exercise_attempts";
+DROP TABLE IF EXISTS "concept_masteries";
+DROP TABLE IF EXISTS "topic_concepts";
+DROP TABLE IF EXISTS "topic_abilities";
+DROP TABLE IF EXISTS "progress_events";
+DROP TABLE IF EXISTS "user_topic_progress";
+DROP TABLE IF EXISTS "goals";
+DROP TABLE IF EXISTS "calendar_feeds";
+DROP TABLE IF EXISTS "study_sessions";
+DROP TABLE IF EXISTS "study_plans";
+DROP TABLE IF EXISTS "roadmap_template_ratings";
+DROP TABLE IF EXISTS "roadmap_templates";
+DROP TABLE IF EXISTS "roadmap_revisions";
+DROP TABLE IF EXISTS "roadmap_step_prerequisites";
+ALTER TABLE "roadmap_steps" DROP COLUMN IF EXISTS "mastered_at";
+ALTER TABLE "roadmap_steps" DROP COLUMN IF EXISTS "mastered";
+ALTER TABLE "roadmap_steps" DROP COLUMN IF EXISTS "completed_at";
+ALTER TABLE "roadmap_steps" DROP COLUMN IF EXISTS "resources";
+ALTER TABLE "roadmap_steps" DROP COLUMN IF EXISTS "objectives";
+ALTER TABLE "roadmap_steps" DROP COLUMN IF EXISTS "estimated_hours";
+ALTER TABLE "roadmap_steps" DROP COLUMN IF EXISTS "description";
+ALTER TABLE "roadmap_steps" DROP COLUMN IF EXISTS "key";
+ALTER TABLE "roadmap_steps" ALTER COLUMN "name" TYPE varchar(100);
+ALTER TABLE "roadmaps" DROP COLUMN IF EXISTS "template_id";
+ALTER TABLE "roadmaps" DROP COLUMN IF EXISTS "topic_id";
+DROP TABLE IF EXISTS "topics";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "timezone";
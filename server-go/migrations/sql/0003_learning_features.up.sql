@@ -0,0 +1,419 @@
+-- The columns, indexes and tables added to the schema since AutoMigrate was replaced. Columns and
+-- tables are only added if they are missing, as databases AutoMigrate set up later already have some.
+
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "timezone" varchar(64) NOT NULL DEFAULT 'UTC';
+
+CREATE TABLE IF NOT EXISTS "topics" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "slug" varchar(120) NOT NULL,
+    "name" varchar(200) NOT NULL,
+    "aliases" text[],
+    "tags" text[],
+    "parent_id" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_topics_children" FOREIGN KEY ("parent_id") REFERENCES "topics"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_topics_deleted_at" ON "topics" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_topics_parent_id" ON "topics" ("parent_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_topics_slug" ON "topics" ("slug");
+
+ALTER TABLE "roadmaps" ADD COLUMN IF NOT EXISTS "topic_id" bigint;
+ALTER TABLE "roadmaps" ADD COLUMN IF NOT EXISTS "template_id" bigint;
+CREATE INDEX IF NOT EXISTS "idx_roadmaps_template_id" ON "roadmaps" ("template_id");
+CREATE INDEX IF NOT EXISTS "idx_roadmaps_topic_id" ON "roadmaps" ("topic_id");
+
+ALTER TABLE "roadmap_steps" ALTER COLUMN "name" TYPE varchar(200);
+ALTER TABLE "roadmap_steps" ADD COLUMN IF NOT EXISTS "key" varchar(50);
+ALTER TABLE "roadmap_steps" ADD COLUMN IF NOT EXISTS "description" text;
+ALTER TABLE "roadmap_steps" ADD COLUMN IF NOT EXISTS "estimated_hours" decimal;
+ALTER TABLE "roadmap_steps" ADD COLUMN IF NOT EXISTS "objectives" text[];
+ALTER TABLE "roadmap_steps" ADD COLUMN IF NOT EXISTS "resources" jsonb;
+ALTER TABLE "roadmap_steps" ADD COLUMN IF NOT EXISTS "completed_at" timestamptz;
+ALTER TABLE "roadmap_steps" ADD COLUMN IF NOT EXISTS "mastered" boolean NOT NULL DEFAULT false;
+ALTER TABLE "roadmap_steps" ADD COLUMN IF NOT EXISTS "mastered_at" timestamptz;
+
+CREATE TABLE IF NOT EXISTS "roadmap_step_prerequisites" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "roadmap_id" bigint NOT NULL,
+    "step_id" bigint NOT NULL,
+    "prerequisite_id" bigint NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_roadmap_step_prerequisites_deleted_at" ON "roadmap_step_prerequisites" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_roadmap_step_prerequisites_roadmap_id" ON "roadmap_step_prerequisites" ("roadmap_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_roadmap_step_prerequisites_edge" ON "roadmap_step_prerequisites" ("step_id","prerequisite_id");
+
+CREATE TABLE IF NOT EXISTS "roadmap_revisions" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "roadmap_id" bigint NOT NULL,
+    "user_id" bigint NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "proposal" jsonb,
+    "resolved_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_roadmap_revisions_deleted_at" ON "roadmap_revisions" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_roadmap_revisions_roadmap_id" ON "roadmap_revisions" ("roadmap_id");
+CREATE INDEX IF NOT EXISTS "idx_roadmap_revisions_user_id" ON "roadmap_revisions" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "roadmap_templates" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "slug" varchar(120) NOT NULL,
+    "title" varchar(200) NOT NULL,
+    "topic" varchar(100) NOT NULL,
+    "description" text,
+    "tags" text[],
+    "author_type" varchar(20) NOT NULL,
+    "author_id" bigint NOT NULL,
+    "author_name" varchar(100),
+    "source_roadmap_id" bigint,
+    "snapshot" jsonb,
+    "step_count" bigint NOT NULL DEFAULT 0,
+    "estimated_hours" decimal,
+    "fork_count" bigint NOT NULL DEFAULT 0,
+    "rating_count" bigint NOT NULL DEFAULT 0,
+    "average_rating" decimal NOT NULL DEFAULT 0,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_roadmap_templates_author_id" ON "roadmap_templates" ("author_id");
+CREATE INDEX IF NOT EXISTS "idx_roadmap_templates_deleted_at" ON "roadmap_templates" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_roadmap_templates_source_roadmap_id" ON "roadmap_templates" ("source_roadmap_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_roadmap_templates_slug" ON "roadmap_templates" ("slug");
+
+CREATE TABLE IF NOT EXISTS "roadmap_template_ratings" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "template_id" bigint NOT NULL,
+    "user_id" bigint NOT NULL,
+    "rating" bigint NOT NULL,
+    "comment" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_roadmap_template_ratings_deleted_at" ON "roadmap_template_ratings" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_roadmap_template_ratings_user" ON "roadmap_template_ratings" ("template_id","user_id");
+
+CREATE TABLE IF NOT EXISTS "study_plans" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "roadmap_id" bigint NOT NULL,
+    "timezone" varchar(64) NOT NULL DEFAULT 'UTC',
+    "availability" jsonb,
+    "session_start_hour" bigint NOT NULL DEFAULT 18,
+    "target_date" timestamptz,
+    "scheduled_at" timestamptz,
+    "estimated_finish" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_study_plans_deleted_at" ON "study_plans" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_study_plans_user_id" ON "study_plans" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_study_plans_roadmap_id" ON "study_plans" ("roadmap_id");
+
+CREATE TABLE IF NOT EXISTS "study_sessions" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "plan_id" bigint NOT NULL,
+    "user_id" bigint NOT NULL,
+    "roadmap_step_id" bigint NOT NULL,
+    "step_name" varchar(200),
+    "starts_at" timestamptz NOT NULL,
+    "ends_at" timestamptz NOT NULL,
+    "hours" decimal,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_study_plans_sessions" FOREIGN KEY ("plan_id") REFERENCES "study_plans"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_study_sessions_deleted_at" ON "study_sessions" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_study_sessions_plan_id" ON "study_sessions" ("plan_id");
+CREATE INDEX IF NOT EXISTS "idx_study_sessions_roadmap_step_id" ON "study_sessions" ("roadmap_step_id");
+CREATE INDEX IF NOT EXISTS "idx_study_sessions_starts_at" ON "study_sessions" ("starts_at");
+CREATE INDEX IF NOT EXISTS "idx_study_sessions_user_id" ON "study_sessions" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "calendar_feeds" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "token" varchar(64) NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_calendar_feeds_deleted_at" ON "calendar_feeds" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_calendar_feeds_token" ON "calendar_feeds" ("token");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_calendar_feeds_user_id" ON "calendar_feeds" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "goals" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "title" varchar(200) NOT NULL,
+    "type" varchar(30) NOT NULL,
+    "roadmap_id" bigint,
+    "target" decimal,
+    "start_date" timestamptz NOT NULL,
+    "deadline" timestamptz NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT 'on-track',
+    "current" decimal,
+    "progress" decimal,
+    "evaluated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_goals_deleted_at" ON "goals" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_goals_roadmap_id" ON "goals" ("roadmap_id");
+CREATE INDEX IF NOT EXISTS "idx_goals_user_id" ON "goals" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "user_topic_progress" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "topic" varchar(200) NOT NULL,
+    "topic_key" varchar(200) NOT NULL,
+    "topic_id" bigint,
+    "viewed" boolean NOT NULL DEFAULT false,
+    "completed" boolean NOT NULL DEFAULT false,
+    "completed_at" timestamptz,
+    "quiz_score" bigint NOT NULL DEFAULT 0,
+    "code_score" bigint NOT NULL DEFAULT 0,
+    "last_viewed" timestamptz,
+    "mastered" boolean NOT NULL DEFAULT false,
+    "mastered_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_user_topic_progress_completed" ON "user_topic_progress" ("completed");
+CREATE INDEX IF NOT EXISTS "idx_user_topic_progress_deleted_at" ON "user_topic_progress" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_user_topic_progress_topic_id" ON "user_topic_progress" ("topic_id");
+CREATE INDEX IF NOT EXISTS "idx_user_topic_progress_topic_key" ON "user_topic_progress" ("topic_key");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_topic_progress_topic" ON "user_topic_progress" ("user_id","topic_key");
+
+CREATE TABLE IF NOT EXISTS "progress_events" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "topic_id" bigint NOT NULL,
+    "type" varchar(20) NOT NULL,
+    "score" bigint,
+    "source" varchar(30) NOT NULL,
+    "undoes_event_id" bigint,
+    "occurred_at" timestamptz NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_progress_events_undoes_event_id" ON "progress_events" ("undoes_event_id");
+CREATE INDEX IF NOT EXISTS "idx_progress_events_user_topic" ON "progress_events" ("user_id","topic_id","occurred_at");
+
+CREATE TABLE IF NOT EXISTS "topic_abilities" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "topic" varchar(100) NOT NULL,
+    "rating" decimal NOT NULL DEFAULT 1200,
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "last_attempt_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_topic_abilities_deleted_at" ON "topic_abilities" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_topic_abilities_user_topic" ON "topic_abilities" ("user_id","topic");
+
+CREATE TABLE IF NOT EXISTS "topic_concepts" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "topic_id" bigint NOT NULL,
+    "concept" varchar(120) NOT NULL,
+    "name" varchar(200) NOT NULL,
+    "source" varchar(20) NOT NULL,
+    "assessed" boolean NOT NULL DEFAULT false,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_topic_concepts_deleted_at" ON "topic_concepts" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_topic_concepts_topic_concept" ON "topic_concepts" ("topic_id","concept");
+
+CREATE TABLE IF NOT EXISTS "concept_masteries" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "topic_id" bigint NOT NULL,
+    "concept" varchar(120) NOT NULL,
+    "p_known" decimal NOT NULL DEFAULT 0.2,
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "correct" bigint NOT NULL DEFAULT 0,
+    "last_attempt_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_concept_masteries_deleted_at" ON "concept_masteries" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_concept_masteries_topic_id" ON "concept_masteries" ("topic_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_concept_masteries_user_concept" ON "concept_masteries" ("user_id","topic_id","concept");
+
+CREATE TABLE IF NOT EXISTS "exercise_attempts" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "topic" varchar(100),
+    "exercise_id" varchar(100),
+    "exercise_item_id" bigint,
+    "type" varchar(20),
+    "difficulty" varchar(20),
+    "raw_score" decimal,
+    "hints_used" bigint,
+    "score" decimal,
+    "correct" boolean,
+    "code" text,
+    "fingerprints" bigint[],
+    "rating_before" decimal,
+    "rating_after" decimal,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_exercise_attempts_deleted_at" ON "exercise_attempts" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_exercise_attempts_exercise_item_id" ON "exercise_attempts" ("exercise_item_id");
+CREATE INDEX IF NOT EXISTS "idx_exercise_attempts_topic" ON "exercise_attempts" ("topic");
+CREATE INDEX IF NOT EXISTS "idx_exercise_attempts_user_id" ON "exercise_attempts" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "exercise_items" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "topic" varchar(100),
+    "topic_key" varchar(100),
+    "type" varchar(20) NOT NULL,
+    "difficulty" varchar(20),
+    "fingerprint" varchar(40),
+    "question" text,
+    "options" text[],
+    "correct_answer" bigint,
+    "prompt" text,
+    "starter_code" text,
+    "solution" text,
+    "explanation" text,
+    "hints" text[],
+    "tags" text[],
+    "placeholder" boolean NOT NULL DEFAULT false,
+    "times_served" bigint NOT NULL DEFAULT 0,
+    "times_answered" bigint NOT NULL DEFAULT 0,
+    "times_correct" bigint NOT NULL DEFAULT 0,
+    "report_count" bigint NOT NULL DEFAULT 0,
+    "retired" boolean NOT NULL DEFAULT false,
+    "retired_reason" varchar(100),
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_exercise_items_bank" ON "exercise_items" ("topic_key","type","difficulty");
+CREATE INDEX IF NOT EXISTS "idx_exercise_items_deleted_at" ON "exercise_items" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_exercise_items_fingerprint" ON "exercise_items" ("fingerprint");
+CREATE INDEX IF NOT EXISTS "idx_exercise_items_retired" ON "exercise_items" ("retired");
+CREATE INDEX IF NOT EXISTS "idx_exercise_items_topic" ON "exercise_items" ("topic");
+
+CREATE TABLE IF NOT EXISTS "hint_usages" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "exercise_item_id" bigint NOT NULL,
+    "hint_index" bigint,
+    "content" text,
+    "generated" boolean,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_hint_usages_deleted_at" ON "hint_usages" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_hint_usages_user_exercise" ON "hint_usages" ("user_id","exercise_item_id");
+
+CREATE TABLE IF NOT EXISTS "exercise_reports" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "exercise_item_id" bigint NOT NULL,
+    "reason" varchar(50) NOT NULL,
+    "details" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_exercise_reports_deleted_at" ON "exercise_reports" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_exercise_reports_user_item" ON "exercise_reports" ("user_id","exercise_item_id");
+
+CREATE TABLE IF NOT EXISTS "assessments" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "roadmap_id" bigint,
+    "topic" varchar(100) NOT NULL,
+    "topic_key" varchar(100),
+    "exercise_item_ids" bigint[],
+    "time_limit_sec" bigint NOT NULL,
+    "started_at" timestamptz NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "submitted_at" timestamptz,
+    "status" varchar(20) NOT NULL DEFAULT 'in-progress',
+    "passing_score" decimal NOT NULL,
+    "score" decimal,
+    "answers" jsonb,
+    "topic_scores" jsonb,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_assessments_deleted_at" ON "assessments" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_assessments_roadmap_id" ON "assessments" ("roadmap_id");
+CREATE INDEX IF NOT EXISTS "idx_assessments_topic_key" ON "assessments" ("topic_key");
+CREATE INDEX IF NOT EXISTS "idx_assessments_user_id" ON "assessments" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "submission_similarities" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "exercise_item_id" bigint NOT NULL,
+    "attempt_a_id" bigint NOT NULL,
+    "attempt_b_id" bigint NOT NULL,
+    "user_a_id" bigint NOT NULL,
+    "user_b_id" bigint NOT NULL,
+    "score" decimal NOT NULL,
+    "flagged" boolean NOT NULL DEFAULT false,
+    "review_status" varchar(20) NOT NULL DEFAULT 'pending',
+    "reviewer_type" varchar(20),
+    "reviewer_id" bigint,
+    "reviewed_at" timestamptz,
+    "review_note" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_submission_similarities_deleted_at" ON "submission_similarities" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_submission_similarities_exercise_item_id" ON "submission_similarities" ("exercise_item_id");
+CREATE INDEX IF NOT EXISTS "idx_submission_similarities_flagged" ON "submission_similarities" ("flagged");
+CREATE INDEX IF NOT EXISTS "idx_submission_similarities_user_a_id" ON "submission_similarities" ("user_a_id");
+CREATE INDEX IF NOT EXISTS "idx_submission_similarities_user_b_id" ON "submission_similarities" ("user_b_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_submission_similarities_pair" ON "submission_similarities" ("attempt_a_id","attempt_b_id");
+
+ALTER TABLE "analytics" ADD COLUMN IF NOT EXISTS "longest_streak" bigint;
+
+ALTER TABLE "topic_interactions" ADD COLUMN IF NOT EXISTS "topic_id" bigint;
+CREATE INDEX IF NOT EXISTS "idx_topic_interactions_topic_id" ON "topic_interactions" ("topic_id");
+
+ALTER TABLE "activity_logs" ADD COLUMN IF NOT EXISTS "topic_id" bigint;
+CREATE INDEX IF NOT EXISTS "idx_activity_logs_topic_id" ON "activity_logs" ("topic_id");
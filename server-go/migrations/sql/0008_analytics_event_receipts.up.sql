@@ -0,0 +1,12 @@
+-- Receipts of the analytics events clients send in batches, so a retried event is counted once.
+
+CREATE TABLE "analytics_event_receipts" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "event_id" varchar(64) NOT NULL,
+    "type" varchar(30) NOT NULL,
+    "occurred_at" timestamptz NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_analytics_event_receipts_event" ON "analytics_event_receipts" ("user_id","event_id");
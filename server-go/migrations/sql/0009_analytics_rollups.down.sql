@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "idx_activity_logs_user_updated_at";
+DROP TABLE IF EXISTS "platform_topic_daily_stats";
+DROP TABLE IF EXISTS "platform_daily_stats";
+ALTER TABLE "analytics" DROP COLUMN IF EXISTS "rolled_up_at";
@@ -0,0 +1,37 @@
+-- Rollups of the activity log: when each user's analytics were last recomputed, and the
+-- platform's activity per day and per topic and day.
+
+ALTER TABLE "analytics" ADD COLUMN IF NOT EXISTS "rolled_up_at" timestamptz;
+
+CREATE TABLE "platform_daily_stats" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "date" timestamptz NOT NULL,
+    "total_users" bigint,
+    "new_users" bigint,
+    "active_users" bigint,
+    "weekly_active_users" bigint,
+    "monthly_active_users" bigint,
+    "activities" bigint,
+    "topic_views" bigint,
+    "topic_completions" bigint,
+    "exercise_attempts" bigint,
+    "exercise_completions" bigint,
+    "learning_time_min" bigint,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_platform_daily_stats_date" ON "platform_daily_stats" ("date");
+
+CREATE TABLE "platform_topic_daily_stats" (
+    "id" bigserial,
+    "date" timestamptz NOT NULL,
+    "topic_name" text NOT NULL,
+    "views" bigint,
+    "completions" bigint,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_platform_topic_daily_stats_topic" ON "platform_topic_daily_stats" ("date","topic_name");
+
+-- Finds the users with activity since their last rollup
+CREATE INDEX IF NOT EXISTS "idx_activity_logs_user_updated_at" ON "activity_logs" ("user_id","updated_at");
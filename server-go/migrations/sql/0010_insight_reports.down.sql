@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "insight_reports";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "insights_opt_out";
@@ -0,0 +1,15 @@
+-- Weekly insight reports, and the flag learners opt out of them with.
+
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "insights_opt_out" boolean NOT NULL DEFAULT false;
+
+CREATE TABLE "insight_reports" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "week_start" timestamptz NOT NULL,
+    "summary" text NOT NULL,
+    "recommendations" text[],
+    "stats" jsonb,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_insight_reports_week" ON "insight_reports" ("user_id","week_start");
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "idx_hint_usages_user_exercise";
+CREATE INDEX "idx_hint_usages_user_exercise" ON "hint_usages" ("user_id","exercise_item_id");
@@ -0,0 +1,13 @@
+-- A hint is revealed once per learner and exercise. Concurrent requests could record the same hint
+-- twice, which counted twice in the hint penalty, so duplicates are removed before the index is
+-- made unique.
+
+DELETE FROM "hint_usages" AS "duplicate"
+USING "hint_usages" AS "first"
+WHERE "duplicate"."user_id" = "first"."user_id"
+    AND "duplicate"."exercise_item_id" = "first"."exercise_item_id"
+    AND "duplicate"."hint_index" = "first"."hint_index"
+    AND "duplicate"."id" > "first"."id";
+
+DROP INDEX IF EXISTS "idx_hint_usages_user_exercise";
+CREATE UNIQUE INDEX "idx_hint_usages_user_exercise" ON "hint_usages" ("user_id","exercise_item_id","hint_index");
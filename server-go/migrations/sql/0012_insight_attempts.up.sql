@@ -0,0 +1,15 @@
+-- Attempts at weekly insight reports that have not succeeded yet. A server claims a learner's week
+-- here before asking the LLM, so two servers do not write the same report, and a failed report is
+-- retried after a backoff instead of on every run.
+
+CREATE TABLE "insight_attempts" (
+    "id" bigserial,
+    "updated_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "week_start" timestamptz NOT NULL,
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "retry_after" timestamptz NOT NULL,
+    "last_error" text,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_insight_attempts_week" ON "insight_attempts" ("user_id","week_start");
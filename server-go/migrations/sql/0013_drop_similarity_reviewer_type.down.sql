@@ -0,0 +1,2 @@
+ALTER TABLE "submission_similarities" ADD COLUMN IF NOT EXISTS "reviewer_type" varchar(20);
+UPDATE "submission_similarities" SET "reviewer_type" = 'admin' WHERE "reviewer_id" IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Similar submissions are reviewed by admins only, so the reviewer no longer needs a type
+ALTER TABLE "submission_similarities" DROP COLUMN IF EXISTS "reviewer_type";
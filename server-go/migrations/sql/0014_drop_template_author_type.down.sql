@@ -0,0 +1,2 @@
+ALTER TABLE "roadmap_templates" ADD COLUMN IF NOT EXISTS "author_type" varchar(20) NOT NULL DEFAULT 'user';
+ALTER TABLE "roadmap_templates" ALTER COLUMN "author_type" DROP DEFAULT;
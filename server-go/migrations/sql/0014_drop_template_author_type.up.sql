@@ -0,0 +1,7 @@
+-- Templates are published by learners from their own roadmaps, so the author no longer needs a
+-- type. Any template published by a mentor is withdrawn first, as its author ID is a mentor's.
+
+UPDATE "roadmap_templates" SET "deleted_at" = NOW()
+WHERE "author_type" <> 'user' AND "deleted_at" IS NULL;
+
+ALTER TABLE "roadmap_templates" DROP COLUMN IF EXISTS "author_type";
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultAbilityRating is the starting Elo rating for a learner on a new topic
+const DefaultAbilityRating = 1200.0
+
+// TopicAbility is an Elo-style estimate of a learner's ability on a topic
+type TopicAbility struct {
+	gorm.Model
+	UserID        uint      `gorm:"not null;uniqueIndex:idx_topic_abilities_user_topic" json:"userId"`
+	Topic         string    `gorm:"size:100;not null;uniqueIndex:idx_topic_abilities_user_topic" json:"topic"`
+	Rating        float64   `gorm:"not null;default:1200" json:"rating"`
+	Attempts      int       `gorm:"not null;default:0" json:"attempts"`
+	LastAttemptAt time.Time `json:"lastAttemptAt"`
+}
+
+// NormalizeTopicKey turns a free-text topic into the key used for per-topic lookups
+func NormalizeTopicKey(topic string) string {
+	return strings.ToLower(strings.Join(strings.Fields(topic), " "))
+}
+
+// DifficultyRating maps a difficulty label to the rating of an item at that level
+func DifficultyRating(difficulty string) float64 {
+	switch strings.ToLower(strings.TrimSpace(difficulty)) {
+	case "beginner", "basic", "easy":
+		return 1000
+	case "advanced", "hard":
+		return 1400
+	case "expert":
+		return 1600
+	default:
+		return 1200
+	}
+}
+
+// DifficultyForRating picks the difficulty label closest to a learner's rating
+func DifficultyForRating(rating float64) string {
+	switch {
+	case rating < 1100:
+		return "beginner"
+	case rating < 1300:
+		return "intermediate"
+	default:
+		return "advanced"
+	}
+}
+
+// NormalizeDifficulty maps any difficulty label onto the labels used for targeting
+func NormalizeDifficulty(difficulty string) string {
+	return DifficultyForRating(DifficultyRating(difficulty))
+}
+
+// Difficulty returns the difficulty label that targets this ability
+func (a *TopicAbility) Difficulty() string {
+	return DifficultyForRating(a.Rating)
+}
+
+// ApplyAttempt updates the rating from a graded attempt against an item of the given rating.
+// score is the attempt result in the range 0-1.
+func (a *TopicAbility) ApplyAttempt(itemRating, score float64) {
+	expected := 1 / (1 + math.Pow(10, (itemRating-a.Rating)/400))
+
+	// Move quickly while the estimate is new and settle down as evidence accumulates
+	k := 40.0
+	if a.Attempts >= 30 {
+		k = 16
+	} else if a.Attempts >= 10 {
+		k = 24
+	}
+
+	a.Rating += k * (score - expected)
+	a.Attempts++
+	a.LastAttemptAt = time.Now()
+}
+
+// GetTopicAbility returns the learner's ability for a topic, or a default estimate if none is stored
+func GetTopicAbility(tx *gorm.DB, userID uint, topic string) (TopicAbility, error) {
+	ability := TopicAbility{
+		UserID: userID,
+		Topic:  NormalizeTopicKey(topic),
+		Rating: DefaultAbilityRating,
+	}
+
+	err := tx.Where("user_id = ? AND topic = ?", userID, ability.Topic).First(&ability).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return ability, err
+	}
+
+	return ability, nil
+}
+
+// UpdateTopicAbility applies a graded attempt to the learner's stored ability for a topic and also
+// returns the rating before it. The row is created if missing and locked before it is read, so
+// concurrent attempts build on each other.
+func UpdateTopicAbility(tx *gorm.DB, userID uint, topic string, itemRating, score float64) (TopicAbility, float64, error) {
+	ability := TopicAbility{UserID: userID, Topic: NormalizeTopicKey(topic), Rating: DefaultAbilityRating}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "topic"}},
+		DoNothing: true,
+	}).Create(&ability).Error; err != nil {
+		return ability, 0, err
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND topic = ?", userID, ability.Topic).
+		First(&ability).Error; err != nil {
+		return ability, 0, err
+	}
+
+	before := ability.Rating
+	ability.ApplyAttempt(itemRating, score)
+	return ability, before, tx.Save(&ability).Error
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"os"
+
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -41,27 +43,37 @@ func (a *Admin) ComparePassword(password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(a.Password), []byte(password))
 }
 
-// createSuperAdmin creates a default super admin user if none exists
-func createSuperAdmin(db *gorm.DB) error {
+// EnsureSuperAdmin creates a super admin from the ADMIN_USERNAME, ADMIN_EMAIL and ADMIN_PASSWORD
+// environment variables if no admin exists. Without ADMIN_PASSWORD no admin is created.
+func EnsureSuperAdmin(db *gorm.DB) error {
+	password := os.Getenv("ADMIN_PASSWORD")
+	if password == "" {
+		return nil
+	}
+
 	// Check if any admin exists
 	var count int64
 	if err := db.Model(&Admin{}).Count(&count).Error; err != nil {
 		return err
 	}
+	if count > 0 {
+		return nil
+	}
 
-	// If no admin exists, create a default super admin
-	if count == 0 {
-		defaultAdmin := Admin{
-			Username: "admin",
-			Email:    "admin@mentorai.com",
-			Password: "password123", // This will be hashed by the BeforeCreate hook
-			Role:     "super_admin",
-		}
-
-		if err := db.Create(&defaultAdmin).Error; err != nil {
-			return err
-		}
+	username := os.Getenv("ADMIN_USERNAME")
+	if username == "" {
+		username = "admin"
+	}
+	email := os.Getenv("ADMIN_EMAIL")
+	if email == "" {
+		email = "admin@mentorai.com"
 	}
 
-	return nil
+	admin := Admin{
+		Username: username,
+		Email:    email,
+		Password: password, // This will be hashed by the BeforeCreate hook
+		Role:     "super_admin",
+	}
+	return db.Create(&admin).Error
 }
@@ -6,21 +6,24 @@ import (
 	"gorm.io/gorm"
 )
 
-// Analytics represents aggregated user learning statistics
+// Analytics represents aggregated user learning statistics. The counters are rolled up from the
+// activity log by a background job rather than maintained by the tracking endpoints.
 type Analytics struct {
 	gorm.Model
-	UserID              uint      `gorm:"index;not null" json:"userId"`
-	TopicsViewed        int       `json:"topicsViewed"`
-	TopicsCompleted     int       `json:"topicsCompleted"`
-	TotalLearningTime   int       `json:"totalLearningTime"` // In minutes
-	StreakDays          int       `json:"streakDays"`
-	LastActivityDate    time.Time `json:"lastActivityDate"`
-	ExercisesCompleted  int       `json:"exercisesCompleted"`
-	ExercisesAttempted  int       `json:"exercisesAttempted"`
-	AverageQuizScore    float64   `json:"averageQuizScore"` // Percentage from 0-100
-	AverageCodeScore    float64   `json:"averageCodeScore"` // Percentage from 0-100
-	LastTopicAccessed   string    `json:"lastTopicAccessed"`
-	TopicCompletionRate float64   `json:"topicCompletionRate"` // Percentage from 0-100
+	UserID              uint       `gorm:"index;not null" json:"userId"`
+	TopicsViewed        int        `json:"topicsViewed"`      // Distinct topics viewed or completed
+	TopicsCompleted     int        `json:"topicsCompleted"`   // Distinct topics completed
+	TotalLearningTime   int        `json:"totalLearningTime"` // In minutes
+	StreakDays          int        `json:"streakDays"`        // Consecutive days with activity up to today, recomputed from DailyActivity
+	LongestStreak       int        `json:"longestStreak"`     // Longest run of consecutive days with activity
+	LastActivityDate    time.Time  `json:"lastActivityDate"`
+	ExercisesCompleted  int        `json:"exercisesCompleted"`
+	ExercisesAttempted  int        `json:"exercisesAttempted"`
+	AverageQuizScore    float64    `json:"averageQuizScore"` // Percentage from 0-100
+	AverageCodeScore    float64    `json:"averageCodeScore"` // Percentage from 0-100
+	LastTopicAccessed   string     `json:"lastTopicAccessed"`
+	TopicCompletionRate float64    `json:"topicCompletionRate"`  // Percentage from 0-100
+	RolledUpAt          *time.Time `json:"rolledUpAt,omitempty"` // When the counters were last recomputed from the activity log
 }
 
 // TopicInteraction tracks detailed user interactions with a specific topic
@@ -28,6 +31,7 @@ type TopicInteraction struct {
 	gorm.Model
 	UserID      uint      `gorm:"index;not null" json:"userId"`
 	TopicName   string    `gorm:"index" json:"topicName"`
+	TopicID     *uint     `gorm:"index" json:"topicId,omitempty"`
 	ViewCount   int       `json:"viewCount"`
 	TimeSpent   int       `json:"timeSpent"` // In minutes
 	LastViewed  time.Time `json:"lastViewed,omitempty"`
@@ -45,6 +49,7 @@ type ActivityLog struct {
 	ActivityType string    `json:"activityType"` // "topic-view", "topic-completion", "exercise", etc.
 	Description  string    `json:"description"`
 	TopicName    string    `json:"topicName,omitempty"`
+	TopicID      *uint     `gorm:"index" json:"topicId,omitempty"`
 	ExerciseID   string    `json:"exerciseId,omitempty"`
 	TimeSpent    int       `json:"timeSpent,omitempty"` // In minutes
 	Score        float64   `json:"score,omitempty"`     // Score achieved (for exercises)
@@ -55,11 +60,22 @@ type ActivityLog struct {
 type DailyActivity struct {
 	gorm.Model
 	UserID          uint      `gorm:"index;not null" json:"userId"`
-	Date            time.Time `gorm:"index" json:"date"`
-	LearningTimeMin int       `json:"learningTimeMin"` // Minutes spent learning
-	TopicsViewed    int       `json:"topicsViewed"`    // Number of topics viewed
-	TopicsCompleted int       `json:"topicsCompleted"` // Number of topics completed
-	ExercisesDone   int       `json:"exercisesDone"`   // Number of exercises completed
+	Date            time.Time `gorm:"index" json:"date"` // The day in the user's timezone, stored as midnight UTC
+	LearningTimeMin int       `json:"learningTimeMin"`   // Minutes spent learning
+	TopicsViewed    int       `json:"topicsViewed"`      // Number of topics viewed
+	TopicsCompleted int       `json:"topicsCompleted"`   // Number of topics completed
+	ExercisesDone   int       `json:"exercisesDone"`     // Number of exercises completed
+}
+
+// AnalyticsEventReceipt records an analytics event the client sent by the ID the client gave it,
+// so the event is only counted once however often it is sent
+type AnalyticsEventReceipt struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UserID     uint      `gorm:"not null;uniqueIndex:idx_analytics_event_receipts_event" json:"userId"`
+	EventID    string    `gorm:"size:64;not null;uniqueIndex:idx_analytics_event_receipts_event" json:"eventId"`
+	Type       string    `gorm:"size:30;not null" json:"type"`
+	OccurredAt time.Time `gorm:"not null" json:"occurredAt"`
 }
 
 // AnalyticsResponse structures the response for the analytics API
@@ -72,12 +88,14 @@ type AnalyticsResponse struct {
 
 // GlobalAnalyticsResponse structures the response for global platform-wide analytics
 type GlobalAnalyticsResponse struct {
-	TotalUsers            int      `json:"totalUsers"`
-	ActiveUsersToday      int      `json:"activeUsersToday"`
-	ActiveUsersThisWeek   int      `json:"activeUsersThisWeek"`
-	TotalTopicsViewed     int      `json:"totalTopicsViewed"`
-	TotalTopicsCompleted  int      `json:"totalTopicsCompleted"`
-	AverageCompletionRate float64  `json:"averageCompletionRate"`
-	MostPopularTopics     []string `json:"mostPopularTopics"`
-	AverageTimePerSession int      `json:"averageTimePerSession"` // In minutes
+	TotalUsers            int       `json:"totalUsers"`
+	ActiveUsersToday      int       `json:"activeUsersToday"`
+	ActiveUsersThisWeek   int       `json:"activeUsersThisWeek"`
+	ActiveUsersThisMonth  int       `json:"activeUsersThisMonth"`
+	TotalTopicsViewed     int       `json:"totalTopicsViewed"`
+	TotalTopicsCompleted  int       `json:"totalTopicsCompleted"`
+	AverageCompletionRate float64   `json:"averageCompletionRate"`
+	MostPopularTopics     []string  `json:"mostPopularTopics"`
+	AverageTimePerSession int       `json:"averageTimePerSession"` // In minutes
+	ComputedAt            time.Time `json:"computedAt"`            // When the rollups were last brought up to date
 }
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// AssessmentStatus represents the state of an assessment attempt
+type AssessmentStatus string
+
+const (
+	AssessmentStatusInProgress AssessmentStatus = "in-progress" // Started and accepting answers
+	AssessmentStatusPassed     AssessmentStatus = "passed"      // Submitted with a passing score
+	AssessmentStatusFailed     AssessmentStatus = "failed"      // Submitted below the passing score
+	AssessmentStatusExpired    AssessmentStatus = "expired"     // Not submitted within the time limit
+)
+
+// AssessmentAnswers maps exercise item IDs to the selected option index
+type AssessmentAnswers map[string]int
+
+// Value implements the driver.Valuer interface for database serialization
+func (a AssessmentAnswers) Value() (driver.Value, error) {
+	return json.Marshal(a)
+}
+
+// Scan implements the sql.Scanner interface for database deserialization
+func (a *AssessmentAnswers) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal AssessmentAnswers value: %v", value)
+	}
+
+	if len(bytes) == 0 {
+		*a = make(AssessmentAnswers)
+		return nil
+	}
+
+	return json.Unmarshal(bytes, a)
+}
+
+// TopicScores maps topic names to the percentage scored on their questions
+type TopicScores map[string]float64
+
+// Value implements the driver.Valuer interface for database serialization
+func (t TopicScores) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+// Scan implements the sql.Scanner interface for database deserialization
+func (t *TopicScores) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal TopicScores value: %v", value)
+	}
+
+	if len(bytes) == 0 {
+		*t = make(TopicScores)
+		return nil
+	}
+
+	return json.Unmarshal(bytes, t)
+}
+
+// Assessment is a timed exam on a topic or a roadmap, graded on the server
+type Assessment struct {
+	gorm.Model
+	UserID          uint              `gorm:"index;not null" json:"userId"`
+	RoadmapID       *uint             `gorm:"index" json:"roadmapId,omitempty"`
+	Topic           string            `gorm:"size:100;not null" json:"topic"`
+	TopicKey        string            `gorm:"size:100;index" json:"-"`
+	ExerciseItemIDs pq.Int64Array     `gorm:"type:bigint[]" json:"exerciseItemIds"`
+	TimeLimitSec    int               `gorm:"not null" json:"timeLimitSec"`
+	StartedAt       time.Time         `gorm:"not null" json:"startedAt"`
+	ExpiresAt       time.Time         `gorm:"not null" json:"expiresAt"`
+	SubmittedAt     *time.Time        `json:"submittedAt,omitempty"`
+	Status          AssessmentStatus  `gorm:"size:20;not null;default:'in-progress'" json:"status"`
+	PassingScore    float64           `gorm:"not null" json:"passingScore"`
+	Score           float64           `json:"score"` // Percentage from 0-100
+	Answers         AssessmentAnswers `gorm:"type:jsonb" json:"answers,omitempty"`
+	TopicScores     TopicScores       `gorm:"type:jsonb" json:"topicScores,omitempty"`
+}
+
+// BeforeSave keeps the topic lookup key in sync with the topic
+func (a *Assessment) BeforeSave(tx *gorm.DB) error {
+	a.TopicKey = NormalizeTopicKey(a.Topic)
+	return nil
+}
+
+// IsExpired reports whether the assessment can no longer be submitted, allowing a grace period for latency
+func (a *Assessment) IsExpired(now time.Time, grace time.Duration) bool {
+	return now.After(a.ExpiresAt.Add(grace))
+}
+
+// ActiveAssessmentItems returns which of the given exercises are questions of an assessment that
+// can still be submitted at now, allowing the grace period. Their answers must not be served
+// anywhere else until it closes.
+func ActiveAssessmentItems(db *gorm.DB, ids []uint, now time.Time, grace time.Duration) (map[uint]bool, error) {
+	active := make(map[uint]bool)
+	if len(ids) == 0 {
+		return active, nil
+	}
+
+	var activeIDs []uint
+	err := db.Raw(`
+		SELECT DISTINCT item_id
+		FROM assessments, unnest(exercise_item_ids) AS item_id
+		WHERE status = ? AND expires_at > ? AND deleted_at IS NULL AND item_id IN ?
+	`, AssessmentStatusInProgress, now.Add(-grace), ids).Scan(&activeIDs).Error
+	for _, id := range activeIDs {
+		active[id] = true
+	}
+	return active, err
+}
@@ -0,0 +1,314 @@
+package models
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"unicode"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// ExerciseDuplicateThreshold is the text similarity above which two exercises count as duplicates
+	ExerciseDuplicateThreshold = 0.85
+	// ExerciseReportRetireThreshold is the number of problem reports that retires an exercise
+	ExerciseReportRetireThreshold = 3
+	// exerciseMinAnswersForStats is the number of answers needed before accuracy is trusted
+	exerciseMinAnswersForStats = 20
+)
+
+// ExerciseItem is a generated exercise stored in the shared exercise bank
+type ExerciseItem struct {
+	gorm.Model
+	Topic         string         `gorm:"size:100;index" json:"topic"`
+	TopicKey      string         `gorm:"size:100;index:idx_exercise_items_bank" json:"topicKey"`
+	Type          string         `gorm:"size:20;not null;index:idx_exercise_items_bank" json:"type"` // "quiz" or "coding"
+	Difficulty    string         `gorm:"size:20;index:idx_exercise_items_bank" json:"difficulty"`
+	Fingerprint   string         `gorm:"size:40;index" json:"-"`
+	Question      string         `gorm:"type:text" json:"question,omitempty"`
+	Options       pq.StringArray `gorm:"type:text[]" json:"options,omitempty"`
+	CorrectAnswer int            `json:"correctAnswer"`
+	Prompt        string         `gorm:"type:text" json:"prompt,omitempty"`
+	StarterCode   string         `gorm:"type:text" json:"starterCode,omitempty"`
+	Solution      string         `gorm:"type:text" json:"solution,omitempty"`
+	Explanation   string         `gorm:"type:text" json:"explanation,omitempty"`
+	Hints         pq.StringArray `gorm:"type:text[]" json:"-"`    // Revealed one at a time through HintUsage
+	Tags          pq.StringArray `gorm:"type:text[]" json:"tags"` // Slugs of the topic concepts the exercise practices
+
+	// Quality signals
+	Placeholder   bool   `gorm:"not null;default:false" json:"placeholder"` // Fallback content that is never served from the bank
+	TimesServed   int    `gorm:"not null;default:0" json:"timesServed"`
+	TimesAnswered int    `gorm:"not null;default:0" json:"timesAnswered"`
+	TimesCorrect  int    `gorm:"not null;default:0" json:"timesCorrect"`
+	ReportCount   int    `gorm:"not null;default:0" json:"reportCount"`
+	Retired       bool   `gorm:"not null;default:false;index" json:"retired"`
+	RetiredReason string `gorm:"size:100" json:"retiredReason,omitempty"`
+}
+
+// ExerciseReport is a learner's "report problem" flag on a bank exercise
+type ExerciseReport struct {
+	gorm.Model
+	UserID         uint   `gorm:"not null;uniqueIndex:idx_exercise_reports_user_item" json:"userId"`
+	ExerciseItemID uint   `gorm:"not null;uniqueIndex:idx_exercise_reports_user_item" json:"exerciseItemId"`
+	Reason         string `gorm:"size:50;not null" json:"reason"` // "wrong-answer", "unclear", "off-topic", "other"
+	Details        string `gorm:"type:text" json:"details,omitempty"`
+}
+
+// BeforeSave keeps the bank lookup keys in sync with the exercise content
+func (e *ExerciseItem) BeforeSave(tx *gorm.DB) error {
+	e.TopicKey = NormalizeTopicKey(e.Topic)
+	e.Difficulty = NormalizeDifficulty(e.Difficulty)
+	e.Fingerprint = ExerciseFingerprint(e.Text())
+	return nil
+}
+
+// Text returns the text that identifies the exercise for deduplication
+func (e *ExerciseItem) Text() string {
+	if e.Type == "coding" {
+		return e.Prompt
+	}
+	return e.Question + " " + strings.Join(e.Options, " ")
+}
+
+// Accuracy returns the share of answers that were correct
+func (e *ExerciseItem) Accuracy() float64 {
+	if e.TimesAnswered == 0 {
+		return 0
+	}
+	return float64(e.TimesCorrect) / float64(e.TimesAnswered)
+}
+
+// RecordExerciseAnswer counts an answer in an exercise's statistics and retires the exercise if it
+// looks broken. The counters are incremented in the database, so concurrent answers are all counted.
+func RecordExerciseAnswer(tx *gorm.DB, itemID uint, correct bool) (bool, error) {
+	correctIncrement := 0
+	if correct {
+		correctIncrement = 1
+	}
+	if err := tx.Model(&ExerciseItem{}).Where("id = ?", itemID).UpdateColumns(map[string]interface{}{
+		"times_answered": gorm.Expr("times_answered + ?", 1),
+		"times_correct":  gorm.Expr("times_correct + ?", correctIncrement),
+	}).Error; err != nil {
+		return false, err
+	}
+	return retireBrokenExercise(tx, itemID)
+}
+
+// RecordExerciseReport stores a learner's problem report and retires the exercise once enough
+// learners reported it. A learner's second report of the same exercise is not stored or counted,
+// which the returned recorded flag tells.
+func RecordExerciseReport(tx *gorm.DB, report *ExerciseReport) (recorded bool, retired bool, err error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(report)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, false, result.Error
+	}
+	if err := tx.Model(&ExerciseItem{}).Where("id = ?", report.ExerciseItemID).
+		UpdateColumn("report_count", gorm.Expr("report_count + ?", 1)).Error; err != nil {
+		return false, false, err
+	}
+	retired, err = retireBrokenExercise(tx, report.ExerciseItemID)
+	return true, retired, err
+}
+
+// HasAnsweredExercise reports whether a learner answered an exercise before, in an attempt or a
+// submitted assessment. Only first answers count in an exercise's statistics, so one account
+// cannot retire an exercise by answering it wrong again and again.
+func HasAnsweredExercise(tx *gorm.DB, userID, itemID uint) (bool, error) {
+	var answered bool
+	err := tx.Raw(`SELECT EXISTS (
+			SELECT 1 FROM exercise_attempts
+			WHERE user_id = ? AND exercise_item_id = ? AND deleted_at IS NULL
+		) OR EXISTS (
+			SELECT 1 FROM assessments
+			WHERE user_id = ? AND ? = ANY(exercise_item_ids) AND submitted_at IS NOT NULL AND deleted_at IS NULL
+		)`, userID, itemID, userID, itemID).Scan(&answered).Error
+	return answered, err
+}
+
+// retireBrokenExercise retires an exercise whose counters show it is flagged too often or
+// statistically broken, and reports whether it is retired. The caller's update of the counters
+// locks the row, so the counters it reads are current.
+func retireBrokenExercise(tx *gorm.DB, itemID uint) (bool, error) {
+	var item ExerciseItem
+	if err := tx.First(&item, itemID).Error; err != nil {
+		return false, err
+	}
+	if item.Retired {
+		return true, nil
+	}
+
+	item.checkRetirement()
+	if !item.Retired {
+		return false, nil
+	}
+	err := tx.Model(&ExerciseItem{}).Where("id = ?", itemID).UpdateColumns(map[string]interface{}{
+		"retired":        true,
+		"retired_reason": item.RetiredReason,
+	}).Error
+	return true, err
+}
+
+// checkRetirement retires exercises that are flagged too often or statistically broken
+func (e *ExerciseItem) checkRetirement() {
+	if e.Retired {
+		return
+	}
+
+	if e.ReportCount >= ExerciseReportRetireThreshold {
+		e.Retired = true
+		e.RetiredReason = "reported"
+		return
+	}
+
+	if e.TimesAnswered < exerciseMinAnswersForStats {
+		return
+	}
+
+	// A quiz answered correctly well below chance usually has a wrong answer key,
+	// and a coding exercise nobody solves usually has a broken prompt or solution
+	minAccuracy := 0.05
+	if e.Type == "quiz" {
+		minAccuracy = 0.15
+	}
+	if e.Accuracy() < minAccuracy {
+		e.Retired = true
+		e.RetiredReason = "low-accuracy"
+	}
+}
+
+// ExerciseFingerprint returns a hash of the normalized exercise text used for exact deduplication
+func ExerciseFingerprint(text string) string {
+	sum := sha1.Sum([]byte(strings.Join(exerciseWords(text), " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// ExerciseTextSimilarity returns the Jaccard similarity of the word sets of two exercise texts
+func ExerciseTextSimilarity(a, b string) float64 {
+	wordsA := make(map[string]bool)
+	for _, word := range exerciseWords(a) {
+		wordsA[word] = true
+	}
+	wordsB := make(map[string]bool)
+	for _, word := range exerciseWords(b) {
+		wordsB[word] = true
+	}
+
+	if len(wordsA) == 0 && len(wordsB) == 0 {
+		return 1
+	}
+
+	shared := 0
+	for word := range wordsA {
+		if wordsB[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wordsA)+len(wordsB)-shared)
+}
+
+// exerciseWords lowercases text and splits it into words, dropping punctuation
+func exerciseWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// FindDuplicateExercise returns an active stored exercise that is identical or nearly identical to
+// the given one. Retired exercises are skipped, so a corrected version of one can be stored.
+func FindDuplicateExercise(tx *gorm.DB, item *ExerciseItem) (*ExerciseItem, error) {
+	var exact ExerciseItem
+	err := tx.Where("topic_key = ? AND type = ? AND fingerprint = ? AND retired = ?",
+		NormalizeTopicKey(item.Topic), item.Type, ExerciseFingerprint(item.Text()), false).
+		First(&exact).Error
+	if err == nil {
+		return &exact, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	var candidates []ExerciseItem
+	if err := tx.Where("topic_key = ? AND type = ? AND placeholder = ? AND retired = ?", NormalizeTopicKey(item.Topic), item.Type, false, false).
+		Order("created_at DESC").
+		Limit(500).
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	text := item.Text()
+	for i := range candidates {
+		if ExerciseTextSimilarity(text, candidates[i].Text()) >= ExerciseDuplicateThreshold {
+			return &candidates[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// SelectBankExercises returns the best active bank exercises for a topic, type and difficulty.
+// An empty difficulty matches every level. When userID is set, exercises the user has already
+// attempted, revealed hints for or been given in an assessment are skipped.
+func SelectBankExercises(tx *gorm.DB, topic, exerciseType, difficulty string, userID uint, limit int) ([]ExerciseItem, error) {
+	var items []ExerciseItem
+	if limit <= 0 {
+		return items, nil
+	}
+
+	query := tx.Where("topic_key = ? AND type = ? AND retired = ? AND placeholder = ?",
+		NormalizeTopicKey(topic), exerciseType, false, false)
+
+	if difficulty != "" {
+		query = query.Where("difficulty = ?", NormalizeDifficulty(difficulty))
+	}
+
+	if userID != 0 {
+		query = query.Where("id NOT IN (?)",
+			tx.Model(&ExerciseAttempt{}).Select("exercise_item_id").
+				Where("user_id = ? AND exercise_item_id IS NOT NULL", userID)).
+			Where("id NOT IN (?)",
+				tx.Model(&HintUsage{}).Select("exercise_item_id").Where("user_id = ?", userID)).
+			Where("id NOT IN (?)",
+				tx.Model(&Assessment{}).Select("unnest(exercise_item_ids)").Where("user_id = ?", userID))
+	}
+
+	// Prefer unreported items whose accuracy is closest to the 70% sweet spot,
+	// then spread exposure across the bank
+	err := query.
+		Order("report_count ASC").
+		Order("CASE WHEN times_answered >= 5 THEN ABS(times_correct::float / times_answered - 0.7) ELSE 0.15 END ASC").
+		Order("times_served ASC").
+		Limit(limit).
+		Find(&items).Error
+	return items, err
+}
+
+// HintUsage records a hint revealed to a learner for a stored exercise
+type HintUsage struct {
+	gorm.Model
+	UserID         uint   `gorm:"not null;uniqueIndex:idx_hint_usages_user_exercise" json:"userId"`
+	ExerciseItemID uint   `gorm:"not null;uniqueIndex:idx_hint_usages_user_exercise" json:"exerciseItemId"`
+	HintIndex      int    `gorm:"uniqueIndex:idx_hint_usages_user_exercise" json:"hintIndex"`
+	Content        string `gorm:"type:text" json:"content"`
+	Generated      bool   `json:"generated"` // True when the hint was generated from the learner's code
+}
+
+// ExerciseAttempt records a graded attempt at an exercise
+type ExerciseAttempt struct {
+	gorm.Model
+	UserID         uint          `gorm:"index;not null" json:"userId"`
+	Topic          string        `gorm:"size:100;index" json:"topic"`
+	ExerciseID     string        `gorm:"size:100" json:"exerciseId,omitempty"`
+	ExerciseItemID *uint         `gorm:"index" json:"exerciseItemId,omitempty"`
+	Type           string        `gorm:"size:20" json:"type"` // "quiz" or "coding"
+	Difficulty     string        `gorm:"size:20" json:"difficulty"`
+	RawScore       float64       `json:"rawScore"` // Score before hint penalties
+	HintsUsed      int           `json:"hintsUsed"`
+	Score          float64       `json:"score"` // Percentage from 0-100
+	Correct        bool          `json:"correct"`
+	Code           string        `gorm:"type:text" json:"code,omitempty"`
+	Fingerprints   pq.Int64Array `gorm:"type:bigint[]" json:"-"` // Winnowed fingerprints of Code, excluding the starter code
+	RatingBefore   float64       `json:"ratingBefore"`
+	RatingAfter    float64       `json:"ratingAfter"`
+}
@@ -0,0 +1,213 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ExportColumn is a column of an exported dataset
+type ExportColumn struct {
+	Name       string `json:"name"`
+	SQL        string `json:"-"`              // Expression selecting the column, the column of the same name if empty
+	Date       bool   `json:"date,omitempty"` // A day rather than a moment
+	UserColumn bool   `json:"-"`              // Identifies the user, so it is pseudonymized in exports across users
+}
+
+// ExportDataset is a table learners and admins can export
+type ExportDataset struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Table       string         `json:"-"`
+	DateColumn  string         `json:"dateColumn"` // The column date ranges apply to
+	Order       string         `json:"-"`
+	SoftDeleted bool           `json:"-"` // Whether the table has a deleted_at column
+	Columns     []ExportColumn `json:"columns"`
+}
+
+// ExportDatasets are the datasets that can be exported
+var ExportDatasets = []ExportDataset{
+	{
+		Name:        "activity",
+		Description: "Every tracked learning activity",
+		Table:       "activity_logs",
+		DateColumn:  "timestamp",
+		Order:       "timestamp ASC, id ASC",
+		SoftDeleted: true,
+		Columns: []ExportColumn{
+			{Name: "id"},
+			{Name: "user_id", UserColumn: true},
+			{Name: "activity_type"},
+			{Name: "description"},
+			{Name: "topic_id"},
+			{Name: "topic_name"},
+			{Name: "exercise_id"},
+			{Name: "time_spent"},
+			{Name: "score", SQL: "score::float8"},
+			{Name: "timestamp"},
+		},
+	},
+	{
+		Name:        "daily-activity",
+		Description: "Learning time, topics and exercises per day",
+		Table:       "daily_activities",
+		DateColumn:  "date",
+		Order:       "date ASC, user_id ASC",
+		SoftDeleted: true,
+		Columns: []ExportColumn{
+			{Name: "user_id", UserColumn: true},
+			{Name: "date", Date: true},
+			{Name: "learning_time_min"},
+			{Name: "topics_viewed"},
+			{Name: "topics_completed"},
+			{Name: "exercises_done"},
+		},
+	},
+	{
+		Name:        "topic-interactions",
+		Description: "Views, time, ratings and scores per topic",
+		Table:       "topic_interactions",
+		DateColumn:  "updated_at",
+		Order:       "updated_at ASC, id ASC",
+		SoftDeleted: true,
+		Columns: []ExportColumn{
+			{Name: "user_id", UserColumn: true},
+			{Name: "topic_id"},
+			{Name: "topic_name"},
+			{Name: "view_count"},
+			{Name: "time_spent"},
+			{Name: "last_viewed"},
+			{Name: "completed_at"},
+			{Name: "rating"},
+			{Name: "difficulty"},
+			{Name: "quiz_score", SQL: "quiz_score::float8"},
+			{Name: "code_score", SQL: "code_score::float8"},
+			{Name: "updated_at"},
+		},
+	},
+	{
+		Name:        "progress",
+		Description: "Current progress per topic",
+		Table:       "user_topic_progress",
+		DateColumn:  "updated_at",
+		Order:       "updated_at ASC, id ASC",
+		SoftDeleted: true,
+		Columns: []ExportColumn{
+			{Name: "user_id", UserColumn: true},
+			{Name: "topic_id"},
+			{Name: "topic"},
+			{Name: "viewed"},
+			{Name: "last_viewed"},
+			{Name: "completed"},
+			{Name: "completed_at"},
+			{Name: "quiz_score"},
+			{Name: "code_score"},
+			{Name: "mastered"},
+			{Name: "mastered_at"},
+			{Name: "updated_at"},
+		},
+	},
+	{
+		Name:        "progress-events",
+		Description: "Every change to topic progress",
+		Table:       "progress_events",
+		DateColumn:  "occurred_at",
+		Order:       "occurred_at ASC, id ASC",
+		Columns: []ExportColumn{
+			{Name: "id"},
+			{Name: "user_id", UserColumn: true},
+			{Name: "topic_id"},
+			{Name: "type"},
+			{Name: "score"},
+			{Name: "source"},
+			{Name: "undoes_event_id"},
+			{Name: "occurred_at"},
+		},
+	},
+}
+
+// FindExportDataset returns the dataset of a name
+func FindExportDataset(name string) (ExportDataset, bool) {
+	for _, dataset := range ExportDatasets {
+		if dataset.Name == name {
+			return dataset, true
+		}
+	}
+	return ExportDataset{}, false
+}
+
+// SelectColumns returns the named columns in the order given, or every column if none are named
+func (d ExportDataset) SelectColumns(names []string) ([]ExportColumn, error) {
+	if len(names) == 0 {
+		return d.Columns, nil
+	}
+
+	columns := make([]ExportColumn, 0, len(names))
+	for _, name := range names {
+		column, ok := d.column(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("Unknown column %q of %s", name, d.Name)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// column returns the column of a name
+func (d ExportDataset) column(name string) (ExportColumn, bool) {
+	for _, column := range d.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return ExportColumn{}, false
+}
+
+// Query selects the columns of the dataset with the date column in [from, to). A nil userID
+// exports the rows of every user. Zero times leave the range open.
+func (d ExportDataset) Query(db *gorm.DB, columns []ExportColumn, userID *uint, from, to time.Time) *gorm.DB {
+	selects := make([]string, len(columns))
+	for i, column := range columns {
+		expr := column.SQL
+		if expr == "" {
+			expr = fmt.Sprintf("%q", column.Name)
+		}
+		selects[i] = fmt.Sprintf("%s AS %q", expr, column.Name)
+	}
+
+	query := db.Table(d.Table).Select(strings.Join(selects, ", "))
+	if d.SoftDeleted {
+		query = query.Where("deleted_at IS NULL")
+	}
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	if !from.IsZero() {
+		query = query.Where(fmt.Sprintf("%q >= ?", d.DateColumn), from)
+	}
+	if !to.IsZero() {
+		query = query.Where(fmt.Sprintf("%q < ?", d.DateColumn), to)
+	}
+	return query.Order(d.Order)
+}
+
+// PseudonymizeUserID replaces a user ID with a keyed hash, so exports across users can be joined
+// on users without revealing who they are. The key is EXPORT_PSEUDONYM_KEY, so pseudonyms stay the
+// same from one export to the next. It has its own key so that it can be rotated, and handed to
+// whoever needs to re-identify users, without touching the secret that signs sessions.
+func PseudonymizeUserID(id uint) (string, error) {
+	key := os.Getenv("EXPORT_PSEUDONYM_KEY")
+	if key == "" {
+		return "", fmt.Errorf("EXPORT_PSEUDONYM_KEY is not set")
+	}
+
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "user:%d", id)
+	return "u_" + hex.EncodeToString(mac.Sum(nil))[:20], nil
+}
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Goal types
+const (
+	GoalTypeRoadmap         = "roadmap"          // Finish a roadmap by the deadline
+	GoalTypeWeeklyExercises = "weekly-exercises" // Complete a number of exercises every week
+	GoalTypeStudyTime       = "study-time"       // Study a number of hours by the deadline
+)
+
+// Goal statuses
+const (
+	GoalStatusOnTrack  = "on-track"
+	GoalStatusAtRisk   = "at-risk"
+	GoalStatusMissed   = "missed"
+	GoalStatusAchieved = "achieved"
+)
+
+// goalRiskTolerance is the share of the steady pace a goal may fall behind before it is at risk
+const goalRiskTolerance = 0.8
+
+// Goal is a measurable learning goal with a deadline
+type Goal struct {
+	gorm.Model
+	UserID      uint       `gorm:"index;not null" json:"userId"`
+	Title       string     `gorm:"size:200;not null" json:"title"`
+	Type        string     `gorm:"size:30;not null" json:"type"`
+	RoadmapID   *uint      `gorm:"index" json:"roadmapId,omitempty"` // Set for roadmap goals
+	Target      float64    `json:"target"`                           // Exercises per week or hours of study; unused for roadmap goals
+	StartDate   time.Time  `gorm:"not null" json:"startDate"`
+	Deadline    time.Time  `gorm:"not null" json:"deadline"` // Last day of the goal
+	Status      string     `gorm:"size:20;not null;default:'on-track'" json:"status"`
+	Current     float64    `json:"current"`  // Roadmap percent, exercises this week or hours studied
+	Progress    float64    `json:"progress"` // Share of the goal reached, from 0 to 1
+	EvaluatedAt *time.Time `json:"evaluatedAt,omitempty"`
+}
+
+// GoalMeasurement is the learning activity a goal is evaluated against
+type GoalMeasurement struct {
+	Activity       []DailyActivity // Daily activity from the start of the goal
+	CompletedSteps int             // Roadmap goals only
+	TotalSteps     int
+	ETA            *RoadmapETA // Predicted completion of the roadmap with the deadline as target
+}
+
+// End returns the moment the goal's deadline passes, the end of its last day
+func (g *Goal) End() time.Time {
+	return g.Deadline.AddDate(0, 0, 1)
+}
+
+// Evaluate measures the goal's progress and marks it achieved, on track, at risk or missed
+func (g *Goal) Evaluate(m GoalMeasurement, now time.Time) {
+	switch g.Type {
+	case GoalTypeRoadmap:
+		g.evaluateRoadmap(m, now)
+	case GoalTypeWeeklyExercises:
+		g.evaluateWeeklyExercises(m, now)
+	case GoalTypeStudyTime:
+		g.evaluateStudyTime(m, now)
+	}
+	g.Progress = math.Round(g.Progress*1000) / 1000
+	g.EvaluatedAt = &now
+}
+
+// elapsed returns the share of the goal's time span that has passed
+func (g *Goal) elapsed(now time.Time) float64 {
+	span := g.End().Sub(g.StartDate)
+	if span <= 0 {
+		return 1
+	}
+	return math.Min(math.Max(now.Sub(g.StartDate).Seconds()/span.Seconds(), 0), 1)
+}
+
+// evaluateRoadmap measures the share of completed steps, using the predicted completion date to
+// tell whether the roadmap is finished in time
+func (g *Goal) evaluateRoadmap(m GoalMeasurement, now time.Time) {
+	g.Current, g.Progress = 0, 0
+	if m.TotalSteps > 0 {
+		g.Progress = float64(m.CompletedSteps) / float64(m.TotalSteps)
+		g.Current = math.Round(g.Progress * 100)
+	}
+
+	switch {
+	case m.TotalSteps > 0 && m.CompletedSteps == m.TotalSteps:
+		g.Status = GoalStatusAchieved
+	case !now.Before(g.End()):
+		g.Status = GoalStatusMissed
+	case m.ETA != nil && m.ETA.Behind:
+		g.Status = GoalStatusAtRisk
+	case (m.ETA == nil || m.ETA.EstimatedDate == nil) && g.Progress < goalRiskTolerance*g.elapsed(now):
+		// Without a pace to predict from, the steady pace to the deadline is the yardstick
+		g.Status = GoalStatusAtRisk
+	default:
+		g.Status = GoalStatusOnTrack
+	}
+}
+
+// evaluateWeeklyExercises counts the exercises of each week since the start. A week that fell short
+// misses the goal; the current week is at risk while it is behind the steady pace to its target.
+func (g *Goal) evaluateWeeklyExercises(m GoalMeasurement, now time.Time) {
+	end := g.End()
+	weeks := int(math.Ceil(end.Sub(g.StartDate).Hours() / (24 * 7)))
+	if weeks < 1 {
+		weeks = 1
+	}
+
+	counts := make([]int, weeks)
+	for _, day := range m.Activity {
+		if day.Date.Before(g.StartDate) || !day.Date.Before(end) {
+			continue
+		}
+		if week := int(day.Date.Sub(g.StartDate).Hours() / (24 * 7)); week < weeks {
+			counts[week] += day.ExercisesDone
+		}
+	}
+
+	current := weeks - 1
+	if now.Before(end) {
+		current = max(int(now.Sub(g.StartDate).Hours()/(24*7)), 0)
+	}
+
+	met, short := 0.0, false
+	for week := 0; week < weeks; week++ {
+		weekEnd := g.StartDate.AddDate(0, 0, 7*(week+1))
+		if weekEnd.After(end) {
+			weekEnd = end
+		}
+		switch {
+		case float64(counts[week]) >= g.Target:
+			met++
+		case now.Before(weekEnd):
+			// A week still under way counts with what it has so far
+			if g.Target > 0 {
+				met += float64(counts[week]) / g.Target
+			}
+		default:
+			short = true
+		}
+	}
+	g.Current = float64(counts[current])
+	g.Progress = met / float64(weeks)
+
+	switch {
+	case short:
+		g.Status = GoalStatusMissed
+	case !now.Before(end):
+		g.Status = GoalStatusAchieved
+	default:
+		weekStart := g.StartDate.AddDate(0, 0, 7*current)
+		weekEnd := weekStart.AddDate(0, 0, 7)
+		if weekEnd.After(end) {
+			weekEnd = end
+		}
+		elapsed := now.Sub(weekStart).Seconds() / weekEnd.Sub(weekStart).Seconds()
+		if g.Current < g.Target && g.Current < goalRiskTolerance*g.Target*elapsed {
+			g.Status = GoalStatusAtRisk
+		} else {
+			g.Status = GoalStatusOnTrack
+		}
+	}
+}
+
+// evaluateStudyTime adds up the learning time between the start and the deadline
+func (g *Goal) evaluateStudyTime(m GoalMeasurement, now time.Time) {
+	minutes := 0
+	for _, day := range m.Activity {
+		if !day.Date.Before(g.StartDate) && day.Date.Before(g.End()) {
+			minutes += day.LearningTimeMin
+		}
+	}
+	g.Current = math.Round(float64(minutes)/60*10) / 10
+	g.Progress = 0
+	if g.Target > 0 {
+		g.Progress = math.Min(float64(minutes)/60/g.Target, 1)
+	}
+
+	switch {
+	case g.Progress >= 1:
+		g.Status = GoalStatusAchieved
+	case !now.Before(g.End()):
+		g.Status = GoalStatusMissed
+	case g.Progress < goalRiskTolerance*g.elapsed(now):
+		g.Status = GoalStatusAtRisk
+	default:
+		g.Status = GoalStatusOnTrack
+	}
+}
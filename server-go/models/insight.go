@@ -0,0 +1,229 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InsightReport is a learner's weekly report: what they did in a week, interpreted by the LLM with
+// recommendations for the next one
+type InsightReport struct {
+	ID              uint           `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time      `json:"createdAt"`
+	UserID          uint           `gorm:"not null;uniqueIndex:idx_insight_reports_week" json:"userId"`
+	WeekStart       time.Time      `gorm:"not null;uniqueIndex:idx_insight_reports_week" json:"weekStart"` // Monday of the week in the user's timezone, as midnight UTC
+	Summary         string         `gorm:"type:text;not null" json:"summary"`
+	Recommendations pq.StringArray `gorm:"type:text[]" json:"recommendations"`
+	Stats           InsightStats   `gorm:"type:jsonb" json:"stats"` // What the report was written from
+}
+
+// InsightAttempt records the attempts at a learner's report on a week until one succeeds. A
+// server claims the week before asking the LLM, and a failed attempt is retried after RetryAfter.
+type InsightAttempt struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	UserID     uint      `gorm:"not null;uniqueIndex:idx_insight_attempts_week" json:"userId"`
+	WeekStart  time.Time `gorm:"not null;uniqueIndex:idx_insight_attempts_week" json:"weekStart"`
+	Attempts   int       `gorm:"not null;default:0" json:"attempts"`
+	RetryAfter time.Time `gorm:"not null" json:"retryAfter"`
+	LastError  string    `gorm:"type:text" json:"lastError,omitempty"`
+}
+
+// InsightStats is what a weekly report is written from
+type InsightStats struct {
+	ActiveDays          int              `json:"activeDays"`
+	LearningTimeMin     int              `json:"learningTimeMin"`
+	PreviousLearningMin int              `json:"previousLearningMin"` // Learning time of the week before, for comparison
+	TopicsViewed        int              `json:"topicsViewed"`
+	TopicsCompleted     int              `json:"topicsCompleted"`
+	ExercisesDone       int              `json:"exercisesDone"`
+	Days                []InsightDay     `json:"days"`
+	WeakestTopics       []InsightTopic   `json:"weakestTopics,omitempty"`
+	StalledRoadmaps     []InsightRoadmap `json:"stalledRoadmaps,omitempty"`
+}
+
+// InsightDay is the learning time of a day of the week
+type InsightDay struct {
+	Date            time.Time `json:"date"`
+	LearningTimeMin int       `json:"learningTimeMin"`
+}
+
+// InsightTopic is a topic the learner scored low in
+type InsightTopic struct {
+	TopicName string  `json:"topicName"`
+	QuizScore float64 `json:"quizScore,omitempty"`
+	CodeScore float64 `json:"codeScore,omitempty"`
+}
+
+// InsightRoadmap is an unfinished roadmap without recent progress
+type InsightRoadmap struct {
+	Topic          string     `json:"topic"`
+	CompletedSteps int        `json:"completedSteps"`
+	TotalSteps     int        `json:"totalSteps"`
+	LastProgress   *time.Time `json:"lastProgress,omitempty"` // Last completed step, if any
+}
+
+// Value implements the driver.Valuer interface for database serialization
+func (s InsightStats) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan implements the sql.Scanner interface for database deserialization
+func (s *InsightStats) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal InsightStats value: %v", value)
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+// HasActivity reports whether the learner learned at all in the week
+func (s InsightStats) HasActivity() bool {
+	return s.ActiveDays > 0
+}
+
+// Limits of what a weekly report is written from
+const (
+	insightWeakestTopics   = 3
+	insightWeakScore       = 70.0 // Scores below this are weak
+	insightStalledRoadmaps = 3
+	insightStalledAfter    = 14 * 24 * time.Hour // Time without a completed step after which a roadmap has stalled
+)
+
+// LoadInsightStats gathers a learner's daily activity in the week starting on a Monday, the topics
+// they scored lowest in and their roadmaps without recent progress
+func LoadInsightStats(db *gorm.DB, userID uint, weekStart time.Time, now time.Time) (InsightStats, error) {
+	weekEnd := weekStart.AddDate(0, 0, 7)
+	stats := InsightStats{Days: make([]InsightDay, 7)}
+	for i := range stats.Days {
+		stats.Days[i].Date = weekStart.AddDate(0, 0, i)
+	}
+
+	var days []DailyActivity
+	if err := db.Where("user_id = ? AND date >= ? AND date < ?", userID, weekStart, weekEnd).Find(&days).Error; err != nil {
+		return stats, err
+	}
+	active := make(map[int]bool, len(stats.Days))
+	for _, day := range days {
+		index := int(day.Date.UTC().Sub(weekStart).Hours() / 24)
+		if index < 0 || index >= len(stats.Days) {
+			continue
+		}
+		stats.Days[index].LearningTimeMin += day.LearningTimeMin
+		stats.LearningTimeMin += day.LearningTimeMin
+		stats.TopicsViewed += day.TopicsViewed
+		stats.TopicsCompleted += day.TopicsCompleted
+		stats.ExercisesDone += day.ExercisesDone
+		if day.LearningTimeMin > 0 || day.TopicsViewed > 0 || day.TopicsCompleted > 0 || day.ExercisesDone > 0 {
+			active[index] = true
+		}
+	}
+	stats.ActiveDays = len(active)
+
+	if err := db.Model(&DailyActivity{}).
+		Select("COALESCE(SUM(learning_time_min), 0)").
+		Where("user_id = ? AND date >= ? AND date < ?", userID, weekStart.AddDate(0, 0, -7), weekStart).
+		Row().Scan(&stats.PreviousLearningMin); err != nil {
+		return stats, err
+	}
+
+	if err := db.Raw(`
+		SELECT topic_name, quiz_score::float8 AS quiz_score, code_score::float8 AS code_score
+		FROM topic_interactions
+		WHERE deleted_at IS NULL AND user_id = ?
+			AND ((quiz_score > 0 AND quiz_score < ?) OR (code_score > 0 AND code_score < ?))
+		ORDER BY LEAST(NULLIF(quiz_score, 0), NULLIF(code_score, 0)) ASC
+		LIMIT ?
+	`, userID, insightWeakScore, insightWeakScore, insightWeakestTopics).Scan(&stats.WeakestTopics).Error; err != nil {
+		return stats, err
+	}
+
+	stalledBefore := now.Add(-insightStalledAfter)
+	if err := db.Raw(`
+		SELECT roadmaps.topic,
+			COUNT(*) FILTER (WHERE roadmap_steps.completed) AS completed_steps,
+			COUNT(*) AS total_steps,
+			MAX(roadmap_steps.completed_at) AS last_progress
+		FROM roadmaps
+		JOIN roadmap_steps ON roadmap_steps.roadmap_id = roadmaps.id AND roadmap_steps.deleted_at IS NULL
+		WHERE roadmaps.deleted_at IS NULL AND roadmaps.user_id = ? AND roadmaps.created_at < ?
+		GROUP BY roadmaps.id, roadmaps.topic
+		HAVING COUNT(*) FILTER (WHERE roadmap_steps.completed) < COUNT(*)
+			AND COALESCE(MAX(roadmap_steps.completed_at), roadmaps.created_at) < ?
+		ORDER BY COALESCE(MAX(roadmap_steps.completed_at), roadmaps.created_at) DESC
+		LIMIT ?
+	`, userID, stalledBefore, stalledBefore, insightStalledRoadmaps).Scan(&stats.StalledRoadmaps).Error; err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// PendingInsightUsers returns, in ID order after afterID, the users who have not opted out of
+// weekly reports and were active in the two weeks before now, which cover their last full week
+func PendingInsightUsers(db *gorm.DB, afterID uint, now time.Time, limit int) ([]User, error) {
+	var users []User
+	err := db.Where("id > ? AND insights_opt_out = ?", afterID, false).
+		Where(`EXISTS (
+			SELECT 1 FROM daily_activities
+			WHERE daily_activities.user_id = users.id AND daily_activities.deleted_at IS NULL
+				AND daily_activities.date >= ? AND `+activeDayCondition+`
+		)`, ActivityDay(now, time.UTC).AddDate(0, 0, -15)).
+		Order("id ASC").
+		Limit(limit).
+		Find(&users).Error
+	return users, err
+}
+
+// LastInsightWeek returns the Monday of the last full week in a user's timezone, as midnight UTC
+func LastInsightWeek(user User, now time.Time) time.Time {
+	return WeekStart(ActivityDay(now, user.Location())).AddDate(0, 0, -7)
+}
+
+// ClaimInsightWeek claims a learner's report on a week for an attempt that ends by until, unless
+// another attempt is running or a failed one is not due for a retry. It returns the number of the
+// attempt, or 0 if the week was not claimed.
+func ClaimInsightWeek(db *gorm.DB, userID uint, week, now, until time.Time) (int, error) {
+	var attempts []int
+	err := db.Raw(`
+		INSERT INTO insight_attempts (updated_at, user_id, week_start, attempts, retry_after)
+		VALUES (?, ?, ?, 1, ?)
+		ON CONFLICT (user_id, week_start) DO UPDATE
+		SET attempts = insight_attempts.attempts + 1, retry_after = EXCLUDED.retry_after, updated_at = EXCLUDED.updated_at
+		WHERE insight_attempts.retry_after <= ?
+		RETURNING attempts
+	`, now, userID, week, until, now).Scan(&attempts).Error
+	if err != nil || len(attempts) == 0 {
+		return 0, err
+	}
+	return attempts[0], nil
+}
+
+// FailInsightWeek records why an attempt at a learner's report on a week failed and when to retry
+func FailInsightWeek(db *gorm.DB, userID uint, week, retryAfter time.Time, cause error) error {
+	return db.Model(&InsightAttempt{}).
+		Where("user_id = ? AND week_start = ?", userID, week).
+		UpdateColumns(map[string]interface{}{
+			"retry_after": retryAfter,
+			"last_error":  cause.Error(),
+			"updated_at":  time.Now(),
+		}).Error
+}
+
+// SaveInsightReport stores a report and forgets the attempts at it and at the learner's earlier
+// weeks
+func SaveInsightReport(db *gorm.DB, report *InsightReport) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(report).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND week_start <= ?", report.UserID, report.WeekStart).
+			Delete(&InsightAttempt{}).Error
+	})
+}
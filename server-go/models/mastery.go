@@ -0,0 +1,285 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// MasteryThreshold is the probability of knowing a concept above which it counts as mastered
+	MasteryThreshold = 0.95
+	// maxConceptsPerSource is how many concepts one lecture or exercise may add to a topic
+	maxConceptsPerSource = 12
+)
+
+// Bayesian knowledge tracing parameters
+const (
+	bktInitial     = 0.2  // Probability of knowing a concept before any practice
+	bktTransit     = 0.15 // Probability of learning the concept through an attempt
+	bktSlip        = 0.1  // Probability of a wrong answer despite knowing the concept
+	bktGuessQuiz   = 0.25 // Probability of guessing one of four quiz options right
+	bktGuessCoding = 0.05 // Probability of solving a coding exercise without knowing the concept
+)
+
+// genericConcepts are filler keywords that say nothing about what a learner knows
+var genericConcepts = map[string]bool{
+	"guide": true, "tutorial": true, "fundamentals": true, "basics": true, "introduction": true,
+	"overview": true, "best-practices": true, "applications": true, "examples": true, "concepts": true,
+}
+
+// TopicConcept is a concept taught or assessed within a topic of the catalog
+type TopicConcept struct {
+	gorm.Model
+	TopicID  uint   `gorm:"not null;uniqueIndex:idx_topic_concepts_topic_concept" json:"topicId"`
+	Concept  string `gorm:"size:120;not null;uniqueIndex:idx_topic_concepts_topic_concept" json:"concept"` // Slug of the concept name
+	Name     string `gorm:"size:200;not null" json:"name"`
+	Source   string `gorm:"size:20;not null" json:"source"`         // "lecture" or "exercise", whichever named it first
+	Assessed bool   `gorm:"not null;default:false" json:"assessed"` // Whether any exercise practices the concept
+}
+
+// ConceptMastery is the knowledge tracing estimate of a learner's grasp of a concept
+type ConceptMastery struct {
+	gorm.Model
+	UserID        uint      `gorm:"not null;uniqueIndex:idx_concept_masteries_user_concept" json:"userId"`
+	TopicID       uint      `gorm:"not null;uniqueIndex:idx_concept_masteries_user_concept;index" json:"topicId"`
+	Concept       string    `gorm:"size:120;not null;uniqueIndex:idx_concept_masteries_user_concept" json:"concept"`
+	PKnown        float64   `gorm:"not null;default:0.2" json:"pKnown"`
+	Attempts      int       `gorm:"not null;default:0" json:"attempts"`
+	Correct       int       `gorm:"not null;default:0" json:"correct"`
+	LastAttemptAt time.Time `json:"lastAttemptAt"`
+}
+
+// ConceptState is a concept of a topic with the learner's estimated grasp of it
+type ConceptState struct {
+	Concept  string  `json:"concept"`
+	Name     string  `json:"name"`
+	PKnown   float64 `json:"pKnown"`
+	Attempts int     `json:"attempts"`
+	Assessed bool    `json:"assessed"`
+	Mastered bool    `json:"mastered"`
+}
+
+// TopicMastery summarizes a learner's mastery of the concepts of a topic
+type TopicMastery struct {
+	TopicID     uint           `json:"topicId"`
+	Topic       string         `json:"topic"` // Slug of the topic
+	Name        string         `json:"name"`
+	Probability float64        `json:"probability"` // Average probability of knowing the assessed concepts
+	Mastered    bool           `json:"mastered"`    // Every assessed concept is above the mastery threshold
+	Concepts    []ConceptState `json:"concepts"`
+}
+
+// ExerciseGuessRate returns the probability of answering an exercise of the given type right by chance
+func ExerciseGuessRate(exerciseType string) float64 {
+	if exerciseType == "coding" {
+		return bktGuessCoding
+	}
+	return bktGuessQuiz
+}
+
+// ApplyAttempt updates the probability of knowing the concept from a graded attempt.
+// score is the attempt result in the range 0-1; partial credit blends the posteriors of a right
+// and a wrong answer.
+func (m *ConceptMastery) ApplyAttempt(score, guess float64) {
+	known := m.PKnown
+	right := known * (1 - bktSlip) / (known*(1-bktSlip) + (1-known)*guess)
+	wrong := known * bktSlip / (known*bktSlip + (1-known)*(1-guess))
+	posterior := score*right + (1-score)*wrong
+
+	m.PKnown = posterior + (1-posterior)*bktTransit
+	m.Attempts++
+	if score >= 0.5 {
+		m.Correct++
+	}
+	m.LastAttemptAt = time.Now()
+}
+
+// RecordTopicConcepts adds named concepts to a topic and returns their slugs. A name that closely
+// matches a concept the topic already has is mapped onto it, so lectures and exercises that word a
+// concept differently still share it. Concepts named by an exercise are marked as assessed.
+func RecordTopicConcepts(tx *gorm.DB, topicID uint, names []string, source string) ([]string, error) {
+	var existing []TopicConcept
+	if err := tx.Where("topic_id = ?", topicID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	var slugs []string
+	for _, name := range names {
+		if len(slugs) >= maxConceptsPerSource {
+			break
+		}
+		name = TopicName(name)
+		slug := TopicSlug(name)
+		if slug == "" || genericConcepts[slug] || containsString(slugs, slug) {
+			continue
+		}
+
+		if match, ok := matchConcept(existing, name); ok {
+			slug = match.Concept
+			if containsString(slugs, slug) {
+				continue
+			}
+		}
+
+		concept := TopicConcept{
+			TopicID:  topicID,
+			Concept:  slug,
+			Name:     name,
+			Source:   source,
+			Assessed: source == "exercise",
+		}
+		onConflict := clause.OnConflict{
+			Columns:   []clause.Column{{Name: "topic_id"}, {Name: "concept"}},
+			DoNothing: true,
+		}
+		if concept.Assessed {
+			onConflict = clause.OnConflict{
+				Columns:   []clause.Column{{Name: "topic_id"}, {Name: "concept"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{"assessed": true}),
+			}
+		}
+		if err := tx.Clauses(onConflict).Create(&concept).Error; err != nil {
+			return nil, err
+		}
+
+		existing = append(existing, concept)
+		slugs = append(slugs, slug)
+	}
+	return slugs, nil
+}
+
+// matchConcept finds the concept of a topic a name refers to, by slug or by the most similar name
+func matchConcept(concepts []TopicConcept, name string) (TopicConcept, bool) {
+	slug := TopicSlug(name)
+	best, bestScore := -1, TopicMatchThreshold
+	for i, concept := range concepts {
+		if concept.Concept == slug {
+			return concept, true
+		}
+		if topicDigits(concept.Name) != topicDigits(name) {
+			continue
+		}
+		if score := StepNameSimilarity(name, concept.Name); score >= bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return TopicConcept{}, false
+	}
+	return concepts[best], true
+}
+
+// UpdateConceptMastery applies a graded attempt to the learner's estimate for a concept. The row is
+// locked while it is updated so concurrent attempts are all counted.
+func UpdateConceptMastery(tx *gorm.DB, userID, topicID uint, concept string, score, guess float64) (ConceptMastery, error) {
+	mastery := ConceptMastery{UserID: userID, TopicID: topicID, Concept: concept, PKnown: bktInitial}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "topic_id"}, {Name: "concept"}},
+		DoNothing: true,
+	}).Create(&mastery).Error; err != nil {
+		return mastery, err
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND topic_id = ? AND concept = ?", userID, topicID, concept).
+		First(&mastery).Error; err != nil {
+		return mastery, err
+	}
+
+	mastery.ApplyAttempt(score, guess)
+	return mastery, tx.Save(&mastery).Error
+}
+
+// GetTopicMastery returns the learner's mastery of every concept of a topic. Concepts that were
+// never practiced count with the initial estimate.
+func GetTopicMastery(db *gorm.DB, userID uint, topic Topic) (TopicMastery, error) {
+	masteries, err := LoadTopicMasteries(db, userID, []Topic{topic})
+	if err != nil {
+		return TopicMastery{}, err
+	}
+	return masteries[0], nil
+}
+
+// LoadUserMastery returns the learner's mastery of every topic they practiced
+func LoadUserMastery(db *gorm.DB, userID uint) ([]TopicMastery, error) {
+	var topics []Topic
+	practiced := db.Model(&ConceptMastery{}).Select("topic_id").Where("user_id = ?", userID)
+	if err := db.Where("id IN (?)", practiced).Order("name ASC").Find(&topics).Error; err != nil {
+		return nil, err
+	}
+	return LoadTopicMasteries(db, userID, topics)
+}
+
+// LoadTopicMasteries summarizes the learner's mastery of each of the given topics
+func LoadTopicMasteries(db *gorm.DB, userID uint, topics []Topic) ([]TopicMastery, error) {
+	result := make([]TopicMastery, len(topics))
+	if len(topics) == 0 {
+		return result, nil
+	}
+
+	ids := make([]uint, len(topics))
+	for i, topic := range topics {
+		ids[i] = topic.ID
+	}
+
+	var concepts []TopicConcept
+	if err := db.Where("topic_id IN ?", ids).Order("id ASC").Find(&concepts).Error; err != nil {
+		return nil, err
+	}
+	var masteries []ConceptMastery
+	if err := db.Where("user_id = ? AND topic_id IN ?", userID, ids).Find(&masteries).Error; err != nil {
+		return nil, err
+	}
+
+	known := make(map[uint]map[string]ConceptMastery)
+	for _, mastery := range masteries {
+		if known[mastery.TopicID] == nil {
+			known[mastery.TopicID] = make(map[string]ConceptMastery)
+		}
+		known[mastery.TopicID][mastery.Concept] = mastery
+	}
+
+	for i, topic := range topics {
+		summary := TopicMastery{TopicID: topic.ID, Topic: topic.Slug, Name: topic.Name, Concepts: []ConceptState{}}
+		assessed, total := 0, 0.0
+		for _, concept := range concepts {
+			if concept.TopicID != topic.ID {
+				continue
+			}
+			state := ConceptState{Concept: concept.Concept, Name: concept.Name, PKnown: bktInitial, Assessed: concept.Assessed}
+			if mastery, ok := known[topic.ID][concept.Concept]; ok {
+				state.PKnown = mastery.PKnown
+				state.Attempts = mastery.Attempts
+			}
+			state.Mastered = state.PKnown >= MasteryThreshold
+			summary.Concepts = append(summary.Concepts, state)
+
+			if state.Assessed {
+				assessed++
+				total += state.PKnown
+			}
+		}
+
+		// Concepts only a lecture mentions cannot be practiced, so only assessed ones decide mastery
+		if assessed > 0 {
+			summary.Probability = total / float64(assessed)
+			summary.Mastered = true
+			for _, state := range summary.Concepts {
+				if state.Assessed && !state.Mastered {
+					summary.Mastered = false
+				}
+			}
+		}
+		result[i] = summary
+	}
+	return result, nil
+}
+
+// ConceptNames returns the names of the concepts of a topic, so generated content can reuse them
+func ConceptNames(db *gorm.DB, topicID uint) ([]string, error) {
+	var names []string
+	err := db.Model(&TopicConcept{}).Where("topic_id = ?", topicID).Order("id ASC").Pluck("name", &names).Error
+	return names, err
+}
@@ -0,0 +1,274 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Funnel steps, in the order a new user goes through them
+const (
+	FunnelSignedUp        = "signed-up"
+	FunnelOnboarded       = "onboarded"
+	FunnelFirstLecture    = "first-lecture"
+	FunnelFirstExercise   = "first-exercise"
+	FunnelFirstCompletion = "first-completion"
+)
+
+// ActiveUsers counts the users active on a day and in the week and month up to it
+type ActiveUsers struct {
+	Date time.Time `json:"date"`
+	DAU  int       `json:"dau"`
+	WAU  int       `json:"wau"` // Users active in the 7 days up to the date
+	MAU  int       `json:"mau"` // Users active in the 30 days up to the date
+}
+
+// RetentionCohort is the users who signed up in a week and how many of them were active in each
+// week since
+type RetentionCohort struct {
+	Week      time.Time `json:"week"` // Monday of the signup week, UTC
+	Users     int       `json:"users"`
+	Active    []int     `json:"active"`    // Active users in each week since signing up, the signup week first
+	Retention []float64 `json:"retention"` // Active users as a share of the cohort
+}
+
+// FunnelStep is how many of the users who signed up reached a step and every step before it
+type FunnelStep struct {
+	Step       string  `json:"step"`
+	Users      int     `json:"users"`
+	Conversion float64 `json:"conversion"` // Share of the users of the previous step
+	Overall    float64 `json:"overall"`    // Share of the users who signed up
+}
+
+// TopicDropOff is how many learners who started a topic completed it and how many left without
+type TopicDropOff struct {
+	TopicID        uint    `json:"topicId"`
+	Topic          string  `json:"topic"` // Slug of the topic
+	Name           string  `json:"name"`
+	Started        int     `json:"started"`
+	Completed      int     `json:"completed"`
+	DroppedOff     int     `json:"droppedOff"` // Not completed and no learning activity since the cutoff
+	CompletionRate float64 `json:"completionRate"`
+	DropOffRate    float64 `json:"dropOffRate"`
+}
+
+// WeekStart returns the Monday of the week a moment falls in, as midnight UTC
+func WeekStart(t time.Time) time.Time {
+	day := ActivityDay(t, time.UTC)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// LoadActiveUsers counts the daily, weekly and monthly active users of each day from one day to
+// another. Days are the days of the users' timezones that daily activity is recorded in.
+func LoadActiveUsers(db *gorm.DB, from, to time.Time) ([]ActiveUsers, error) {
+	var series []ActiveUsers
+	err := db.Raw(`
+		WITH days AS (
+			SELECT gs::date AS day FROM generate_series(?::date, ?::date, interval '1 day') AS gs
+		), active AS (
+			SELECT DISTINCT user_id, (date AT TIME ZONE 'UTC')::date AS day
+			FROM daily_activities
+			WHERE deleted_at IS NULL AND `+activeDayCondition+` AND date >= ? AND date <= ?
+		)
+		SELECT days.day AS date,
+			COUNT(DISTINCT active.user_id) FILTER (WHERE active.day = days.day) AS dau,
+			COUNT(DISTINCT active.user_id) FILTER (WHERE active.day > days.day - 7) AS wau,
+			COUNT(DISTINCT active.user_id) AS mau
+		FROM days
+		LEFT JOIN active ON active.day > days.day - 30 AND active.day <= days.day
+		GROUP BY days.day
+		ORDER BY days.day
+	`, from.Format("2006-01-02"), to.Format("2006-01-02"), from.AddDate(0, 0, -29), to).Scan(&series).Error
+	return series, err
+}
+
+// LoadCohortRetention groups the users who signed up in the last weeks by signup week and counts
+// how many of each cohort were active in every week since
+func LoadCohortRetention(db *gorm.DB, weeks int, now time.Time) ([]RetentionCohort, error) {
+	current := WeekStart(now)
+	first := current.AddDate(0, 0, -7*(weeks-1))
+
+	var sizes []struct {
+		Cohort time.Time
+		Users  int
+	}
+	if err := db.Raw(`
+		SELECT date_trunc('week', created_at AT TIME ZONE 'UTC') AS cohort, COUNT(*) AS users
+		FROM users
+		WHERE deleted_at IS NULL AND created_at >= ?
+		GROUP BY 1
+	`, first).Scan(&sizes).Error; err != nil {
+		return nil, err
+	}
+
+	var active []struct {
+		Cohort time.Time
+		Week   int
+		Users  int
+	}
+	if err := db.Raw(`
+		SELECT cohorts.cohort,
+			FLOOR(EXTRACT(EPOCH FROM active.day - cohorts.cohort) / 604800)::int AS week,
+			COUNT(DISTINCT active.user_id) AS users
+		FROM (
+			SELECT id AS user_id, date_trunc('week', created_at AT TIME ZONE 'UTC') AS cohort
+			FROM users
+			WHERE deleted_at IS NULL AND created_at >= ?
+		) AS cohorts
+		JOIN (
+			SELECT DISTINCT user_id, date AT TIME ZONE 'UTC' AS day
+			FROM daily_activities
+			WHERE deleted_at IS NULL AND `+activeDayCondition+` AND date >= ?
+		) AS active ON active.user_id = cohorts.user_id AND active.day >= cohorts.cohort
+		GROUP BY 1, 2
+	`, first, first).Scan(&active).Error; err != nil {
+		return nil, err
+	}
+
+	cohorts := make([]RetentionCohort, weeks)
+	byWeek := make(map[string]*RetentionCohort, weeks)
+	for i := range cohorts {
+		week := first.AddDate(0, 0, 7*i)
+		elapsed := weeks - i // The signup week and every week since, up to the current one
+		cohorts[i] = RetentionCohort{Week: week, Active: make([]int, elapsed), Retention: make([]float64, elapsed)}
+		byWeek[week.Format("2006-01-02")] = &cohorts[i]
+	}
+	for _, size := range sizes {
+		if cohort, ok := byWeek[size.Cohort.Format("2006-01-02")]; ok {
+			cohort.Users = size.Users
+		}
+	}
+	for _, row := range active {
+		if cohort, ok := byWeek[row.Cohort.Format("2006-01-02")]; ok && row.Week >= 0 && row.Week < len(cohort.Active) {
+			cohort.Active[row.Week] = row.Users
+		}
+	}
+	for i := range cohorts {
+		for week, users := range cohorts[i].Active {
+			cohorts[i].Retention[week] = shareOf(users, cohorts[i].Users)
+		}
+	}
+	return cohorts, nil
+}
+
+// LoadFunnel follows the users who signed up between two moments through onboarding, their first
+// lecture, their first exercise and their first completed topic. A user counts for a step only
+// after reaching every step before it.
+func LoadFunnel(db *gorm.DB, from, to time.Time) ([]FunnelStep, error) {
+	var counts struct {
+		SignedUp        int
+		Onboarded       int
+		FirstLecture    int
+		FirstExercise   int
+		FirstCompletion int
+	}
+	if err := db.Raw(`
+		SELECT
+			COUNT(*) AS signed_up,
+			COUNT(*) FILTER (WHERE onboarded) AS onboarded,
+			COUNT(*) FILTER (WHERE onboarded AND lectured) AS first_lecture,
+			COUNT(*) FILTER (WHERE onboarded AND lectured AND exercised) AS first_exercise,
+			COUNT(*) FILTER (WHERE onboarded AND lectured AND exercised AND completed) AS first_completion
+		FROM (
+			SELECT
+				COALESCE(users.onboarding_data->>'completed' = 'true', false) AS onboarded,
+				EXISTS (
+					SELECT 1 FROM activity_logs
+					WHERE activity_logs.user_id = users.id AND activity_logs.deleted_at IS NULL
+						AND activity_logs.activity_type = 'topic-view'
+				) AS lectured,
+				EXISTS (
+					SELECT 1 FROM exercise_attempts
+					WHERE exercise_attempts.user_id = users.id AND exercise_attempts.deleted_at IS NULL
+				) OR EXISTS (
+					SELECT 1 FROM activity_logs
+					WHERE activity_logs.user_id = users.id AND activity_logs.deleted_at IS NULL
+						AND activity_logs.activity_type IN ('exercise-attempt', 'exercise-completion')
+				) AS exercised,
+				EXISTS (
+					SELECT 1 FROM progress_events
+					WHERE progress_events.user_id = users.id AND progress_events.type = ?
+				) AS completed
+			FROM users
+			WHERE users.deleted_at IS NULL AND users.created_at >= ? AND users.created_at < ?
+		) AS journeys
+	`, ProgressEventCompleted, from, to).Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+
+	reached := []struct {
+		step  string
+		users int
+	}{
+		{FunnelSignedUp, counts.SignedUp},
+		{FunnelOnboarded, counts.Onboarded},
+		{FunnelFirstLecture, counts.FirstLecture},
+		{FunnelFirstExercise, counts.FirstExercise},
+		{FunnelFirstCompletion, counts.FirstCompletion},
+	}
+	steps := make([]FunnelStep, len(reached))
+	for i, r := range reached {
+		previous := counts.SignedUp
+		if i > 0 {
+			previous = reached[i-1].users
+		}
+		steps[i] = FunnelStep{
+			Step:       r.step,
+			Users:      r.users,
+			Conversion: shareOf(r.users, previous),
+			Overall:    shareOf(r.users, counts.SignedUp),
+		}
+	}
+	return steps, nil
+}
+
+// LoadTopicDropOff compares, for every topic at least minUsers learners started, how many completed
+// it with how many stopped learning altogether before completing it. Learners without any learning
+// activity since inactiveSince have dropped off. Topics are ordered by drop-off rate.
+func LoadTopicDropOff(db *gorm.DB, inactiveSince time.Time, minUsers, limit int) ([]TopicDropOff, error) {
+	var topics []TopicDropOff
+	err := db.Raw(`
+		WITH learners AS (
+			SELECT user_id, topic_id, bool_or(activity_type = 'topic-completion') AS completed
+			FROM activity_logs
+			WHERE deleted_at IS NULL AND topic_id IS NOT NULL
+				AND activity_type IN ('topic-view', 'topic-completion')
+			GROUP BY user_id, topic_id
+		), last_active AS (
+			SELECT user_id, MAX(date) AS day
+			FROM daily_activities
+			WHERE deleted_at IS NULL AND `+activeDayCondition+`
+			GROUP BY user_id
+		)
+		SELECT topics.id AS topic_id, topics.slug AS topic, topics.name,
+			COUNT(*) AS started,
+			COUNT(*) FILTER (WHERE learners.completed) AS completed,
+			COUNT(*) FILTER (WHERE NOT learners.completed AND (last_active.day IS NULL OR last_active.day < ?)) AS dropped_off
+		FROM learners
+		JOIN topics ON topics.id = learners.topic_id AND topics.deleted_at IS NULL
+		LEFT JOIN last_active ON last_active.user_id = learners.user_id
+		GROUP BY topics.id, topics.slug, topics.name
+		HAVING COUNT(*) >= ?
+		ORDER BY COUNT(*) FILTER (WHERE NOT learners.completed AND (last_active.day IS NULL OR last_active.day < ?))::float / COUNT(*) DESC,
+			COUNT(*) DESC
+		LIMIT ?
+	`, inactiveSince, minUsers, inactiveSince, limit).Scan(&topics).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range topics {
+		topics[i].CompletionRate = shareOf(topics[i].Completed, topics[i].Started)
+		topics[i].DropOffRate = shareOf(topics[i].DroppedOff, topics[i].Started)
+	}
+	return topics, nil
+}
+
+// shareOf returns part as a share of whole, rounded to three decimals
+func shareOf(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(whole)*1000) / 1000
+}
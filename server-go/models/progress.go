@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // TopicStatus represents the status of a topic in the learning progress
@@ -17,6 +18,8 @@ type TopicStatus struct {
 	QuizScore   int       `json:"quizScore"`
 	CodeScore   int       `json:"codeScore"`
 	LastViewed  time.Time `json:"lastViewed"`
+	Mastered    bool      `json:"mastered"` // Set only by passing an assessment
+	MasteredAt  time.Time `json:"masteredAt,omitempty"`
 }
 
 // TopicProgressMap is a map of topic names to their status
@@ -29,6 +32,11 @@ func (t TopicProgressMap) Value() (driver.Value, error) {
 
 // Scan implements the sql.Scanner interface for database deserialization
 func (t *TopicProgressMap) Scan(value interface{}) error {
+	if value == nil {
+		*t = make(TopicProgressMap)
+		return nil
+	}
+
 	bytes, ok := value.([]byte)
 	if !ok {
 		return fmt.Errorf("failed to unmarshal TopicProgressMap value: %v", value)
@@ -42,46 +50,167 @@ func (t *TopicProgressMap) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, t)
 }
 
-// UserProgress represents a user's learning progress
+// UserProgress represents a user's learning progress. The topics and counts are loaded from
+// user_topic_progress by LoadUserProgress.
 type UserProgress struct {
 	gorm.Model
 	UserID          uint             `gorm:"index;not null" json:"userId"`
-	TopicProgress   TopicProgressMap `gorm:"type:jsonb" json:"topicProgress"`
-	TotalTopics     int              `json:"totalTopics"`
-	CompletedTopics int              `json:"completedTopics"`
-	ViewedTopics    int              `json:"viewedTopics"`
+	TopicProgress   TopicProgressMap `gorm:"-" json:"topicProgress"`
+	TotalTopics     int              `gorm:"-" json:"totalTopics"`
+	CompletedTopics int              `gorm:"-" json:"completedTopics"`
+	ViewedTopics    int              `gorm:"-" json:"viewedTopics"`
 	LastActivity    time.Time        `json:"lastActivity"`
 }
 
-// BeforeCreate ensures fields are properly initialized
-func (up *UserProgress) BeforeCreate(tx *gorm.DB) error {
-	if up.TopicProgress == nil {
-		up.TopicProgress = make(TopicProgressMap)
+// UserTopicProgress is a user's progress in one topic
+type UserTopicProgress struct {
+	gorm.Model
+	UserID      uint       `gorm:"not null;uniqueIndex:idx_user_topic_progress_topic" json:"userId"`
+	Topic       string     `gorm:"size:200;not null" json:"topic"`                                             // Name as first recorded
+	TopicKey    string     `gorm:"size:200;not null;uniqueIndex:idx_user_topic_progress_topic;index" json:"-"` // Slug of the catalog topic
+	TopicID     *uint      `gorm:"index" json:"topicId,omitempty"`
+	Viewed      bool       `gorm:"not null;default:false" json:"viewed"`
+	Completed   bool       `gorm:"not null;default:false;index" json:"completed"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	QuizScore   int        `gorm:"not null;default:0" json:"quizScore"`
+	CodeScore   int        `gorm:"not null;default:0" json:"codeScore"`
+	LastViewed  *time.Time `json:"lastViewed,omitempty"`
+	Mastered    bool       `gorm:"not null;default:false" json:"mastered"` // Set only by passing an assessment
+	MasteredAt  *time.Time `json:"masteredAt,omitempty"`
+}
+
+// TableName keeps the table name singular, as the rows already are per topic
+func (UserTopicProgress) TableName() string {
+	return "user_topic_progress"
+}
+
+// Status converts the row to the status format of the progress API
+func (p UserTopicProgress) Status() TopicStatus {
+	status := TopicStatus{
+		Viewed:    p.Viewed,
+		Completed: p.Completed,
+		QuizScore: p.QuizScore,
+		CodeScore: p.CodeScore,
+		Mastered:  p.Mastered,
 	}
-	if up.LastActivity.IsZero() {
-		up.LastActivity = time.Now()
+	if p.CompletedAt != nil {
+		status.CompletedAt = *p.CompletedAt
 	}
-	return nil
+	if p.LastViewed != nil {
+		status.LastViewed = *p.LastViewed
+	}
+	if p.MasteredAt != nil {
+		status.MasteredAt = *p.MasteredAt
+	}
+	return status
 }
 
-// BeforeSave calculates aggregated statistics before saving
-func (up *UserProgress) BeforeSave(tx *gorm.DB) error {
-	completedCount := 0
-	viewedCount := 0
+// LoadUserProgress loads a user's progress in all topics, counting them in the database
+func LoadUserProgress(db *gorm.DB, userID uint) (UserProgress, error) {
+	var progress UserProgress
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&progress).Error; err != nil {
+		return progress, err
+	}
+	progress.UserID = userID
 
-	for _, status := range up.TopicProgress {
-		if status.Completed {
-			completedCount++
-		}
-		if status.Viewed {
-			viewedCount++
-		}
+	var topics []UserTopicProgress
+	if err := db.Where("user_id = ?", userID).Order("created_at ASC").Find(&topics).Error; err != nil {
+		return progress, err
+	}
+	progress.TopicProgress = make(TopicProgressMap, len(topics))
+	for _, topic := range topics {
+		progress.TopicProgress[topic.Topic] = topic.Status()
 	}
 
-	up.CompletedTopics = completedCount
-	up.ViewedTopics = viewedCount
-	up.TotalTopics = len(up.TopicProgress)
-	up.LastActivity = time.Now()
+	var counts struct {
+		Total        int
+		Completed    int
+		Viewed       int
+		LastActivity *time.Time
+	}
+	if err := db.Model(&UserTopicProgress{}).
+		Select("COUNT(*) AS total, COUNT(*) FILTER (WHERE completed) AS completed, COUNT(*) FILTER (WHERE viewed) AS viewed, MAX(updated_at) AS last_activity").
+		Where("user_id = ?", userID).
+		Scan(&counts).Error; err != nil {
+		return progress, err
+	}
+	progress.TotalTopics = counts.Total
+	progress.CompletedTopics = counts.Completed
+	progress.ViewedTopics = counts.Viewed
+	if counts.LastActivity != nil && counts.LastActivity.After(progress.LastActivity) {
+		progress.LastActivity = *counts.LastActivity
+	}
+	if progress.LastActivity.IsZero() {
+		progress.LastActivity = time.Now()
+	}
+
+	return progress, nil
+}
+
+// MigrateTopicProgressMap moves the topics of the former topic_progress JSONB column of
+// user_progresses into user_topic_progress and drops the column with its derived counts.
+// It does nothing once the column is gone.
+func MigrateTopicProgressMap(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&UserProgress{}, "topic_progress") {
+		return nil
+	}
+
+	var legacy []struct {
+		UserID        uint
+		TopicProgress TopicProgressMap
+	}
+	if err := db.Table("user_progresses").
+		Select("user_id, topic_progress").
+		Where("deleted_at IS NULL").
+		Scan(&legacy).Error; err != nil {
+		return err
+	}
+
+	var rows []UserTopicProgress
+	for _, progress := range legacy {
+		for topic, status := range progress.TopicProgress {
+			key := NormalizeTopicKey(topic)
+			if key == "" {
+				continue
+			}
+			row := UserTopicProgress{
+				UserID:    progress.UserID,
+				Topic:     topic,
+				TopicKey:  key,
+				Viewed:    status.Viewed,
+				Completed: status.Completed,
+				QuizScore: status.QuizScore,
+				CodeScore: status.CodeScore,
+				Mastered:  status.Mastered,
+			}
+			if !status.CompletedAt.IsZero() {
+				row.CompletedAt = &status.CompletedAt
+			}
+			if !status.LastViewed.IsZero() {
+				row.LastViewed = &status.LastViewed
+			}
+			if !status.MasteredAt.IsZero() {
+				row.MasteredAt = &status.MasteredAt
+			}
+			rows = append(rows, row)
+		}
+	}
 
-	return nil
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Topics that differ only in case or spacing keep the first one
+		if len(rows) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&rows, 500).Error; err != nil {
+				return err
+			}
+		}
+		for _, column := range []string{"topic_progress", "total_topics", "completed_topics", "viewed_topics"} {
+			if tx.Migrator().HasColumn(&UserProgress{}, column) {
+				if err := tx.Migrator().DropColumn(&UserProgress{}, column); err != nil {
+					return err
+				}
+			}
+		}
+		fmt.Printf("INFO: Migrated %d topics of %d users to user_topic_progress\n", len(rows), len(legacy))
+		return nil
+	})
 }
@@ -0,0 +1,208 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Progress event types
+const (
+	ProgressEventViewed      = "viewed"
+	ProgressEventCompleted   = "completed"
+	ProgressEventUncompleted = "uncompleted"
+	ProgressEventQuizScore   = "quiz-score"
+	ProgressEventCodeScore   = "code-score"
+	ProgressEventMastered    = "mastered"
+)
+
+// ProgressEvent is one change to a user's progress in a topic. Events are only ever appended; the
+// progress in user_topic_progress is derived from them, so it has no timestamps to update and no
+// soft delete.
+type ProgressEvent struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UserID        uint      `gorm:"not null;index:idx_progress_events_user_topic" json:"userId"`
+	TopicID       uint      `gorm:"not null;index:idx_progress_events_user_topic" json:"topicId"`
+	Type          string    `gorm:"size:20;not null" json:"type"`
+	Score         *int      `json:"score,omitempty"`                      // Set for quiz and code scores
+	Source        string    `gorm:"size:30;not null" json:"source"`       // What recorded the event, e.g. "progress", "exercise" or "undo"
+	UndoesEventID *uint     `gorm:"index" json:"undoesEventId,omitempty"` // The completion an undo reverts
+	OccurredAt    time.Time `gorm:"not null;index:idx_progress_events_user_topic" json:"occurredAt"`
+	Undone        bool      `gorm:"-" json:"undone"` // Whether a later event undid this one
+}
+
+// NewProgressEvent creates an event of the given type
+func NewProgressEvent(eventType, source string, at time.Time) ProgressEvent {
+	return ProgressEvent{Type: eventType, Source: source, OccurredAt: at}
+}
+
+// NewScoreEvent creates a quiz or code score event
+func NewScoreEvent(eventType, source string, score int, at time.Time) ProgressEvent {
+	event := NewProgressEvent(eventType, source, at)
+	event.Score = &score
+	return event
+}
+
+// Derive rebuilds the progress from the topic's events in the order they occurred. Views and
+// mastery accumulate, the latest completion or uncompletion decides whether the topic is completed,
+// and the latest scores are kept so a drop in performance shows.
+func (p *UserTopicProgress) Derive(events []ProgressEvent) {
+	p.Viewed, p.LastViewed = false, nil
+	p.Completed, p.CompletedAt = false, nil
+	p.QuizScore, p.CodeScore = 0, 0
+	p.Mastered, p.MasteredAt = false, nil
+
+	for _, event := range events {
+		at := event.OccurredAt
+		switch event.Type {
+		case ProgressEventViewed:
+			p.Viewed = true
+			if p.LastViewed == nil || at.After(*p.LastViewed) {
+				p.LastViewed = &at
+			}
+		case ProgressEventCompleted:
+			if !p.Completed {
+				p.Completed, p.CompletedAt = true, &at
+			}
+		case ProgressEventUncompleted:
+			p.Completed, p.CompletedAt = false, nil
+		case ProgressEventQuizScore:
+			if event.Score != nil {
+				p.QuizScore = *event.Score
+			}
+		case ProgressEventCodeScore:
+			if event.Score != nil {
+				p.CodeScore = *event.Score
+			}
+		case ProgressEventMastered:
+			if !p.Mastered {
+				p.Mastered, p.MasteredAt = true, &at
+			}
+		}
+	}
+}
+
+// LockTopicProgress returns a user's progress in a topic, creating it if there is none, and locks
+// the row until the transaction ends so events are appended one writer at a time
+func LockTopicProgress(tx *gorm.DB, userID uint, topic Topic) (UserTopicProgress, error) {
+	progress := UserTopicProgress{UserID: userID, Topic: topic.Name, TopicKey: topic.Slug, TopicID: &topic.ID}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "topic_key"}},
+		DoNothing: true,
+	}).Create(&progress).Error; err != nil {
+		return progress, err
+	}
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND topic_key = ?", userID, topic.Slug).
+		First(&progress).Error
+	return progress, err
+}
+
+// AppendProgressEvents appends events to a locked topic progress row and derives its state again
+// from the topic's whole event log
+func AppendProgressEvents(tx *gorm.DB, progress *UserTopicProgress, events ...ProgressEvent) error {
+	if progress.TopicID == nil {
+		return fmt.Errorf("topic progress %d has no catalog topic", progress.ID)
+	}
+
+	for i := range events {
+		events[i].UserID = progress.UserID
+		events[i].TopicID = *progress.TopicID
+	}
+	if len(events) > 0 {
+		if err := tx.Create(&events).Error; err != nil {
+			return err
+		}
+	}
+
+	log, err := LoadProgressEvents(tx, progress.UserID, *progress.TopicID)
+	if err != nil {
+		return err
+	}
+	progress.Derive(log)
+	return tx.Save(progress).Error
+}
+
+// LoadProgressEvents loads a user's events in a topic in the order they occurred, flagging the
+// completions that were undone
+func LoadProgressEvents(db *gorm.DB, userID, topicID uint) ([]ProgressEvent, error) {
+	var events []ProgressEvent
+	if err := db.Where("user_id = ? AND topic_id = ?", userID, topicID).
+		Order("occurred_at ASC, id ASC").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	undone := make(map[uint]bool)
+	for _, event := range events {
+		if event.UndoesEventID != nil {
+			undone[*event.UndoesEventID] = true
+		}
+	}
+	for i := range events {
+		events[i].Undone = undone[events[i].ID]
+	}
+	return events, nil
+}
+
+// MigrateProgressEvents gives topic progress recorded before the event log a history, turning each
+// row into the events that derive it. Rows that already have events are left alone, so it does
+// nothing once every row has been converted.
+func MigrateProgressEvents(db *gorm.DB) error {
+	var rows []UserTopicProgress
+	if err := db.Where("topic_id IS NOT NULL AND NOT EXISTS (?)",
+		db.Model(&ProgressEvent{}).Select("1").Where("progress_events.user_id = user_topic_progress.user_id AND progress_events.topic_id = user_topic_progress.topic_id"),
+	).Find(&rows).Error; err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			var events []ProgressEvent
+			if row.Viewed {
+				at := row.CreatedAt
+				if row.LastViewed != nil {
+					at = *row.LastViewed
+				}
+				events = append(events, NewProgressEvent(ProgressEventViewed, "migration", at))
+			}
+			if row.QuizScore > 0 {
+				events = append(events, NewScoreEvent(ProgressEventQuizScore, "migration", row.QuizScore, row.UpdatedAt))
+			}
+			if row.CodeScore > 0 {
+				events = append(events, NewScoreEvent(ProgressEventCodeScore, "migration", row.CodeScore, row.UpdatedAt))
+			}
+			if row.Completed {
+				at := row.UpdatedAt
+				if row.CompletedAt != nil {
+					at = *row.CompletedAt
+				}
+				events = append(events, NewProgressEvent(ProgressEventCompleted, "migration", at))
+			}
+			if row.Mastered {
+				at := row.UpdatedAt
+				if row.MasteredAt != nil {
+					at = *row.MasteredAt
+				}
+				events = append(events, NewProgressEvent(ProgressEventMastered, "migration", at))
+			}
+			if len(events) == 0 {
+				continue
+			}
+
+			if err := AppendProgressEvents(tx, &row, events...); err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("INFO: Recorded the progress history of %d topics as events\n", len(rows))
+		return nil
+	})
+}
@@ -4,27 +4,121 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"time"
 
+	"github.com/lib/pq"
 	"gorm.io/gorm"
 )
 
 // Roadmap represents a learning roadmap
 type Roadmap struct {
 	gorm.Model
-	Topic  string        `gorm:"size:100;not null" json:"topic"`
-	UserID uint          `json:"userId"`
-	User   User          `gorm:"foreignKey:UserID" json:"-"`
-	Steps  []RoadmapStep `gorm:"foreignKey:RoadmapID" json:"steps"`
+	Topic      string        `gorm:"size:100;not null" json:"topic"`
+	TopicID    *uint         `gorm:"index" json:"topicId,omitempty"` // Catalog topic the title resolves to
+	UserID     uint          `json:"userId"`
+	User       User          `gorm:"foreignKey:UserID" json:"-"`
+	TemplateID *uint         `gorm:"index" json:"templateId,omitempty"` // Template the roadmap was forked from
+	Steps      []RoadmapStep `gorm:"foreignKey:RoadmapID" json:"steps"`
+}
+
+// BeforeSave resolves the roadmap's title against the topic catalog
+func (r *Roadmap) BeforeSave(tx *gorm.DB) error {
+	// A title without letters or digits is still a valid roadmap, it just has no catalog topic
+	if TopicSlug(r.Topic) == "" {
+		return nil
+	}
+
+	topic, err := ResolveTopic(tx, r.Topic)
+	if err != nil {
+		return err
+	}
+	r.TopicID = &topic.ID
+	return nil
 }
 
 // RoadmapStep represents a step in a learning roadmap
 type RoadmapStep struct {
 	gorm.Model
-	Name      string  `gorm:"size:100;not null" json:"name"`
-	Order     int     `gorm:"not null" json:"order"`
-	RoadmapID uint    `json:"roadmapId"`
-	Roadmap   Roadmap `gorm:"foreignKey:RoadmapID" json:"-"`
-	Completed bool    `gorm:"not null;default:false" json:"completed"`
+	Name           string         `gorm:"size:200;not null" json:"name"`
+	Key            string         `gorm:"size:50" json:"key"` // Identifier of the step within its roadmap, used by prerequisite edges
+	Order          int            `gorm:"not null" json:"order"`
+	RoadmapID      uint           `json:"roadmapId"`
+	Roadmap        Roadmap        `gorm:"foreignKey:RoadmapID" json:"-"`
+	Description    string         `gorm:"type:text" json:"description,omitempty"`
+	EstimatedHours float64        `json:"estimatedHours"`
+	Objectives     pq.StringArray `gorm:"type:text[]" json:"objectives"`
+	Resources      StepResources  `gorm:"type:jsonb" json:"resources"`
+	Completed      bool           `gorm:"not null;default:false" json:"completed"`
+	CompletedAt    *time.Time     `json:"completedAt,omitempty"`
+	Mastered       bool           `gorm:"not null;default:false" json:"mastered"` // Set only by passing an assessment
+	MasteredAt     *time.Time     `json:"masteredAt,omitempty"`
+}
+
+// RoadmapCompletion summarizes how much of a roadmap is completed
+type RoadmapCompletion struct {
+	CompletedSteps int     `json:"completedSteps"`
+	TotalSteps     int     `json:"totalSteps"`
+	Percent        float64 `json:"percent"` // Percentage from 0-100
+}
+
+// CalculateRoadmapCompletion counts the completed steps of a roadmap
+func CalculateRoadmapCompletion(steps []RoadmapStep) RoadmapCompletion {
+	completion := RoadmapCompletion{TotalSteps: len(steps)}
+	for _, step := range steps {
+		if step.Completed {
+			completion.CompletedSteps++
+		}
+	}
+	if completion.TotalSteps > 0 {
+		completion.Percent = float64(completion.CompletedSteps) / float64(completion.TotalSteps) * 100
+	}
+	return completion
+}
+
+// RoadmapStepPrerequisite is an edge of the roadmap graph: StepID cannot start before PrerequisiteID
+type RoadmapStepPrerequisite struct {
+	gorm.Model
+	RoadmapID      uint `gorm:"index;not null" json:"roadmapId"`
+	StepID         uint `gorm:"not null;uniqueIndex:idx_roadmap_step_prerequisites_edge" json:"stepId"`
+	PrerequisiteID uint `gorm:"not null;uniqueIndex:idx_roadmap_step_prerequisites_edge" json:"prerequisiteId"`
+}
+
+// StepResource represents a learning resource recommended for a roadmap step
+type StepResource struct {
+	Title string `json:"title" yaml:"title"`
+	URL   string `json:"url,omitempty" yaml:"url,omitempty"`
+	Type  string `json:"type,omitempty" yaml:"type,omitempty"` // article, video, documentation, course, book, practice
+}
+
+// StepResources is a slice of StepResource
+type StepResources []StepResource
+
+// Value implements the driver.Valuer interface for database serialization
+func (sr StepResources) Value() (driver.Value, error) {
+	if sr == nil {
+		return json.Marshal([]StepResource{})
+	}
+	return json.Marshal(sr)
+}
+
+// Scan implements the sql.Scanner interface for database deserialization
+func (sr *StepResources) Scan(value interface{}) error {
+	if value == nil {
+		*sr = StepResources{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal StepResources value: %v", value)
+	}
+
+	if len(bytes) == 0 {
+		*sr = StepResources{}
+		return nil
+	}
+
+	return json.Unmarshal(bytes, sr)
 }
 
 // RecommendedTopic represents a personalized recommended topic
@@ -0,0 +1,258 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RoadmapDocumentVersion is the version of the portable roadmap format written by exports
+const RoadmapDocumentVersion = 1
+
+const (
+	maxDocumentSteps          = 100
+	maxDocumentEstimatedHours = 200.0
+)
+
+// RoadmapDocument is the portable form of a roadmap used for import and export
+type RoadmapDocument struct {
+	Version    int                   `json:"version" yaml:"version"`
+	Topic      string                `json:"topic" yaml:"topic"`
+	ExportedAt *time.Time            `json:"exportedAt,omitempty" yaml:"exportedAt,omitempty"`
+	Steps      []RoadmapDocumentStep `json:"steps" yaml:"steps"`
+}
+
+// RoadmapDocumentStep is a step of a roadmap document. Steps are listed in order and
+// refer to their prerequisites by id.
+type RoadmapDocumentStep struct {
+	ID             string         `json:"id" yaml:"id"`
+	Name           string         `json:"name" yaml:"name"`
+	Description    string         `json:"description,omitempty" yaml:"description,omitempty"`
+	EstimatedHours float64        `json:"estimatedHours,omitempty" yaml:"estimatedHours,omitempty"`
+	Objectives     []string       `json:"objectives,omitempty" yaml:"objectives,omitempty"`
+	Resources      []StepResource `json:"resources,omitempty" yaml:"resources,omitempty"`
+	Prerequisites  []string       `json:"prerequisites,omitempty" yaml:"prerequisites,omitempty"`
+	Completed      bool           `json:"completed,omitempty" yaml:"completed,omitempty"`
+}
+
+// DocumentFieldError describes a problem with one field of an imported document
+type DocumentFieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// NewRoadmapDocument exports a roadmap with its ordered steps and prerequisite edges. Steps are
+// listed in dependency order, as Validate requires, keeping their order among independent steps.
+func NewRoadmapDocument(roadmap Roadmap, steps []RoadmapStep, edges []RoadmapGraphEdge) RoadmapDocument {
+	prerequisites := make(map[string][]string)
+	for _, edge := range edges {
+		prerequisites[edge.To] = append(prerequisites[edge.To], edge.From)
+	}
+	steps = SortStepsByPrerequisites(steps, edges)
+
+	now := time.Now().UTC()
+	document := RoadmapDocument{
+		Version:    RoadmapDocumentVersion,
+		Topic:      roadmap.Topic,
+		ExportedAt: &now,
+		Steps:      make([]RoadmapDocumentStep, 0, len(steps)),
+	}
+	for _, step := range steps {
+		key := StepKey(step)
+		document.Steps = append(document.Steps, RoadmapDocumentStep{
+			ID:             key,
+			Name:           step.Name,
+			Description:    step.Description,
+			EstimatedHours: step.EstimatedHours,
+			Objectives:     []string(step.Objectives),
+			Resources:      step.Resources,
+			Prerequisites:  prerequisites[key],
+			Completed:      step.Completed,
+		})
+	}
+	return document
+}
+
+// Validate checks the document and returns one error per invalid field.
+// Prerequisites must be listed before the steps that need them, which also rules out cycles.
+func (d RoadmapDocument) Validate() []DocumentFieldError {
+	var errs []DocumentFieldError
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, DocumentFieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case d.Version == 0:
+		fail("version", "is required")
+	case d.Version != RoadmapDocumentVersion:
+		fail("version", "unsupported version %d, expected %d", d.Version, RoadmapDocumentVersion)
+	}
+
+	topic := strings.TrimSpace(d.Topic)
+	switch {
+	case topic == "":
+		fail("topic", "is required")
+	case len([]rune(topic)) > 100:
+		fail("topic", "must be at most 100 characters")
+	}
+
+	switch {
+	case len(d.Steps) == 0:
+		fail("steps", "must contain at least one step")
+	case len(d.Steps) > maxDocumentSteps:
+		fail("steps", "must contain at most %d steps", maxDocumentSteps)
+	}
+
+	position := make(map[string]int, len(d.Steps))
+	for i, step := range d.Steps {
+		field := "steps[" + strconv.Itoa(i) + "]"
+		id := strings.TrimSpace(step.ID)
+
+		switch {
+		case id == "":
+			fail(field+".id", "is required")
+		case len([]rune(id)) > 50:
+			fail(field+".id", "must be at most 50 characters")
+		default:
+			if previous, duplicate := position[id]; duplicate {
+				fail(field+".id", "%q is already used by steps[%d]", id, previous)
+			} else {
+				position[id] = i
+			}
+		}
+
+		name := strings.TrimSpace(step.Name)
+		switch {
+		case name == "":
+			fail(field+".name", "is required")
+		case len([]rune(name)) > 200:
+			fail(field+".name", "must be at most 200 characters")
+		}
+
+		if step.EstimatedHours < 0 || step.EstimatedHours > maxDocumentEstimatedHours {
+			fail(field+".estimatedHours", "must be between 0 and %g", maxDocumentEstimatedHours)
+		}
+
+		for j, objective := range step.Objectives {
+			if strings.TrimSpace(objective) == "" {
+				fail(field+".objectives["+strconv.Itoa(j)+"]", "must not be empty")
+			}
+		}
+
+		for j, resource := range step.Resources {
+			resourceField := field + ".resources[" + strconv.Itoa(j) + "]"
+			if strings.TrimSpace(resource.Title) == "" {
+				fail(resourceField+".title", "is required")
+			}
+			if resource.URL != "" {
+				parsed, err := url.Parse(resource.URL)
+				if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+					fail(resourceField+".url", "must be an http or https URL")
+				}
+			}
+		}
+	}
+
+	for i, step := range d.Steps {
+		for j, prerequisite := range step.Prerequisites {
+			field := "steps[" + strconv.Itoa(i) + "].prerequisites[" + strconv.Itoa(j) + "]"
+			at, known := position[strings.TrimSpace(prerequisite)]
+			switch {
+			case !known:
+				fail(field, "unknown step id %q", prerequisite)
+			case at == i:
+				fail(field, "a step cannot be its own prerequisite")
+			case at > i:
+				fail(field, "%q must be listed before the step that requires it", prerequisite)
+			}
+		}
+	}
+
+	return errs
+}
+
+// RoadmapSteps converts a valid document into unsaved roadmap steps and prerequisite edges
+func (d RoadmapDocument) RoadmapSteps() ([]RoadmapStep, []RoadmapGraphEdge) {
+	steps := make([]RoadmapStep, 0, len(d.Steps))
+	var edges []RoadmapGraphEdge
+	seen := make(map[RoadmapGraphEdge]bool)
+	now := time.Now()
+
+	for i, documentStep := range d.Steps {
+		key := strings.TrimSpace(documentStep.ID)
+		step := RoadmapStep{
+			Key:            key,
+			Name:           strings.TrimSpace(documentStep.Name),
+			Description:    strings.TrimSpace(documentStep.Description),
+			EstimatedHours: documentStep.EstimatedHours,
+			Objectives:     pq.StringArray(documentStep.Objectives),
+			Resources:      StepResources(documentStep.Resources),
+			Order:          i + 1,
+			Completed:      documentStep.Completed,
+		}
+		if step.Completed {
+			step.CompletedAt = &now
+		}
+		steps = append(steps, step)
+
+		for _, prerequisite := range documentStep.Prerequisites {
+			edge := RoadmapGraphEdge{From: strings.TrimSpace(prerequisite), To: key}
+			if !seen[edge] {
+				seen[edge] = true
+				edges = append(edges, edge)
+			}
+		}
+	}
+
+	return steps, edges
+}
+
+// Markdown renders the document as a Markdown checklist
+func (d RoadmapDocument) Markdown() string {
+	names := make(map[string]string, len(d.Steps))
+	for _, step := range d.Steps {
+		names[step.ID] = step.Name
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", d.Topic)
+
+	for _, step := range d.Steps {
+		check := " "
+		if step.Completed {
+			check = "x"
+		}
+		fmt.Fprintf(&b, "- [%s] **%s**", check, step.Name)
+		if step.EstimatedHours > 0 {
+			fmt.Fprintf(&b, " (~%gh)", step.EstimatedHours)
+		}
+		b.WriteString("\n")
+
+		if step.Description != "" {
+			fmt.Fprintf(&b, "  %s\n", step.Description)
+		}
+		if len(step.Prerequisites) > 0 {
+			required := make([]string, 0, len(step.Prerequisites))
+			for _, prerequisite := range step.Prerequisites {
+				required = append(required, names[prerequisite])
+			}
+			fmt.Fprintf(&b, "  - Requires: %s\n", strings.Join(required, ", "))
+		}
+		for _, objective := range step.Objectives {
+			fmt.Fprintf(&b, "  - %s\n", objective)
+		}
+		for _, resource := range step.Resources {
+			if resource.URL != "" {
+				fmt.Fprintf(&b, "  - [%s](%s)\n", resource.Title, resource.URL)
+			} else {
+				fmt.Fprintf(&b, "  - %s\n", resource.Title)
+			}
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// PaceWindowWeeks is how many recent weeks of learning activity determine the pace
+	PaceWindowWeeks = 8
+	// minPaceCompletions is how many completed steps are needed before they calibrate the pace
+	minPaceCompletions = 2
+	// Bounds of the step hours completed per hour of learning, so a few outliers cannot skew it
+	minPaceEfficiency = 0.5
+	maxPaceEfficiency = 2.0
+)
+
+// LearningPace describes how much roadmap work a learner gets done per week
+type LearningPace struct {
+	WeeklyHours float64 `json:"weeklyHours"` // Average hours of learning per week
+	Deviation   float64 `json:"deviation"`   // Standard deviation of the weekly hours
+	Efficiency  float64 `json:"efficiency"`  // Estimated step hours completed per hour of learning
+	ActiveWeeks int     `json:"activeWeeks"` // Weeks with any learning activity
+	Basis       string  `json:"basis"`       // "activity", "plan" or "none"
+}
+
+// StepCompletion is a completed step, weighted by its estimated hours
+type StepCompletion struct {
+	Hours float64
+	At    time.Time
+}
+
+// RoadmapETA is the predicted completion of a roadmap
+type RoadmapETA struct {
+	Finished            bool       `json:"finished"`
+	RemainingHours      float64    `json:"remainingHours"`                // Estimated hours of the incomplete steps
+	WeeklyHours         float64    `json:"weeklyHours"`                   // Estimated step hours completed per week
+	EstimatedDate       *time.Time `json:"estimatedDate,omitempty"`       // Most likely completion date
+	EarliestDate        *time.Time `json:"earliestDate,omitempty"`        // Completion date at a good week's pace
+	LatestDate          *time.Time `json:"latestDate,omitempty"`          // Completion date at a slow week's pace
+	Confidence          string     `json:"confidence"`                    // "low", "medium" or "high"
+	Basis               string     `json:"basis"`                         // What the pace is based on
+	TargetDate          *time.Time `json:"targetDate,omitempty"`          // The learner's target date, if any
+	RequiredWeeklyHours float64    `json:"requiredWeeklyHours,omitempty"` // Learning hours per week needed to meet the target
+	Behind              bool       `json:"behind"`
+	DaysBehind          int        `json:"daysBehind,omitempty"`
+}
+
+// EstimateLearningPace derives the weekly pace from the daily activity of the last PaceWindowWeeks
+// weeks. Completed steps calibrate how much of the estimated step effort an hour of learning covers.
+func EstimateLearningPace(activity []DailyActivity, completions []StepCompletion, now time.Time) LearningPace {
+	pace := LearningPace{Efficiency: 1, Basis: "none"}
+	windowStart := now.AddDate(0, 0, -7*PaceWindowWeeks)
+
+	// Learners who started recently are measured from their first active day
+	first := now
+	for _, day := range activity {
+		if day.LearningTimeMin > 0 && !day.Date.Before(windowStart) && day.Date.Before(first) {
+			first = day.Date
+		}
+	}
+	weeks := int(math.Ceil(now.Sub(first).Hours() / (24 * 7)))
+	if weeks < 1 {
+		weeks = 1
+	}
+	weeks = min(weeks, PaceWindowWeeks)
+
+	weekly := make([]float64, weeks)
+	totalHours := 0.0
+	for _, day := range activity {
+		if day.LearningTimeMin <= 0 || day.Date.Before(windowStart) || day.Date.After(now) {
+			continue
+		}
+		week := int(now.Sub(day.Date).Hours() / (24 * 7))
+		if week >= weeks {
+			continue
+		}
+		hours := float64(day.LearningTimeMin) / 60
+		weekly[week] += hours
+		totalHours += hours
+	}
+
+	for _, hours := range weekly {
+		if hours > 0 {
+			pace.ActiveWeeks++
+		}
+	}
+	if pace.ActiveWeeks == 0 {
+		return pace
+	}
+
+	pace.Basis = "activity"
+	pace.WeeklyHours = totalHours / float64(weeks)
+	variance := 0.0
+	for _, hours := range weekly {
+		variance += (hours - pace.WeeklyHours) * (hours - pace.WeeklyHours)
+	}
+	pace.Deviation = math.Sqrt(variance / float64(weeks))
+
+	completedHours := 0.0
+	count := 0
+	for _, completion := range completions {
+		if !completion.At.Before(windowStart) && !completion.At.After(now) {
+			completedHours += completion.Hours
+			count++
+		}
+	}
+	if count >= minPaceCompletions && totalHours > 0 {
+		pace.Efficiency = math.Min(math.Max(completedHours/totalHours, minPaceEfficiency), maxPaceEfficiency)
+	}
+
+	return pace
+}
+
+// PredictRoadmapCompletion predicts when the remaining steps of a roadmap are completed at the
+// given pace, and whether that is later than the target date. Dates further away than the
+// scheduling horizon are nil.
+func PredictRoadmapCompletion(steps []RoadmapStep, pace LearningPace, now time.Time, target *time.Time) RoadmapETA {
+	eta := RoadmapETA{Basis: pace.Basis, Confidence: "low", TargetDate: target}
+	for _, step := range steps {
+		if !step.Completed {
+			eta.RemainingHours += StepHours(step)
+		}
+	}
+	if eta.RemainingHours == 0 {
+		eta.Finished = true
+		eta.Confidence = "high"
+		return eta
+	}
+
+	// Learning hours per week needed to finish the remaining steps by the end of the target day
+	if target != nil {
+		deadline := target.AddDate(0, 0, 1)
+		if weeksLeft := deadline.Sub(now).Hours() / (24 * 7); weeksLeft > 0 {
+			eta.RequiredWeeklyHours = math.Round(eta.RemainingHours/pace.Efficiency/weeksLeft*10) / 10
+		} else {
+			eta.Behind = true
+			eta.DaysBehind = int(math.Ceil(now.Sub(deadline).Hours() / 24))
+		}
+	}
+
+	eta.WeeklyHours = pace.WeeklyHours * pace.Efficiency
+	if eta.WeeklyHours <= 0 {
+		return eta
+	}
+
+	// Dates past the scheduling horizon are left out rather than overflowing time.Duration
+	finishAt := func(weeklyHours float64) *time.Time {
+		days := eta.RemainingHours / weeklyHours * 7
+		if weeklyHours <= 0 || days > maxScheduleDays {
+			return nil
+		}
+		date := now.Add(time.Duration(days * 24 * float64(time.Hour)))
+		return &date
+	}
+	fast := (pace.WeeklyHours + pace.Deviation) * pace.Efficiency
+	// A slow week still counts for something, otherwise one idle week would push the range to infinity
+	slow := math.Max(pace.WeeklyHours-pace.Deviation, pace.WeeklyHours/3) * pace.Efficiency
+	eta.EstimatedDate = finishAt(eta.WeeklyHours)
+	eta.EarliestDate = finishAt(fast)
+	eta.LatestDate = finishAt(slow)
+
+	switch {
+	case pace.Basis != "activity":
+		eta.Confidence = "low"
+	case pace.ActiveWeeks >= 4 && pace.Deviation <= pace.WeeklyHours/2:
+		eta.Confidence = "high"
+	case pace.ActiveWeeks >= 2:
+		eta.Confidence = "medium"
+	}
+
+	if target != nil && !eta.Behind {
+		deadline := target.AddDate(0, 0, 1)
+		if eta.EstimatedDate == nil {
+			eta.Behind = true
+		} else if eta.EstimatedDate.After(deadline) {
+			eta.Behind = true
+			eta.DaysBehind = int(math.Ceil(eta.EstimatedDate.Sub(deadline).Hours() / 24))
+		}
+	}
+
+	return eta
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+var etaNow = time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+
+// weeklyActivity returns one active day in each of the last weeks weeks
+func weeklyActivity(weeks, minutes int) []DailyActivity {
+	var activity []DailyActivity
+	for week := 0; week < weeks; week++ {
+		activity = append(activity, DailyActivity{Date: etaNow.AddDate(0, 0, -7*week-1), LearningTimeMin: minutes})
+	}
+	return activity
+}
+
+func TestEstimateLearningPace(t *testing.T) {
+	tests := []struct {
+		name        string
+		activity    []DailyActivity
+		completions []StepCompletion
+		want        LearningPace
+	}{
+		{
+			name: "no activity",
+			want: LearningPace{Efficiency: 1, Basis: "none"},
+		},
+		{
+			name:     "activity before the window",
+			activity: []DailyActivity{{Date: etaNow.AddDate(0, 0, -7*PaceWindowWeeks-1), LearningTimeMin: 120}},
+			want:     LearningPace{Efficiency: 1, Basis: "none"},
+		},
+		{
+			name:     "steady weeks",
+			activity: weeklyActivity(PaceWindowWeeks, 120),
+			want:     LearningPace{WeeklyHours: 2, Efficiency: 1, ActiveWeeks: PaceWindowWeeks, Basis: "activity"},
+		},
+		{
+			name:     "recent start is measured from the first active day",
+			activity: []DailyActivity{{Date: etaNow.AddDate(0, 0, -2), LearningTimeMin: 90}},
+			want:     LearningPace{WeeklyHours: 1.5, Efficiency: 1, ActiveWeeks: 1, Basis: "activity"},
+		},
+		{
+			name:     "one completion does not calibrate the efficiency",
+			activity: weeklyActivity(PaceWindowWeeks, 120),
+			completions: []StepCompletion{
+				{Hours: 8, At: etaNow.AddDate(0, 0, -3)},
+			},
+			want: LearningPace{WeeklyHours: 2, Efficiency: 1, ActiveWeeks: PaceWindowWeeks, Basis: "activity"},
+		},
+		{
+			name:     "completions calibrate the efficiency",
+			activity: weeklyActivity(PaceWindowWeeks, 120),
+			completions: []StepCompletion{
+				{Hours: 4, At: etaNow.AddDate(0, 0, -3)},
+				{Hours: 4, At: etaNow.AddDate(0, 0, -10)},
+			},
+			want: LearningPace{WeeklyHours: 2, Efficiency: 0.5, ActiveWeeks: PaceWindowWeeks, Basis: "activity"},
+		},
+		{
+			name:     "efficiency is capped",
+			activity: weeklyActivity(PaceWindowWeeks, 120),
+			completions: []StepCompletion{
+				{Hours: 20, At: etaNow.AddDate(0, 0, -3)},
+				{Hours: 20, At: etaNow.AddDate(0, 0, -10)},
+			},
+			want: LearningPace{WeeklyHours: 2, Efficiency: maxPaceEfficiency, ActiveWeeks: PaceWindowWeeks, Basis: "activity"},
+		},
+		{
+			name: "uneven weeks",
+			activity: []DailyActivity{
+				{Date: etaNow.AddDate(0, 0, -1), LearningTimeMin: 180},
+				{Date: etaNow.AddDate(0, 0, -8), LearningTimeMin: 60},
+			},
+			want: LearningPace{WeeklyHours: 2, Deviation: 1, Efficiency: 1, ActiveWeeks: 2, Basis: "activity"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateLearningPace(tt.activity, tt.completions, etaNow); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPredictRoadmapCompletion(t *testing.T) {
+	days := func(n int) *time.Time {
+		date := etaNow.AddDate(0, 0, n)
+		return &date
+	}
+	steady := LearningPace{WeeklyHours: 2, Efficiency: 1, ActiveWeeks: PaceWindowWeeks, Basis: "activity"}
+	twoSteps := []RoadmapStep{{EstimatedHours: 2}, {EstimatedHours: 2}}
+
+	tests := []struct {
+		name       string
+		steps      []RoadmapStep
+		pace       LearningPace
+		target     *time.Time
+		finished   bool
+		estimated  *time.Time
+		latest     *time.Time
+		confidence string
+		behind     bool
+		daysBehind int
+	}{
+		{
+			name:       "finished",
+			steps:      []RoadmapStep{{Completed: true}},
+			pace:       steady,
+			finished:   true,
+			confidence: "high",
+		},
+		{
+			name:       "no pace",
+			steps:      twoSteps,
+			pace:       LearningPace{Efficiency: 1, Basis: "none"},
+			confidence: "low",
+		},
+		{
+			name:       "steady pace",
+			steps:      append([]RoadmapStep{{EstimatedHours: 6, Completed: true}}, twoSteps...),
+			pace:       steady,
+			estimated:  days(14),
+			latest:     days(14),
+			confidence: "high",
+		},
+		{
+			name:       "steps without an estimate",
+			steps:      []RoadmapStep{{}, {}},
+			pace:       steady,
+			estimated:  days(14),
+			latest:     days(14),
+			confidence: "high",
+		},
+		{
+			name:       "uneven pace widens the range",
+			steps:      twoSteps,
+			pace:       LearningPace{WeeklyHours: 2, Deviation: 1, Efficiency: 1, ActiveWeeks: 2, Basis: "activity"},
+			estimated:  days(14),
+			latest:     days(28),
+			confidence: "medium",
+		},
+		{
+			name:       "on track for the target",
+			steps:      twoSteps,
+			pace:       steady,
+			target:     days(30),
+			estimated:  days(14),
+			latest:     days(14),
+			confidence: "high",
+		},
+		{
+			name:       "behind the target",
+			steps:      twoSteps,
+			pace:       steady,
+			target:     days(10),
+			estimated:  days(14),
+			latest:     days(14),
+			confidence: "high",
+			behind:     true,
+			daysBehind: 3,
+		},
+		{
+			name:       "target passed",
+			steps:      twoSteps,
+			pace:       steady,
+			target:     days(-3),
+			estimated:  days(14),
+			latest:     days(14),
+			confidence: "high",
+			behind:     true,
+			daysBehind: 2,
+		},
+		{
+			name:       "beyond the horizon",
+			steps:      []RoadmapStep{{EstimatedHours: 1000}},
+			pace:       LearningPace{WeeklyHours: 1e-9, Efficiency: 1, ActiveWeeks: 1, Basis: "activity"},
+			target:     days(30),
+			confidence: "low",
+			behind:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eta := PredictRoadmapCompletion(tt.steps, tt.pace, etaNow, tt.target)
+			if eta.Finished != tt.finished || eta.Confidence != tt.confidence {
+				t.Errorf("finished %v confidence %q, want %v %q", eta.Finished, eta.Confidence, tt.finished, tt.confidence)
+			}
+			if !sameDate(eta.EstimatedDate, tt.estimated) {
+				t.Errorf("estimated date %v, want %v", eta.EstimatedDate, tt.estimated)
+			}
+			if !sameDate(eta.LatestDate, tt.latest) {
+				t.Errorf("latest date %v, want %v", eta.LatestDate, tt.latest)
+			}
+			if eta.Behind != tt.behind || eta.DaysBehind != tt.daysBehind {
+				t.Errorf("behind %v by %d days, want %v by %d", eta.Behind, eta.DaysBehind, tt.behind, tt.daysBehind)
+			}
+		})
+	}
+}
+
+func sameDate(got, want *time.Time) bool {
+	if got == nil || want == nil {
+		return got == nil && want == nil
+	}
+	return got.Equal(*want)
+}
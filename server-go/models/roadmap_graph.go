@@ -0,0 +1,173 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrRoadmapCycle is returned when roadmap prerequisites do not form a DAG
+var ErrRoadmapCycle = errors.New("roadmap prerequisites contain a cycle")
+
+// RoadmapGraphNode is a roadmap step positioned for graph layout
+type RoadmapGraphNode struct {
+	ID             string        `json:"id"`
+	StepID         uint          `json:"stepId,omitempty"`
+	Name           string        `json:"name"`
+	Description    string        `json:"description,omitempty"`
+	EstimatedHours float64       `json:"estimatedHours"`
+	Objectives     []string      `json:"objectives"`
+	Resources      StepResources `json:"resources"`
+	Order          int           `json:"order"`
+	Level          int           `json:"level"` // Length of the longest prerequisite chain leading to the step
+	Completed      bool          `json:"completed"`
+	Mastered       bool          `json:"mastered"`
+}
+
+// RoadmapGraphEdge points from a prerequisite to the step that depends on it
+type RoadmapGraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// RoadmapGraph is a roadmap in a form the frontend can lay out as a graph
+type RoadmapGraph struct {
+	Nodes []RoadmapGraphNode `json:"nodes"`
+	Edges []RoadmapGraphEdge `json:"edges"`
+}
+
+// StepKey returns the graph identifier of a step, falling back to its ID for steps stored without a key
+func StepKey(step RoadmapStep) string {
+	if step.Key != "" {
+		return step.Key
+	}
+	return fmt.Sprintf("step-%d", step.ID)
+}
+
+// SortRoadmapDAG orders step keys so every step comes after its prerequisites, keeping the
+// given order among independent steps. It also returns the level of each step and fails
+// with ErrRoadmapCycle if the edges contain a cycle.
+func SortRoadmapDAG(keys []string, edges []RoadmapGraphEdge) ([]string, map[string]int, error) {
+	inDegree := make(map[string]int, len(keys))
+	dependents := make(map[string][]string, len(keys))
+	for _, key := range keys {
+		inDegree[key] = 0
+	}
+	for _, edge := range edges {
+		dependents[edge.From] = append(dependents[edge.From], edge.To)
+		inDegree[edge.To]++
+	}
+
+	sorted := make([]string, 0, len(keys))
+	levels := make(map[string]int, len(keys))
+	done := make(map[string]bool, len(keys))
+
+	for len(sorted) < len(keys) {
+		next := ""
+		for _, key := range keys {
+			if !done[key] && inDegree[key] == 0 {
+				next = key
+				break
+			}
+		}
+		if next == "" {
+			return nil, nil, ErrRoadmapCycle
+		}
+
+		done[next] = true
+		sorted = append(sorted, next)
+		for _, dependent := range dependents[next] {
+			inDegree[dependent]--
+			if levels[next]+1 > levels[dependent] {
+				levels[dependent] = levels[next] + 1
+			}
+		}
+	}
+
+	return sorted, levels, nil
+}
+
+// SortStepsByPrerequisites orders steps so every step comes after its prerequisites, keeping the
+// given order among independent steps. Stored graphs have no cycles; should one slip in, the steps
+// keep the given order.
+func SortStepsByPrerequisites(steps []RoadmapStep, edges []RoadmapGraphEdge) []RoadmapStep {
+	keys := make([]string, len(steps))
+	byKey := make(map[string]RoadmapStep, len(steps))
+	for i, step := range steps {
+		keys[i] = StepKey(step)
+		byKey[keys[i]] = step
+	}
+	sorted, _, err := SortRoadmapDAG(keys, edges)
+	if err != nil {
+		return steps
+	}
+
+	ordered := make([]RoadmapStep, 0, len(sorted))
+	for _, key := range sorted {
+		ordered = append(ordered, byKey[key])
+	}
+	return ordered
+}
+
+// PrerequisiteEdges converts stored prerequisite rows into graph edges between step keys
+func PrerequisiteEdges(steps []RoadmapStep, prerequisites []RoadmapStepPrerequisite) []RoadmapGraphEdge {
+	keysByID := make(map[uint]string, len(steps))
+	for _, step := range steps {
+		keysByID[step.ID] = StepKey(step)
+	}
+
+	edges := make([]RoadmapGraphEdge, 0, len(prerequisites))
+	for _, prerequisite := range prerequisites {
+		from, okFrom := keysByID[prerequisite.PrerequisiteID]
+		to, okTo := keysByID[prerequisite.StepID]
+		if okFrom && okTo {
+			edges = append(edges, RoadmapGraphEdge{From: from, To: to})
+		}
+	}
+	return edges
+}
+
+// BuildRoadmapGraph lays out steps and the edges between their keys as a graph
+func BuildRoadmapGraph(steps []RoadmapStep, edges []RoadmapGraphEdge) RoadmapGraph {
+	keys := make([]string, 0, len(steps))
+	for _, step := range steps {
+		keys = append(keys, StepKey(step))
+	}
+
+	// Stored graphs were validated when they were saved, so a failure here leaves every step on level 0
+	_, levels, err := SortRoadmapDAG(keys, edges)
+	if err != nil {
+		levels = map[string]int{}
+	}
+
+	nodes := make([]RoadmapGraphNode, 0, len(steps))
+	for _, step := range steps {
+		objectives := []string(step.Objectives)
+		if objectives == nil {
+			objectives = []string{}
+		}
+		resources := step.Resources
+		if resources == nil {
+			resources = StepResources{}
+		}
+
+		key := StepKey(step)
+		nodes = append(nodes, RoadmapGraphNode{
+			ID:             key,
+			StepID:         step.ID,
+			Name:           step.Name,
+			Description:    step.Description,
+			EstimatedHours: step.EstimatedHours,
+			Objectives:     objectives,
+			Resources:      resources,
+			Order:          step.Order,
+			Level:          levels[key],
+			Completed:      step.Completed,
+			Mastered:       step.Mastered,
+		})
+	}
+
+	if edges == nil {
+		edges = []RoadmapGraphEdge{}
+	}
+	return RoadmapGraph{Nodes: nodes, Edges: edges}
+}
@@ -0,0 +1,265 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StepMatchThreshold is the name similarity at which a regenerated step is treated as an existing one
+const StepMatchThreshold = 0.7
+
+// RoadmapRevisionStatus represents the state of a proposed roadmap regeneration
+type RoadmapRevisionStatus string
+
+const (
+	RoadmapRevisionPending   RoadmapRevisionStatus = "pending"   // Waiting for the user to decide
+	RoadmapRevisionAccepted  RoadmapRevisionStatus = "accepted"  // Merged into the roadmap
+	RoadmapRevisionDiscarded RoadmapRevisionStatus = "discarded" // Rejected or replaced by a newer proposal
+)
+
+// Proposed step actions
+const (
+	ProposedStepKeep = "keep" // An existing step stays, keeping its ID and completion
+	ProposedStepAdd  = "add"  // A new step is added
+)
+
+// ProposedStep is a step of the merged roadmap proposed by a regeneration
+type ProposedStep struct {
+	Key            string        `json:"key"`
+	Name           string        `json:"name"`
+	Description    string        `json:"description,omitempty"`
+	EstimatedHours float64       `json:"estimatedHours"`
+	Objectives     []string      `json:"objectives"`
+	Resources      StepResources `json:"resources"`
+	Action         string        `json:"action"`
+	ExistingStepID uint          `json:"existingStepId,omitempty"`
+	ExistingName   string        `json:"existingName,omitempty"`
+	Similarity     float64       `json:"similarity,omitempty"` // Name similarity to the existing step, 0-1
+	Completed      bool          `json:"completed"`
+}
+
+// RemovedStep is an existing step a regeneration proposes to remove
+type RemovedStep struct {
+	StepID uint   `json:"stepId"`
+	Name   string `json:"name"`
+}
+
+// RoadmapProposal is the merged roadmap proposed by a regeneration
+type RoadmapProposal struct {
+	Steps   []ProposedStep     `json:"steps"`
+	Edges   []RoadmapGraphEdge `json:"edges"`
+	Removed []RemovedStep      `json:"removed"`
+}
+
+// Value implements the driver.Valuer interface for database serialization
+func (p RoadmapProposal) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// Scan implements the sql.Scanner interface for database deserialization
+func (p *RoadmapProposal) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal RoadmapProposal value: %v", value)
+	}
+
+	if len(bytes) == 0 {
+		*p = RoadmapProposal{}
+		return nil
+	}
+
+	return json.Unmarshal(bytes, p)
+}
+
+// RoadmapRevision is a regenerated version of a roadmap waiting to be merged or discarded
+type RoadmapRevision struct {
+	gorm.Model
+	RoadmapID  uint                  `gorm:"index;not null" json:"roadmapId"`
+	UserID     uint                  `gorm:"index;not null" json:"userId"`
+	Status     RoadmapRevisionStatus `gorm:"size:20;not null;default:'pending'" json:"status"`
+	Proposal   RoadmapProposal       `gorm:"type:jsonb" json:"proposal"`
+	ResolvedAt *time.Time            `json:"resolvedAt,omitempty"`
+}
+
+// StepNameSimilarity scores how alike two step names are, from 0 to 1.
+// It takes the better of word overlap and edit distance so that both reworded
+// and slightly misspelled names match.
+func StepNameSimilarity(a, b string) float64 {
+	a, b = NormalizeTopicKey(a), NormalizeTopicKey(b)
+	if a == b {
+		return 1
+	}
+	if a == "" || b == "" {
+		return 0
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	editSimilarity := 1 - float64(levenshtein(ra, rb))/float64(longest)
+
+	// Dice coefficient of the significant words
+	wordsA, wordsB := stepNameWords(a), stepNameWords(b)
+	wordSimilarity := 0.0
+	if len(wordsA) > 0 && len(wordsB) > 0 {
+		shared := 0
+		for word := range wordsA {
+			if wordsB[word] {
+				shared++
+			}
+		}
+		wordSimilarity = 2 * float64(shared) / float64(len(wordsA)+len(wordsB))
+	}
+
+	return max(editSimilarity, wordSimilarity)
+}
+
+// stepNameStopWords are ignored when comparing step names
+var stepNameStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "of": true, "to": true,
+	"in": true, "for": true, "with": true, "on": true, "basics": true, "introduction": true,
+}
+
+// stepNameWords returns the significant words of a step name with a plural "s" removed
+func stepNameWords(name string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range exerciseWords(name) {
+		if stepNameStopWords[word] {
+			continue
+		}
+		if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+			word = strings.TrimSuffix(word, "s")
+		}
+		words[word] = true
+	}
+	return words
+}
+
+// BuildRoadmapProposal merges a regenerated roadmap into the existing steps.
+// Regenerated steps that match an existing step keep its ID and completion, unmatched
+// regenerated steps are added, and unmatched existing steps are proposed for removal
+// unless they are completed, in which case they are kept at the end.
+func BuildRoadmapProposal(existing []RoadmapStep, generated []RoadmapStep, edges []RoadmapGraphEdge) RoadmapProposal {
+	type candidate struct {
+		existing, generated int
+		score               float64
+	}
+
+	var candidates []candidate
+	for i, step := range existing {
+		for j, regenerated := range generated {
+			if score := StepNameSimilarity(step.Name, regenerated.Name); score >= StepMatchThreshold {
+				candidates = append(candidates, candidate{existing: i, generated: j, score: score})
+			}
+		}
+	}
+
+	// Match greedily, best pairs first, so every step is matched at most once
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+	matchOfGenerated := make(map[int]candidate)
+	matchedExisting := make(map[int]bool)
+	for _, c := range candidates {
+		if matchedExisting[c.existing] {
+			continue
+		}
+		if _, taken := matchOfGenerated[c.generated]; taken {
+			continue
+		}
+		matchedExisting[c.existing] = true
+		matchOfGenerated[c.generated] = c
+	}
+
+	proposal := RoadmapProposal{
+		Steps:   make([]ProposedStep, 0, len(generated)),
+		Edges:   edges,
+		Removed: []RemovedStep{},
+	}
+	usedKeys := make(map[string]bool, len(generated))
+	for j, regenerated := range generated {
+		step := ProposedStep{
+			Key:            regenerated.Key,
+			Name:           regenerated.Name,
+			Description:    regenerated.Description,
+			EstimatedHours: regenerated.EstimatedHours,
+			Objectives:     []string(regenerated.Objectives),
+			Resources:      regenerated.Resources,
+			Action:         ProposedStepAdd,
+		}
+		if match, ok := matchOfGenerated[j]; ok {
+			matched := existing[match.existing]
+			step.Action = ProposedStepKeep
+			step.ExistingStepID = matched.ID
+			step.ExistingName = matched.Name
+			step.Similarity = match.score
+			step.Completed = matched.Completed
+		}
+		usedKeys[step.Key] = true
+		proposal.Steps = append(proposal.Steps, step)
+	}
+
+	for i, step := range existing {
+		if matchedExisting[i] {
+			continue
+		}
+		if !step.Completed {
+			proposal.Removed = append(proposal.Removed, RemovedStep{StepID: step.ID, Name: step.Name})
+			continue
+		}
+
+		// Completed work is never dropped by a regeneration
+		key := StepKey(step)
+		for n := 1; usedKeys[key]; n++ {
+			key = fmt.Sprintf("kept-%d-%d", step.ID, n)
+		}
+		usedKeys[key] = true
+		proposal.Steps = append(proposal.Steps, ProposedStep{
+			Key:            key,
+			Name:           step.Name,
+			Description:    step.Description,
+			EstimatedHours: step.EstimatedHours,
+			Objectives:     []string(step.Objectives),
+			Resources:      step.Resources,
+			Action:         ProposedStepKeep,
+			ExistingStepID: step.ID,
+			ExistingName:   step.Name,
+			Similarity:     1,
+			Completed:      true,
+		})
+	}
+
+	if proposal.Edges == nil {
+		proposal.Edges = []RoadmapGraphEdge{}
+	}
+	return proposal
+}
+
+// levenshtein returns the edit distance between two rune slices
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
@@ -0,0 +1,355 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PlatformDailyStat is the platform's activity on a UTC day, rolled up from the activity log
+type PlatformDailyStat struct {
+	ID                  uint      `gorm:"primarykey" json:"-"`
+	CreatedAt           time.Time `json:"-"`
+	UpdatedAt           time.Time `json:"computedAt"`
+	Date                time.Time `gorm:"uniqueIndex;not null" json:"date"` // Midnight UTC
+	TotalUsers          int       `json:"totalUsers"`                       // Users signed up by the end of the day
+	NewUsers            int       `json:"newUsers"`
+	ActiveUsers         int       `json:"activeUsers"`
+	WeeklyActiveUsers   int       `json:"weeklyActiveUsers"`  // Users active in the 7 days up to the day
+	MonthlyActiveUsers  int       `json:"monthlyActiveUsers"` // Users active in the 30 days up to the day
+	Activities          int       `json:"activities"`         // Entries of the activity log
+	TopicViews          int       `json:"topicViews"`
+	TopicCompletions    int       `json:"topicCompletions"`
+	ExerciseAttempts    int       `json:"exerciseAttempts"` // Completed exercises included
+	ExerciseCompletions int       `json:"exerciseCompletions"`
+	LearningTimeMin     int       `json:"learningTimeMin"`
+}
+
+// PlatformTopicDailyStat is how often a topic was viewed and completed on a UTC day
+type PlatformTopicDailyStat struct {
+	ID          uint      `gorm:"primarykey" json:"-"`
+	Date        time.Time `gorm:"uniqueIndex:idx_platform_topic_daily_stats_topic;not null" json:"date"`
+	TopicName   string    `gorm:"uniqueIndex:idx_platform_topic_daily_stats_topic;not null" json:"topicName"`
+	Views       int       `json:"views"`
+	Completions int       `json:"completions"`
+}
+
+// rollupLockKey is the advisory lock that keeps two servers from rolling up at the same time
+const rollupLockKey = 4820250419
+
+// WithRollupLock runs fn on a connection holding the rollup lock, unless another server holds it.
+// It reports whether fn ran.
+func WithRollupLock(db *gorm.DB, fn func(conn *gorm.DB) error) (bool, error) {
+	var locked bool
+	err := db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Raw("SELECT pg_try_advisory_lock(?)", rollupLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", rollupLockKey)
+		return fn(conn)
+	})
+	return locked, err
+}
+
+// PendingAnalyticsRollups returns, in ID order after afterID, the users whose analytics are missing
+// or behind their activity log, or were last rolled up before staleBefore
+func PendingAnalyticsRollups(db *gorm.DB, afterID uint, staleBefore time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	err := db.Raw(`
+		SELECT DISTINCT users.id
+		FROM users
+		LEFT JOIN analytics ON analytics.user_id = users.id AND analytics.deleted_at IS NULL
+		WHERE users.deleted_at IS NULL AND users.id > ?
+			AND (
+				(analytics.id IS NULL AND EXISTS (
+					SELECT 1 FROM activity_logs
+					WHERE activity_logs.user_id = users.id AND activity_logs.deleted_at IS NULL
+				))
+				OR (analytics.id IS NOT NULL AND (analytics.rolled_up_at IS NULL OR analytics.rolled_up_at < ?))
+				OR EXISTS (
+					SELECT 1 FROM activity_logs
+					WHERE activity_logs.user_id = users.id AND activity_logs.updated_at >= analytics.rolled_up_at
+				)
+			)
+		ORDER BY users.id
+		LIMIT ?
+	`, afterID, staleBefore, limit).Scan(&ids).Error
+	return ids, err
+}
+
+// RollupUserAnalytics recomputes the analytics of users from their activity log and topic
+// interactions, replacing whatever the counters held. Running it again gives the same analytics.
+func RollupUserAnalytics(db *gorm.DB, userIDs []uint, now time.Time) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	var totals []struct {
+		UserID             uint
+		TopicsViewed       int
+		TopicsCompleted    int
+		TotalLearningTime  int
+		ExercisesAttempted int
+		ExercisesCompleted int
+		AverageQuizScore   float64
+	}
+	if err := db.Raw(`
+		SELECT user_id,
+			COUNT(DISTINCT COALESCE(topic_id::text, topic_name)) FILTER (WHERE activity_type IN ('topic-view', 'topic-completion')) AS topics_viewed,
+			COUNT(DISTINCT COALESCE(topic_id::text, topic_name)) FILTER (WHERE activity_type = 'topic-completion') AS topics_completed,
+			COALESCE(SUM(time_spent), 0) AS total_learning_time,
+			COUNT(*) FILTER (WHERE activity_type IN ('exercise-attempt', 'exercise-completion')) AS exercises_attempted,
+			COUNT(*) FILTER (WHERE activity_type = 'exercise-completion') AS exercises_completed,
+			COALESCE(AVG(score) FILTER (WHERE activity_type IN ('topic-completion', 'exercise-completion') AND score > 0), 0)::float8 AS average_quiz_score
+		FROM activity_logs
+		WHERE deleted_at IS NULL AND user_id IN ?
+		GROUP BY user_id
+	`, userIDs).Scan(&totals).Error; err != nil {
+		return err
+	}
+
+	var codeScores []struct {
+		UserID           uint
+		AverageCodeScore float64
+	}
+	if err := db.Raw(`
+		SELECT user_id, AVG(code_score)::float8 AS average_code_score
+		FROM topic_interactions
+		WHERE deleted_at IS NULL AND code_score > 0 AND user_id IN ?
+		GROUP BY user_id
+	`, userIDs).Scan(&codeScores).Error; err != nil {
+		return err
+	}
+
+	// Ratings are not learning, so they do not move the last activity
+	var lastActivities []struct {
+		UserID    uint
+		Timestamp time.Time
+		TopicName string
+	}
+	if err := db.Raw(`
+		SELECT DISTINCT ON (user_id) user_id, timestamp, topic_name
+		FROM activity_logs
+		WHERE deleted_at IS NULL AND activity_type <> 'topic-rating' AND user_id IN ?
+		ORDER BY user_id, timestamp DESC, id DESC
+	`, userIDs).Scan(&lastActivities).Error; err != nil {
+		return err
+	}
+
+	var users []User
+	if err := db.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return err
+	}
+
+	rollups := make(map[uint]*Analytics, len(users))
+	for _, user := range users {
+		rollups[user.ID] = &Analytics{UserID: user.ID}
+	}
+	for _, row := range totals {
+		if analytics, ok := rollups[row.UserID]; ok {
+			analytics.TopicsViewed = row.TopicsViewed
+			analytics.TopicsCompleted = row.TopicsCompleted
+			analytics.TotalLearningTime = row.TotalLearningTime
+			analytics.ExercisesAttempted = row.ExercisesAttempted
+			analytics.ExercisesCompleted = row.ExercisesCompleted
+			analytics.AverageQuizScore = row.AverageQuizScore
+			if row.TopicsViewed > 0 {
+				analytics.TopicCompletionRate = float64(row.TopicsCompleted) / float64(row.TopicsViewed) * 100.0
+			}
+		}
+	}
+	for _, row := range codeScores {
+		if analytics, ok := rollups[row.UserID]; ok {
+			analytics.AverageCodeScore = row.AverageCodeScore
+		}
+	}
+	for _, row := range lastActivities {
+		if analytics, ok := rollups[row.UserID]; ok {
+			analytics.LastActivityDate = row.Timestamp
+			analytics.LastTopicAccessed = row.TopicName
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, user := range users {
+			rollup := rollups[user.ID]
+
+			var analytics Analytics
+			result := tx.Where("user_id = ?", user.ID).Order("id ASC").First(&analytics)
+			if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+				return result.Error
+			}
+			if result.Error == gorm.ErrRecordNotFound {
+				analytics = Analytics{UserID: user.ID, LastActivityDate: now}
+			}
+
+			analytics.TopicsViewed = rollup.TopicsViewed
+			analytics.TopicsCompleted = rollup.TopicsCompleted
+			analytics.TotalLearningTime = rollup.TotalLearningTime
+			analytics.ExercisesAttempted = rollup.ExercisesAttempted
+			analytics.ExercisesCompleted = rollup.ExercisesCompleted
+			analytics.AverageQuizScore = rollup.AverageQuizScore
+			analytics.AverageCodeScore = rollup.AverageCodeScore
+			analytics.TopicCompletionRate = rollup.TopicCompletionRate
+			if !rollup.LastActivityDate.IsZero() {
+				analytics.LastActivityDate = rollup.LastActivityDate
+				analytics.LastTopicAccessed = rollup.LastTopicAccessed
+			}
+			analytics.RolledUpAt = &now
+
+			if err := tx.Save(&analytics).Error; err != nil {
+				return err
+			}
+			if _, _, err := RecomputeStreaks(tx, user.ID, user.Location(), now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RollupPlatformDay recomputes the platform's activity on a UTC day and on each topic that day,
+// replacing the day's earlier rollup
+func RollupPlatformDay(db *gorm.DB, day time.Time) (PlatformDailyStat, error) {
+	day = ActivityDay(day, time.UTC)
+	next := day.AddDate(0, 0, 1)
+	stat := PlatformDailyStat{Date: day}
+
+	var users struct {
+		TotalUsers int
+		NewUsers   int
+	}
+	if err := db.Raw(`
+		SELECT COUNT(*) AS total_users, COUNT(*) FILTER (WHERE created_at >= ?) AS new_users
+		FROM users
+		WHERE deleted_at IS NULL AND created_at < ?
+	`, day, next).Scan(&users).Error; err != nil {
+		return stat, err
+	}
+	stat.TotalUsers, stat.NewUsers = users.TotalUsers, users.NewUsers
+
+	active, err := LoadActiveUsers(db, day, day)
+	if err != nil {
+		return stat, err
+	}
+	if len(active) > 0 {
+		stat.ActiveUsers, stat.WeeklyActiveUsers, stat.MonthlyActiveUsers = active[0].DAU, active[0].WAU, active[0].MAU
+	}
+
+	var activity struct {
+		Activities          int
+		TopicViews          int
+		TopicCompletions    int
+		ExerciseAttempts    int
+		ExerciseCompletions int
+		LearningTimeMin     int
+	}
+	if err := db.Raw(`
+		SELECT COUNT(*) AS activities,
+			COUNT(*) FILTER (WHERE activity_type = 'topic-view') AS topic_views,
+			COUNT(*) FILTER (WHERE activity_type = 'topic-completion') AS topic_completions,
+			COUNT(*) FILTER (WHERE activity_type IN ('exercise-attempt', 'exercise-completion')) AS exercise_attempts,
+			COUNT(*) FILTER (WHERE activity_type = 'exercise-completion') AS exercise_completions,
+			COALESCE(SUM(time_spent), 0) AS learning_time_min
+		FROM activity_logs
+		WHERE deleted_at IS NULL AND timestamp >= ? AND timestamp < ?
+	`, day, next).Scan(&activity).Error; err != nil {
+		return stat, err
+	}
+	stat.Activities = activity.Activities
+	stat.TopicViews = activity.TopicViews
+	stat.TopicCompletions = activity.TopicCompletions
+	stat.ExerciseAttempts = activity.ExerciseAttempts
+	stat.ExerciseCompletions = activity.ExerciseCompletions
+	stat.LearningTimeMin = activity.LearningTimeMin
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "date"}},
+			UpdateAll: true,
+		}).Create(&stat).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("date = ?", day).Delete(&PlatformTopicDailyStat{}).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`
+			INSERT INTO platform_topic_daily_stats (date, topic_name, views, completions)
+			SELECT ?, topic_name,
+				COUNT(*) FILTER (WHERE activity_type = 'topic-view'),
+				COUNT(*) FILTER (WHERE activity_type = 'topic-completion')
+			FROM activity_logs
+			WHERE deleted_at IS NULL AND topic_name <> '' AND activity_type IN ('topic-view', 'topic-completion')
+				AND timestamp >= ? AND timestamp < ?
+			GROUP BY topic_name
+		`, day, day, next).Error
+	})
+	return stat, err
+}
+
+// MissingPlatformDays returns, oldest first, up to limit days before the given one that have
+// activity or signups but no rollup yet
+func MissingPlatformDays(db *gorm.DB, before time.Time, limit int) ([]time.Time, error) {
+	var first sql.NullTime
+	if err := db.Raw(`
+		SELECT MIN(day) FROM (
+			SELECT MIN(timestamp) AS day FROM activity_logs WHERE deleted_at IS NULL
+			UNION ALL
+			SELECT MIN(created_at) FROM users WHERE deleted_at IS NULL
+		) AS firsts
+	`).Row().Scan(&first); err != nil || !first.Valid {
+		return nil, err
+	}
+
+	var days []time.Time
+	err := db.Raw(`
+		SELECT gs AT TIME ZONE 'UTC' AS day
+		FROM generate_series(?::date, ?::date - 1, interval '1 day') AS gs
+		WHERE NOT EXISTS (
+			SELECT 1 FROM platform_daily_stats WHERE platform_daily_stats.date = gs AT TIME ZONE 'UTC'
+		)
+		ORDER BY gs
+		LIMIT ?
+	`, ActivityDay(first.Time, time.UTC).Format("2006-01-02"), ActivityDay(before, time.UTC).Format("2006-01-02"), limit).Scan(&days).Error
+	return days, err
+}
+
+// PlatformTotals sums the platform's daily rollups over every day
+type PlatformTotals struct {
+	Activities       int
+	TopicViews       int
+	TopicCompletions int
+	LearningTimeMin  int
+}
+
+// LoadPlatformTotals sums the daily rollups of the platform
+func LoadPlatformTotals(db *gorm.DB) (PlatformTotals, error) {
+	var totals PlatformTotals
+	err := db.Raw(`
+		SELECT COALESCE(SUM(activities), 0) AS activities,
+			COALESCE(SUM(topic_views), 0) AS topic_views,
+			COALESCE(SUM(topic_completions), 0) AS topic_completions,
+			COALESCE(SUM(learning_time_min), 0) AS learning_time_min
+		FROM platform_daily_stats
+	`).Scan(&totals).Error
+	return totals, err
+}
+
+// LoadPopularTopics returns the names of the most viewed topics according to the daily rollups
+func LoadPopularTopics(db *gorm.DB, limit int) ([]string, error) {
+	var names []string
+	err := db.Raw(`
+		SELECT topic_name
+		FROM platform_topic_daily_stats
+		GROUP BY topic_name
+		ORDER BY SUM(views) DESC, topic_name ASC
+		LIMIT ?
+	`, limit).Scan(&names).Error
+	return names, err
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// winnowK is the number of tokens in each hashed k-gram
+	winnowK = 5
+	// winnowWindow is the number of consecutive k-gram hashes a fingerprint is chosen from
+	winnowWindow = 4
+)
+
+// SimilarityReviewStatus represents the review state of a suspicious submission pair
+type SimilarityReviewStatus string
+
+const (
+	SimilarityReviewPending   SimilarityReviewStatus = "pending"   // Waiting for a mentor or admin
+	SimilarityReviewConfirmed SimilarityReviewStatus = "confirmed" // Reviewer confirmed copying
+	SimilarityReviewDismissed SimilarityReviewStatus = "dismissed" // Reviewer found no problem
+)
+
+// SubmissionSimilarity stores the similarity between two users' code submissions for the same exercise
+type SubmissionSimilarity struct {
+	gorm.Model
+	ExerciseItemID uint                   `gorm:"index;not null" json:"exerciseItemId"`
+	AttemptAID     uint                   `gorm:"not null;uniqueIndex:idx_submission_similarities_pair" json:"attemptAId"`
+	AttemptBID     uint                   `gorm:"not null;uniqueIndex:idx_submission_similarities_pair" json:"attemptBId"`
+	UserAID        uint                   `gorm:"index;not null" json:"userAId"`
+	UserBID        uint                   `gorm:"index;not null" json:"userBId"`
+	Score          float64                `gorm:"not null" json:"score"` // Jaccard similarity of the fingerprints, 0-1
+	Flagged        bool                   `gorm:"not null;default:false;index" json:"flagged"`
+	ReviewStatus   SimilarityReviewStatus `gorm:"size:20;not null;default:'pending'" json:"reviewStatus"`
+	ReviewerID     *uint                  `json:"reviewerId,omitempty"` // Admin who reviewed the pair
+	ReviewedAt     *time.Time             `json:"reviewedAt,omitempty"`
+	ReviewNote     string                 `gorm:"type:text" json:"reviewNote,omitempty"`
+}
+
+// codeKeywords are kept verbatim when tokenizing so the structure of the code survives renaming
+var codeKeywords = map[string]bool{
+	"break": true, "case": true, "catch": true, "class": true, "const": true, "continue": true,
+	"def": true, "default": true, "defer": true, "do": true, "elif": true, "else": true,
+	"for": true, "func": true, "function": true, "go": true, "if": true, "import": true,
+	"in": true, "let": true, "new": true, "of": true, "package": true, "return": true,
+	"struct": true, "switch": true, "this": true, "throw": true, "try": true, "type": true,
+	"var": true, "while": true, "with": true, "yield": true, "async": true, "await": true,
+	"lambda": true, "null": true, "nil": true, "true": true, "false": true, "None": true,
+}
+
+// CodeTokens splits source code into normalized tokens.
+// Identifiers become "V", numbers "N" and string literals "S", while comments and whitespace are dropped.
+func CodeTokens(code string) []string {
+	runes := []rune(code)
+	var tokens []string
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/', r == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/') {
+				i++
+			}
+			i += 2
+		case r == '"' || r == '\'' || r == '`':
+			quote := r
+			i++
+			for i < len(runes) && runes[i] != quote {
+				if runes[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			i++
+			tokens = append(tokens, "S")
+		case unicode.IsDigit(r):
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || unicode.IsLetter(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, "N")
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '$') {
+				i++
+			}
+			word := string(runes[start:i])
+			if codeKeywords[word] {
+				tokens = append(tokens, word)
+			} else {
+				tokens = append(tokens, "V")
+			}
+		default:
+			tokens = append(tokens, string(r))
+			i++
+		}
+	}
+
+	return tokens
+}
+
+// CodeFingerprints returns the winnowed k-gram fingerprints of source code, sorted and deduplicated
+func CodeFingerprints(code string) []int64 {
+	tokens := CodeTokens(code)
+	if len(tokens) < winnowK {
+		return nil
+	}
+
+	hashes := make([]int64, 0, len(tokens)-winnowK+1)
+	for i := 0; i+winnowK <= len(tokens); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(tokens[i:i+winnowK], "\x00")))
+		hashes = append(hashes, int64(h.Sum64()))
+	}
+
+	// Keep the minimum hash of every window, taking the rightmost one on ties
+	selected := make(map[int64]bool)
+	if len(hashes) <= winnowWindow {
+		minHash := hashes[0]
+		for _, h := range hashes[1:] {
+			if h <= minHash {
+				minHash = h
+			}
+		}
+		selected[minHash] = true
+	} else {
+		for start := 0; start+winnowWindow <= len(hashes); start++ {
+			minHash := hashes[start]
+			for _, h := range hashes[start+1 : start+winnowWindow] {
+				if h <= minHash {
+					minHash = h
+				}
+			}
+			selected[minHash] = true
+		}
+	}
+
+	fingerprints := make([]int64, 0, len(selected))
+	for h := range selected {
+		fingerprints = append(fingerprints, h)
+	}
+	sort.Slice(fingerprints, func(i, j int) bool { return fingerprints[i] < fingerprints[j] })
+	return fingerprints
+}
+
+// WithoutFingerprints removes the fingerprints that also appear in exclude, such as the starter code
+func WithoutFingerprints(fingerprints, exclude []int64) []int64 {
+	excluded := make(map[int64]bool, len(exclude))
+	for _, h := range exclude {
+		excluded[h] = true
+	}
+
+	kept := make([]int64, 0, len(fingerprints))
+	for _, h := range fingerprints {
+		if !excluded[h] {
+			kept = append(kept, h)
+		}
+	}
+	return kept
+}
+
+// FingerprintSimilarity returns the Jaccard similarity of two fingerprint sets
+func FingerprintSimilarity(a, b []int64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	inA := make(map[int64]bool, len(a))
+	for _, h := range a {
+		inA[h] = true
+	}
+
+	shared := 0
+	for _, h := range b {
+		if inA[h] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
@@ -0,0 +1,211 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// activeDayCondition selects the days of daily activity on which a user actually learned
+const activeDayCondition = "(learning_time_min > 0 OR topics_viewed > 0 OR topics_completed > 0 OR exercises_done > 0)"
+
+// ActivityDay returns the calendar day a moment falls on in a timezone, as midnight UTC.
+// Daily activity is bucketed by these days so a day means the same thing wherever the server runs.
+func ActivityDay(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// ComputeStreaks returns the current and the longest run of consecutive active days. The current
+// streak still counts when the last active day was yesterday, as today is not over yet.
+func ComputeStreaks(days []time.Time, today time.Time) (current, longest int) {
+	if len(days) == 0 {
+		return 0, 0
+	}
+
+	sorted := make([]time.Time, len(days))
+	copy(sorted, days)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+
+	run := 0
+	var previous time.Time
+	for i, day := range sorted {
+		if day.After(today) {
+			break
+		}
+		switch {
+		case i > 0 && day.Equal(previous):
+			continue
+		case i > 0 && day.Equal(previous.AddDate(0, 0, 1)):
+			run++
+		default:
+			run = 1
+		}
+		previous = day
+		longest = max(longest, run)
+	}
+
+	if !previous.IsZero() && !previous.Before(today.AddDate(0, 0, -1)) {
+		current = run
+	}
+	return current, longest
+}
+
+// RecomputeStreaks recomputes a user's current and longest streak from their daily activity in
+// their timezone and stores them in their analytics
+func RecomputeStreaks(db *gorm.DB, userID uint, loc *time.Location, now time.Time) (current, longest int, err error) {
+	var days []time.Time
+	if err := db.Model(&DailyActivity{}).
+		Where("user_id = ? AND "+activeDayCondition, userID).
+		Pluck("date", &days).Error; err != nil {
+		return 0, 0, err
+	}
+	for i := range days {
+		days[i] = days[i].UTC()
+	}
+
+	current, longest = ComputeStreaks(days, ActivityDay(now, loc))
+	err = db.Model(&Analytics{}).
+		Where("user_id = ?", userID).
+		UpdateColumns(map[string]interface{}{"streak_days": current, "longest_streak": longest}).Error
+	return current, longest, err
+}
+
+// activityLogDay is what an activity log adds to the daily activity of the day it happened on
+type activityLogDay struct {
+	ActivityType string
+	TimeSpent    int
+	Timestamp    time.Time
+	CreatedAt    time.Time
+}
+
+// MigrateActivityDays takes the timezone of users who have none from their study plans, rebuilds
+// their daily activity from their activity logs on the days of their timezones and recomputes
+// everyone's streaks. The days are rebuilt from the logs as a whole, so running it again leaves
+// them as they are. Users without activity logs keep their days, moved from the server's local time
+// onto the UTC midnights of the same days.
+func MigrateActivityDays(db *gorm.DB) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// A study plan records the timezone its learner studies in
+		if err := tx.Exec(`
+			UPDATE users SET timezone = plans.timezone
+			FROM (
+				SELECT DISTINCT ON (user_id) user_id, timezone
+				FROM study_plans
+				WHERE deleted_at IS NULL AND timezone NOT IN ('', 'Local')
+				ORDER BY user_id, updated_at DESC
+			) AS plans
+			WHERE users.id = plans.user_id AND users.timezone = 'UTC'
+		`).Error; err != nil {
+			return err
+		}
+
+		var users []User
+		if err := tx.Select("id", "timezone").
+			Where("id IN (?)", tx.Model(&ActivityLog{}).Select("user_id")).
+			Find(&users).Error; err != nil {
+			return err
+		}
+		for _, user := range users {
+			if err := rebuildDailyActivity(tx, user); err != nil {
+				return err
+			}
+		}
+		if len(users) > 0 {
+			fmt.Printf("INFO: Rebuilt the daily activity of %d users in their timezones\n", len(users))
+		}
+
+		var rows []DailyActivity
+		if err := tx.Where("date <> date_trunc('day', date AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'").Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			day := ActivityDay(row.Date, time.Local)
+			if err := tx.Model(&row).UpdateColumn("date", day).Error; err != nil {
+				return err
+			}
+		}
+		if len(rows) > 0 {
+			fmt.Printf("INFO: Moved %d days of activity without activity logs to UTC days\n", len(rows))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var users []User
+	if err := db.Select("id", "timezone").
+		Where("id IN (?)", db.Model(&Analytics{}).Select("user_id")).
+		Find(&users).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, user := range users {
+		if _, _, err := RecomputeStreaks(db, user.ID, user.Location(), now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildDailyActivity replaces a user's daily activity with the sum of their activity logs on
+// each day of their timezone, counting the logs as recording them counted them
+func rebuildDailyActivity(tx *gorm.DB, user User) error {
+	var logs []activityLogDay
+	if err := tx.Model(&ActivityLog{}).
+		Select("activity_type", "time_spent", "timestamp", "created_at").
+		Where("user_id = ?", user.ID).
+		Scan(&logs).Error; err != nil {
+		return err
+	}
+
+	loc := user.Location()
+	days := make(map[time.Time]*DailyActivity)
+	var order []time.Time
+	for _, log := range logs {
+		at := log.Timestamp
+		if at.IsZero() {
+			at = log.CreatedAt
+		}
+		var viewed, completed, exercises int
+		switch log.ActivityType {
+		case "topic-view":
+			viewed = 1
+		case "topic-completion":
+			completed = 1
+		case "exercise-completion":
+			exercises = 1
+		case "exercise-attempt":
+		default:
+			// Ratings and other logs are not learning activity
+			continue
+		}
+
+		day := ActivityDay(at, loc)
+		activity, ok := days[day]
+		if !ok {
+			activity = &DailyActivity{UserID: user.ID, Date: day}
+			days[day] = activity
+			order = append(order, day)
+		}
+		activity.TopicsViewed += viewed
+		activity.TopicsCompleted += completed
+		activity.ExercisesDone += exercises
+		activity.LearningTimeMin += log.TimeSpent
+	}
+
+	if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&DailyActivity{}).Error; err != nil {
+		return err
+	}
+	rows := make([]DailyActivity, 0, len(order))
+	for _, day := range order {
+		rows = append(rows, *days[day])
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(&rows, 500).Error
+}
@@ -0,0 +1,259 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // Timezones must resolve even where the system has no zoneinfo
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultStepHours is the effort assumed for steps without an estimate
+	DefaultStepHours = 2.0
+	// maxScheduleDays stops scheduling when the availability is too small to ever finish
+	maxScheduleDays = 3 * 365
+	// minSessionHours avoids scheduling sessions too short to be useful
+	minSessionHours = 0.25
+)
+
+// Weekdays lists the weekday names used as availability keys, indexed by time.Weekday
+var Weekdays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// WeeklyAvailability maps lowercase weekday names to the hours available for study on that day
+type WeeklyAvailability map[string]float64
+
+// Value implements the driver.Valuer interface for database serialization
+func (w WeeklyAvailability) Value() (driver.Value, error) {
+	return json.Marshal(w)
+}
+
+// Scan implements the sql.Scanner interface for database deserialization
+func (w *WeeklyAvailability) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal WeeklyAvailability value: %v", value)
+	}
+
+	if len(bytes) == 0 {
+		*w = make(WeeklyAvailability)
+		return nil
+	}
+
+	return json.Unmarshal(bytes, w)
+}
+
+// HoursOn returns the hours available on a weekday
+func (w WeeklyAvailability) HoursOn(day time.Weekday) float64 {
+	return w[Weekdays[day]]
+}
+
+// WeeklyHours returns the total hours available per week
+func (w WeeklyAvailability) WeeklyHours() float64 {
+	total := 0.0
+	for _, day := range Weekdays {
+		total += w[day]
+	}
+	return total
+}
+
+// StudyPlan schedules the steps of a roadmap into study sessions
+type StudyPlan struct {
+	gorm.Model
+	UserID           uint               `gorm:"index;not null" json:"userId"`
+	RoadmapID        uint               `gorm:"uniqueIndex;not null" json:"roadmapId"`
+	Timezone         string             `gorm:"size:64;not null;default:'UTC'" json:"timezone"`
+	Availability     WeeklyAvailability `gorm:"type:jsonb" json:"availability"`
+	SessionStartHour int                `gorm:"not null;default:18" json:"sessionStartHour"` // Local hour the first session of a day starts
+	TargetDate       *time.Time         `json:"targetDate,omitempty"`
+	ScheduledAt      time.Time          `json:"scheduledAt"`
+	EstimatedFinish  *time.Time         `json:"estimatedFinish,omitempty"`
+	Sessions         []StudySession     `gorm:"foreignKey:PlanID" json:"sessions,omitempty"`
+}
+
+// StudySession is a block of time reserved for studying a roadmap step
+type StudySession struct {
+	gorm.Model
+	PlanID        uint      `gorm:"index;not null" json:"planId"`
+	UserID        uint      `gorm:"index;not null" json:"userId"`
+	RoadmapStepID uint      `gorm:"index;not null" json:"roadmapStepId"`
+	StepName      string    `gorm:"size:200" json:"stepName"`
+	StartsAt      time.Time `gorm:"index;not null" json:"startsAt"`
+	EndsAt        time.Time `gorm:"not null" json:"endsAt"`
+	Hours         float64   `json:"hours"`
+}
+
+// CalendarFeed holds the secret token of a user's study calendar feed
+type CalendarFeed struct {
+	gorm.Model
+	UserID uint   `gorm:"uniqueIndex;not null" json:"userId"`
+	Token  string `gorm:"size:64;uniqueIndex;not null" json:"-"`
+}
+
+// Location returns the plan's timezone, falling back to UTC
+func (p *StudyPlan) Location() *time.Location {
+	loc, err := LoadTimezone(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// StepHours returns the effort of a step, using the default for steps without an estimate
+func StepHours(step RoadmapStep) float64 {
+	if step.EstimatedHours > 0 {
+		return step.EstimatedHours
+	}
+	return DefaultStepHours
+}
+
+// ScheduleStudySessions assigns the incomplete steps to sessions on the available days starting
+// after from, in their order but never before their prerequisites. It returns the sessions and the
+// end of the last one.
+func (p *StudyPlan) ScheduleStudySessions(steps []RoadmapStep, edges []RoadmapGraphEdge, from time.Time) ([]StudySession, time.Time) {
+	steps = SortStepsByPrerequisites(steps, edges)
+	loc := p.Location()
+	local := from.In(loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	var sessions []StudySession
+	finish := from
+	stepIndex := 0
+	remaining := 0.0
+
+	nextStep := func() bool {
+		for stepIndex < len(steps) && steps[stepIndex].Completed {
+			stepIndex++
+		}
+		if stepIndex >= len(steps) {
+			return false
+		}
+		remaining = StepHours(steps[stepIndex])
+		return true
+	}
+	if !nextStep() || p.Availability.WeeklyHours() <= 0 {
+		return sessions, finish
+	}
+
+	for d := 0; d < maxScheduleDays; d++ {
+		date := day.AddDate(0, 0, d)
+		capacity := p.Availability.HoursOn(date.Weekday())
+		cursor := date.Add(time.Duration(p.SessionStartHour) * time.Hour)
+
+		// Skip a day whose study time has already begun
+		if capacity <= 0 || cursor.Before(from) {
+			continue
+		}
+
+		for capacity >= minSessionHours {
+			hours := min(remaining, capacity)
+			end := cursor.Add(time.Duration(hours * float64(time.Hour)))
+			sessions = append(sessions, StudySession{
+				PlanID:        p.ID,
+				UserID:        p.UserID,
+				RoadmapStepID: steps[stepIndex].ID,
+				StepName:      steps[stepIndex].Name,
+				StartsAt:      cursor.UTC(),
+				EndsAt:        end.UTC(),
+				Hours:         hours,
+			})
+			finish = end
+			cursor = end
+			capacity -= hours
+			remaining -= hours
+
+			if remaining < minSessionHours {
+				stepIndex++
+				if !nextStep() {
+					return sessions, finish
+				}
+			}
+		}
+	}
+
+	return sessions, finish
+}
+
+// NeedsReschedule reports whether the upcoming sessions no longer fit the roadmap: an incomplete
+// step has no session left, or a session ahead belongs to a step that was completed or removed.
+func NeedsReschedule(sessions []StudySession, steps []RoadmapStep, now time.Time) bool {
+	stepsByID := make(map[uint]RoadmapStep, len(steps))
+	for _, step := range steps {
+		stepsByID[step.ID] = step
+	}
+
+	lastEnd := make(map[uint]time.Time, len(steps))
+	for _, session := range sessions {
+		step, ok := stepsByID[session.RoadmapStepID]
+		if session.StartsAt.After(now) && (!ok || step.Completed) {
+			return true
+		}
+		if session.EndsAt.After(lastEnd[session.RoadmapStepID]) {
+			lastEnd[session.RoadmapStepID] = session.EndsAt
+		}
+	}
+
+	// A step is late when its last session is over and it is still not completed
+	for _, step := range steps {
+		end, ok := lastEnd[step.ID]
+		if !step.Completed && (!ok || end.Before(now)) {
+			return true
+		}
+	}
+	return false
+}
+
+// StudySessionsICS renders study sessions as an iCalendar feed
+func StudySessionsICS(calendarName string, sessions []StudySession, descriptions map[uint]string) string {
+	var b strings.Builder
+	writeICSLine(&b, "BEGIN:VCALENDAR")
+	writeICSLine(&b, "VERSION:2.0")
+	writeICSLine(&b, "PRODID:-//Mentor//Study Plan//EN")
+	writeICSLine(&b, "CALSCALE:GREGORIAN")
+	writeICSLine(&b, "METHOD:PUBLISH")
+	writeICSLine(&b, "X-WR-CALNAME:"+escapeICSText(calendarName))
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for _, session := range sessions {
+		writeICSLine(&b, "BEGIN:VEVENT")
+		writeICSLine(&b, fmt.Sprintf("UID:study-session-%d@mentor", session.ID))
+		writeICSLine(&b, "DTSTAMP:"+stamp)
+		writeICSLine(&b, "DTSTART:"+session.StartsAt.UTC().Format("20060102T150405Z"))
+		writeICSLine(&b, "DTEND:"+session.EndsAt.UTC().Format("20060102T150405Z"))
+		writeICSLine(&b, "SUMMARY:"+escapeICSText("Study: "+session.StepName))
+		if description := descriptions[session.RoadmapStepID]; description != "" {
+			writeICSLine(&b, "DESCRIPTION:"+escapeICSText(description))
+		}
+		writeICSLine(&b, "END:VEVENT")
+	}
+
+	writeICSLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// escapeICSText escapes text values as required by RFC 5545
+func escapeICSText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(text)
+}
+
+// writeICSLine writes a content line, folding it at 75 octets as required by RFC 5545
+func writeICSLine(b *strings.Builder, line string) {
+	// Continuation lines start with a space, which counts towards their length
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		// Do not split a multi-byte character
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// TemplateStep is a roadmap step stored in a template, without any learner progress
+type TemplateStep struct {
+	Key            string        `json:"key"`
+	Name           string        `json:"name"`
+	Description    string        `json:"description,omitempty"`
+	EstimatedHours float64       `json:"estimatedHours"`
+	Objectives     []string      `json:"objectives"`
+	Resources      StepResources `json:"resources"`
+	Order          int           `json:"order"`
+}
+
+// TemplateSnapshot is the copy of a roadmap's steps and prerequisites taken when it is published
+type TemplateSnapshot struct {
+	Steps []TemplateStep     `json:"steps"`
+	Edges []RoadmapGraphEdge `json:"edges"`
+}
+
+// Value implements the driver.Valuer interface for database serialization
+func (s TemplateSnapshot) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan implements the sql.Scanner interface for database deserialization
+func (s *TemplateSnapshot) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal TemplateSnapshot value: %v", value)
+	}
+
+	if len(bytes) == 0 {
+		*s = TemplateSnapshot{}
+		return nil
+	}
+
+	return json.Unmarshal(bytes, s)
+}
+
+// NewTemplateSnapshot copies roadmap steps and their edges into a template snapshot
+func NewTemplateSnapshot(steps []RoadmapStep, edges []RoadmapGraphEdge) TemplateSnapshot {
+	snapshot := TemplateSnapshot{
+		Steps: make([]TemplateStep, 0, len(steps)),
+		Edges: edges,
+	}
+	for _, step := range steps {
+		snapshot.Steps = append(snapshot.Steps, TemplateStep{
+			Key:            StepKey(step),
+			Name:           step.Name,
+			Description:    step.Description,
+			EstimatedHours: step.EstimatedHours,
+			Objectives:     []string(step.Objectives),
+			Resources:      step.Resources,
+			Order:          step.Order,
+		})
+	}
+	if snapshot.Edges == nil {
+		snapshot.Edges = []RoadmapGraphEdge{}
+	}
+	return snapshot
+}
+
+// RoadmapSteps turns the snapshot back into unsaved roadmap steps with fresh progress
+func (s TemplateSnapshot) RoadmapSteps() []RoadmapStep {
+	steps := make([]RoadmapStep, 0, len(s.Steps))
+	for _, step := range s.Steps {
+		steps = append(steps, RoadmapStep{
+			Key:            step.Key,
+			Name:           step.Name,
+			Description:    step.Description,
+			EstimatedHours: step.EstimatedHours,
+			Objectives:     pq.StringArray(step.Objectives),
+			Resources:      step.Resources,
+			Order:          step.Order,
+		})
+	}
+	return steps
+}
+
+// RoadmapTemplate is a published roadmap that anyone can browse and fork
+type RoadmapTemplate struct {
+	gorm.Model
+	Slug            string           `gorm:"size:120;uniqueIndex;not null" json:"slug"`
+	Title           string           `gorm:"size:200;not null" json:"title"`
+	Topic           string           `gorm:"size:100;not null" json:"topic"`
+	Description     string           `gorm:"type:text" json:"description"`
+	Tags            pq.StringArray   `gorm:"type:text[]" json:"tags"`
+	AuthorID        uint             `gorm:"index;not null" json:"authorId"` // Learner who published the template
+	AuthorName      string           `gorm:"size:100" json:"authorName"`
+	SourceRoadmapID *uint            `gorm:"index" json:"sourceRoadmapId,omitempty"`
+	Snapshot        TemplateSnapshot `gorm:"type:jsonb" json:"-"`
+	StepCount       int              `gorm:"not null;default:0" json:"stepCount"`
+	EstimatedHours  float64          `json:"estimatedHours"`
+	ForkCount       int              `gorm:"not null;default:0" json:"forkCount"`
+	RatingCount     int              `gorm:"not null;default:0" json:"ratingCount"`
+	AverageRating   float64          `gorm:"not null;default:0" json:"averageRating"`
+}
+
+// RoadmapTemplateRating is a user's rating of a template
+type RoadmapTemplateRating struct {
+	gorm.Model
+	TemplateID uint   `gorm:"not null;uniqueIndex:idx_roadmap_template_ratings_user" json:"templateId"`
+	UserID     uint   `gorm:"not null;uniqueIndex:idx_roadmap_template_ratings_user" json:"userId"`
+	Rating     int    `gorm:"not null" json:"rating"` // From 1-5
+	Comment    string `gorm:"type:text" json:"comment,omitempty"`
+}
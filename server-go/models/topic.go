@@ -0,0 +1,427 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TopicMatchThreshold is the name similarity above which free text is resolved to an existing topic
+const TopicMatchThreshold = 0.85
+
+// Topic is a canonical subject of the catalog that free-text topic names resolve to
+type Topic struct {
+	gorm.Model
+	Slug     string         `gorm:"size:120;uniqueIndex;not null" json:"slug"`
+	Name     string         `gorm:"size:200;not null" json:"name"`
+	Aliases  pq.StringArray `gorm:"type:text[]" json:"aliases"` // Slugs of other names of the topic
+	Tags     pq.StringArray `gorm:"type:text[]" json:"tags"`
+	ParentID *uint          `gorm:"index" json:"parentId,omitempty"`
+	Parent   *Topic         `gorm:"foreignKey:ParentID" json:"parent,omitempty"`
+	Children []Topic        `gorm:"foreignKey:ParentID" json:"children,omitempty"`
+}
+
+// TopicSlug turns a topic name into its slug, keeping letters of any script and spelling out
+// the symbols that tell languages apart, so "C++" and "C#" do not both become "c"
+func TopicSlug(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == '+':
+			b.WriteString("-plus-")
+		case r == '#':
+			b.WriteString("-sharp-")
+		default:
+			b.WriteRune('-')
+		}
+	}
+	slug := strings.Join(strings.FieldsFunc(b.String(), func(r rune) bool { return r == '-' }), "-")
+	return truncateSlug(slug, 120)
+}
+
+// TopicName tidies free text into a display name, trimming and collapsing whitespace
+func TopicName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// TopicCatalog is an in-memory copy of the catalog for resolving many names at once
+type TopicCatalog struct {
+	topics  []Topic
+	bySlug  map[string]int
+	byAlias map[string]int
+}
+
+// LoadTopicCatalog loads all topics of the catalog
+func LoadTopicCatalog(db *gorm.DB) (*TopicCatalog, error) {
+	var topics []Topic
+	if err := db.Order("id ASC").Find(&topics).Error; err != nil {
+		return nil, err
+	}
+
+	catalog := &TopicCatalog{bySlug: make(map[string]int), byAlias: make(map[string]int)}
+	for _, topic := range topics {
+		catalog.add(topic)
+	}
+	return catalog, nil
+}
+
+// add indexes a topic in the catalog
+func (c *TopicCatalog) add(topic Topic) {
+	c.topics = append(c.topics, topic)
+	c.bySlug[topic.Slug] = len(c.topics) - 1
+	for _, alias := range topic.Aliases {
+		if _, taken := c.byAlias[alias]; !taken {
+			c.byAlias[alias] = len(c.topics) - 1
+		}
+	}
+}
+
+// Match finds the topic a name refers to: by slug, then by alias, then by the most similar name
+func (c *TopicCatalog) Match(name string) (Topic, bool) {
+	slug := TopicSlug(name)
+	if slug == "" {
+		return Topic{}, false
+	}
+	if i, ok := c.bySlug[slug]; ok {
+		return c.topics[i], true
+	}
+	if i, ok := c.byAlias[slug]; ok {
+		return c.topics[i], true
+	}
+
+	best, bestScore := -1, TopicMatchThreshold
+	for i, topic := range c.topics {
+		candidates := append([]string{topic.Name}, topic.Aliases...)
+		for _, candidate := range candidates {
+			candidate = strings.ReplaceAll(candidate, "-", " ")
+			// Versions are different topics however similar the names are, e.g. "Python 2" and "Python 3"
+			if topicDigits(candidate) != topicDigits(name) {
+				continue
+			}
+			if score := StepNameSimilarity(name, candidate); score >= bestScore {
+				best, bestScore = i, score
+			}
+		}
+	}
+	if best < 0 {
+		return Topic{}, false
+	}
+	return c.topics[best], true
+}
+
+// Resolve returns the topic a name refers to, adding it to the catalog if there is none.
+// A name matched by similarity is recorded as an alias so it resolves directly next time.
+func (c *TopicCatalog) Resolve(db *gorm.DB, name string) (Topic, error) {
+	name = TopicName(name)
+	slug := TopicSlug(name)
+	if slug == "" {
+		return Topic{}, fmt.Errorf("topic name %q has no letters or digits", name)
+	}
+
+	if topic, ok := c.Match(name); ok {
+		if topic.Slug != slug && !containsString(topic.Aliases, slug) {
+			if err := db.Model(&Topic{}).
+				Where("id = ? AND NOT (? = ANY(COALESCE(aliases, '{}')))", topic.ID, slug).
+				Update("aliases", gorm.Expr("array_append(aliases, ?)", slug)).Error; err != nil {
+				return Topic{}, err
+			}
+			i := c.bySlug[topic.Slug]
+			c.topics[i].Aliases = append(c.topics[i].Aliases, slug)
+			c.byAlias[slug] = i
+		}
+		return topic, nil
+	}
+
+	// Another request may add the same topic at the same time, in which case its row is used
+	topic := Topic{Slug: slug, Name: name, Aliases: pq.StringArray{}, Tags: pq.StringArray{}}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&topic).Error; err != nil {
+		return Topic{}, err
+	}
+	if topic.ID == 0 {
+		if err := db.Where("slug = ?", slug).First(&topic).Error; err != nil {
+			return Topic{}, err
+		}
+	}
+	c.add(topic)
+	return topic, nil
+}
+
+// FindTopic looks up the topic a name refers to without adding it to the catalog
+func FindTopic(db *gorm.DB, name string) (Topic, bool, error) {
+	slug := TopicSlug(name)
+	if slug == "" {
+		return Topic{}, false, nil
+	}
+
+	if topic, ok, err := findTopicBySlug(db, slug); err != nil || ok {
+		return topic, ok, err
+	}
+
+	catalog, err := LoadTopicCatalog(db)
+	if err != nil {
+		return Topic{}, false, err
+	}
+	topic, ok := catalog.Match(name)
+	return topic, ok, nil
+}
+
+// ResolveTopic returns the topic a free-text name refers to, adding it to the catalog if there is none
+func ResolveTopic(db *gorm.DB, name string) (Topic, error) {
+	slug := TopicSlug(name)
+	if slug == "" {
+		return Topic{}, fmt.Errorf("topic name %q has no letters or digits", name)
+	}
+
+	if topic, ok, err := findTopicBySlug(db, slug); err != nil || ok {
+		return topic, err
+	}
+
+	catalog, err := LoadTopicCatalog(db)
+	if err != nil {
+		return Topic{}, err
+	}
+	return catalog.Resolve(db, name)
+}
+
+// findTopicBySlug looks up a topic by its slug or one of its aliases
+func findTopicBySlug(db *gorm.DB, slug string) (Topic, bool, error) {
+	var topics []Topic
+	if err := db.Where("slug = ? OR ? = ANY(aliases)", slug, slug).Order("id ASC").Find(&topics).Error; err != nil {
+		return Topic{}, false, err
+	}
+	for _, topic := range topics {
+		if topic.Slug == slug {
+			return topic, true, nil
+		}
+	}
+	if len(topics) > 0 {
+		return topics[0], true, nil
+	}
+	return Topic{}, false, nil
+}
+
+// MigrateTopicCatalog resolves the free-text topics of existing rows against the catalog. Roadmaps,
+// topic interactions, activity logs and topic progress get their topic ID and canonical name, and
+// rows of a user that turn out to be about the same topic are merged.
+// Only rows without a topic ID are looked at, so it does nothing once everything is resolved.
+func MigrateTopicCatalog(db *gorm.DB) error {
+	catalog, err := LoadTopicCatalog(db)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		resolved := 0
+		resolve := func(table, column string) (map[string]Topic, error) {
+			var names []string
+			if err := tx.Table(table).
+				Where("topic_id IS NULL AND deleted_at IS NULL AND TRIM("+column+") <> ''").
+				Distinct(column).
+				Pluck(column, &names).Error; err != nil {
+				return nil, err
+			}
+			topics := make(map[string]Topic, len(names))
+			for _, name := range names {
+				topic, err := catalog.Resolve(tx, name)
+				if err != nil {
+					fmt.Printf("Warning: could not resolve topic %q in %s: %v\n", name, table, err)
+					continue
+				}
+				topics[name] = topic
+			}
+			resolved += len(topics)
+			return topics, nil
+		}
+
+		// Roadmaps keep the title the learner gave them
+		topics, err := resolve("roadmaps", "topic")
+		if err != nil {
+			return err
+		}
+		for name, topic := range topics {
+			if err := tx.Model(&Roadmap{}).Where("topic_id IS NULL AND topic = ?", name).
+				Update("topic_id", topic.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		topics, err = resolve("activity_logs", "topic_name")
+		if err != nil {
+			return err
+		}
+		for name, topic := range topics {
+			if err := tx.Model(&ActivityLog{}).Where("topic_id IS NULL AND topic_name = ?", name).
+				Updates(map[string]interface{}{"topic_id": topic.ID, "topic_name": topic.Name}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := migrateTopicInteractions(tx, catalog); err != nil {
+			return err
+		}
+		if err := migrateUserTopicProgress(tx, catalog); err != nil {
+			return err
+		}
+
+		if resolved > 0 {
+			fmt.Printf("INFO: Resolved %d topic names against the topic catalog\n", resolved)
+		}
+		return nil
+	})
+}
+
+// migrateTopicInteractions resolves topic interactions, merging those of a user about the same topic
+// into the oldest one
+func migrateTopicInteractions(tx *gorm.DB, catalog *TopicCatalog) error {
+	var pending []TopicInteraction
+	if err := tx.Where("topic_id IS NULL AND TRIM(topic_name) <> ''").Order("created_at ASC").Find(&pending).Error; err != nil {
+		return err
+	}
+
+	for _, interaction := range pending {
+		topic, err := catalog.Resolve(tx, interaction.TopicName)
+		if err != nil {
+			fmt.Printf("Warning: could not resolve topic %q of interaction %d: %v\n", interaction.TopicName, interaction.ID, err)
+			continue
+		}
+
+		var existing []TopicInteraction
+		if err := tx.Where("user_id = ? AND topic_id = ?", interaction.UserID, topic.ID).Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			interaction.TopicID = &topic.ID
+			interaction.TopicName = topic.Name
+			if err := tx.Save(&interaction).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		merged := existing[0]
+		merged.ViewCount += interaction.ViewCount
+		merged.TimeSpent += interaction.TimeSpent
+		if interaction.LastViewed.After(merged.LastViewed) {
+			merged.LastViewed = interaction.LastViewed
+		}
+		if interaction.CompletedAt.After(merged.CompletedAt) {
+			merged.CompletedAt = interaction.CompletedAt
+		}
+		if interaction.Rating > 0 {
+			merged.Rating = interaction.Rating
+		}
+		if interaction.Difficulty > 0 {
+			merged.Difficulty = interaction.Difficulty
+		}
+		merged.QuizScore = max(merged.QuizScore, interaction.QuizScore)
+		merged.CodeScore = max(merged.CodeScore, interaction.CodeScore)
+		if err := tx.Save(&merged).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&interaction).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateUserTopicProgress resolves topic progress, merging the rows of a user about the same topic
+func migrateUserTopicProgress(tx *gorm.DB, catalog *TopicCatalog) error {
+	var pending []UserTopicProgress
+	if err := tx.Where("topic_id IS NULL").Order("created_at ASC").Find(&pending).Error; err != nil {
+		return err
+	}
+
+	for _, progress := range pending {
+		topic, err := catalog.Resolve(tx, progress.Topic)
+		if err != nil {
+			fmt.Printf("Warning: could not resolve topic %q of progress %d: %v\n", progress.Topic, progress.ID, err)
+			continue
+		}
+
+		var existing []UserTopicProgress
+		if err := tx.Where("user_id = ? AND topic_key = ? AND id <> ?", progress.UserID, topic.Slug, progress.ID).
+			Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			if err := tx.Model(&progress).Updates(map[string]interface{}{
+				"topic_id":  topic.ID,
+				"topic":     topic.Name,
+				"topic_key": topic.Slug,
+			}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		merged := existing[0]
+		merged.TopicID = &topic.ID
+		merged.Topic = topic.Name
+		merged.Viewed = merged.Viewed || progress.Viewed
+		merged.Completed = merged.Completed || progress.Completed
+		merged.CompletedAt = earliestTime(merged.CompletedAt, progress.CompletedAt)
+		merged.QuizScore = max(merged.QuizScore, progress.QuizScore)
+		merged.CodeScore = max(merged.CodeScore, progress.CodeScore)
+		if progress.LastViewed != nil && (merged.LastViewed == nil || progress.LastViewed.After(*merged.LastViewed)) {
+			merged.LastViewed = progress.LastViewed
+		}
+		merged.Mastered = merged.Mastered || progress.Mastered
+		merged.MasteredAt = earliestTime(merged.MasteredAt, progress.MasteredAt)
+		// The unique index on the topic key is freed before the merged row is saved
+		if err := tx.Unscoped().Delete(&progress).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(&merged).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// topicDigits returns the digits of a name, which tell versions of a topic apart
+func topicDigits(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, name)
+}
+
+// truncateSlug shortens a slug to at most limit bytes without splitting a character or ending in a dash
+func truncateSlug(slug string, limit int) string {
+	if len(slug) <= limit {
+		return slug
+	}
+	cut := limit
+	for cut > 0 && slug[cut]&0xC0 == 0x80 {
+		cut--
+	}
+	return strings.TrimRight(slug[:cut], "-")
+}
+
+// earliestTime returns the earlier of two optional times
+func earliestTime(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.Before(*a)) {
+		return b
+	}
+	return a
+}
+
+// containsString reports whether a list contains a value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
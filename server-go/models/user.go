@@ -4,18 +4,19 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
-	"gorm.io/gorm"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"time"
 )
 
 // OnboardingData represents user onboarding data
 type OnboardingData struct {
-	Age            string   `json:"age"`
-	Experience     string   `json:"experience"`
-	Interests      []string `json:"interests"`
-	Goals          []string `json:"goals"`
-	LearningStyle  string   `json:"learningStyle"`
-	Completed      bool     `json:"completed"`
+	Age           string   `json:"age"`
+	Experience    string   `json:"experience"`
+	Interests     []string `json:"interests"`
+	Goals         []string `json:"goals"`
+	LearningStyle string   `json:"learningStyle"`
+	Completed     bool     `json:"completed"`
 }
 
 // Value implements the driver.Valuer interface for database serialization
@@ -53,13 +54,33 @@ func (o *OnboardingData) ensureInitialized() {
 // User represents a user in the system
 type User struct {
 	gorm.Model
-	Username       string        `gorm:"size:50;not null;uniqueIndex" json:"username"`
-	Email          string        `gorm:"size:100;uniqueIndex" json:"email"`
-	Phone          string        `gorm:"size:15;uniqueIndex" json:"phone"`
-	Password       string        `gorm:"size:100;not null" json:"-"`
-	DisplayName    string        `gorm:"size:100" json:"displayName"`
-	AvatarURL      string        `gorm:"size:255" json:"avatarUrl"`
+	Username       string         `gorm:"size:50;not null;uniqueIndex" json:"username"`
+	Email          string         `gorm:"size:100;uniqueIndex" json:"email"`
+	Phone          string         `gorm:"size:15;uniqueIndex" json:"phone"`
+	Password       string         `gorm:"size:100;not null" json:"-"`
+	DisplayName    string         `gorm:"size:100" json:"displayName"`
+	AvatarURL      string         `gorm:"size:255" json:"avatarUrl"`
 	OnboardingData OnboardingData `gorm:"type:jsonb" json:"onboardingData"`
+	Timezone       string         `gorm:"size:64;not null;default:'UTC'" json:"timezone"` // IANA name, e.g. "Europe/Moscow"
+	InsightsOptOut bool           `gorm:"not null;default:false" json:"insightsOptOut"`   // No weekly insight reports
+}
+
+// LoadTimezone loads a timezone by its IANA name. Unlike time.LoadLocation it rejects "Local" and
+// the empty name, which would depend on where the server runs.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return time.LoadLocation(name)
+}
+
+// Location returns the user's timezone, falling back to UTC if it is unset or unknown
+func (u *User) Location() *time.Location {
+	loc, err := LoadTimezone(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
 }
 
 // BeforeCreate is a GORM hook that hashes the password before creating a user
@@ -69,12 +90,12 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 		return err
 	}
 	u.Password = string(hashedPassword)
-	
+
 	// Set default display name if empty
 	if u.DisplayName == "" {
 		u.DisplayName = u.Username
 	}
-	
+
 	return nil
 }
 
@@ -93,4 +114,4 @@ func (u *User) BeforeUpdate(tx *gorm.DB) error {
 // ComparePassword compares a plain text password with the user's hashed password
 func (u *User) ComparePassword(password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-} 
\ No newline at end of file
+}
@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"mentorback/controllers"
+	"mentorback/middleware"
+)
+
+// RegisterAdminRoutes registers the admin login and the admin-only routes
+func RegisterAdminRoutes(router *gin.Engine, db *gorm.DB) {
+	adminController := controllers.NewAdminController(*controllers.NewBaseController(db))
+	analyticsController := controllers.NewAnalyticsController(*controllers.NewBaseController(db))
+	similarityController := controllers.NewSimilarityController(db)
+	exportController := controllers.NewExportController(*controllers.NewBaseController(db))
+	topicController := controllers.NewTopicController(*controllers.NewBaseController(db))
+
+	router.POST("/api/admin/login", adminController.Login)
+
+	// Create admin routes group with authentication and admin-only middleware
+	adminRoutes := router.Group("/api/admin")
+	{
+		adminRoutes.Use(middleware.Auth(db))
+		adminRoutes.Use(middleware.AdminOnly())
+
+		adminRoutes.GET("/me", adminController.GetCurrentAdmin)
+
+		// Platform analytics
+		adminRoutes.GET("/analytics/global", analyticsController.GetGlobalAnalytics)
+		adminRoutes.GET("/analytics/active-users", analyticsController.GetActiveUsers)
+		adminRoutes.GET("/analytics/cohorts", analyticsController.GetCohortRetention)
+		adminRoutes.GET("/analytics/funnel", analyticsController.GetFunnel)
+		adminRoutes.GET("/analytics/topics/drop-off", analyticsController.GetTopicDropOff)
+
+		// Raw analytics data of every user, with user IDs pseudonymized
+		adminRoutes.GET("/export", exportController.ListExportDatasets)
+		adminRoutes.GET("/export/:dataset", exportController.ExportAllData)
+
+		// Suspiciously similar code submissions of all learners
+		adminRoutes.GET("/similarity", similarityController.GetFlaggedSubmissions)
+		adminRoutes.PATCH("/similarity/:id", similarityController.ReviewSubmission)
+
+		// Topic catalog curation
+		adminRoutes.PATCH("/topics/:slug", topicController.UpdateTopic)
+	}
+}
@@ -48,7 +48,6 @@ func RegisterEnWebRoutes(router *gin.Engine, db *gorm.DB) {
 		enWebRoutes.POST("/analytics/topic-completion", analyticsController.TrackTopicCompletion)
 		enWebRoutes.POST("/analytics/exercise-activity", analyticsController.TrackExerciseActivity)
 		enWebRoutes.POST("/analytics/rate-topic", analyticsController.RateTopic)
-		enWebRoutes.GET("/analytics/global", analyticsController.GetGlobalAnalytics)
 	}
 
 	// Public routes for English API are defined in web_routes.go
@@ -20,7 +20,13 @@ func RegisterRuWebRoutes(router *gin.Engine, db *gorm.DB) {
 	chatController := controllers.NewChatController(*baseController)
 	exerciseController := controllers.NewExerciseController(*baseController)
 	progressController := controllers.NewProgressController(*baseController)
+	assessmentController := controllers.NewAssessmentController(*baseController)
+	templateController := controllers.NewTemplateController(*baseController)
+	studyPlanController := controllers.NewStudyPlanController(*baseController)
+	goalController := controllers.NewGoalController(*baseController)
 	analyticsController := controllers.NewAnalyticsController(*baseController)
+	exportController := controllers.NewExportController(*baseController)
+	insightController := controllers.NewInsightController(*baseController)
 
 	// Create web routes group
 	ruWebRoutes := router.Group("/ru/api/web")
@@ -30,7 +36,43 @@ func RegisterRuWebRoutes(router *gin.Engine, db *gorm.DB) {
 
 		ruWebRoutes.POST("/personalized-content", contentController.PersonalizedContent)
 		ruWebRoutes.POST("/roadmap", roadmapController.GenerateRoadmap)
+		ruWebRoutes.GET("/roadmaps", roadmapController.ListRoadmaps)
+		ruWebRoutes.GET("/roadmaps/:id", roadmapController.GetRoadmap)
+		ruWebRoutes.GET("/roadmaps/:id/export", roadmapController.ExportRoadmap)
+		ruWebRoutes.POST("/roadmaps/import", roadmapController.ImportRoadmap)
+		ruWebRoutes.PATCH("/roadmaps/:id", roadmapController.UpdateRoadmap)
+		ruWebRoutes.DELETE("/roadmaps/:id", roadmapController.DeleteRoadmap)
+		ruWebRoutes.POST("/roadmaps/:id/regenerate", roadmapController.RegenerateRoadmap)
+		ruWebRoutes.POST("/roadmaps/:id/publish", templateController.PublishRoadmap)
+		ruWebRoutes.GET("/roadmaps/:id/plan", studyPlanController.GetPlan)
+		ruWebRoutes.PUT("/roadmaps/:id/plan", studyPlanController.SavePlan)
+		ruWebRoutes.DELETE("/roadmaps/:id/plan", studyPlanController.DeletePlan)
+		ruWebRoutes.POST("/calendar/token", studyPlanController.RotateCalendarToken)
+		ruWebRoutes.GET("/goals", goalController.ListGoals)
+		ruWebRoutes.POST("/goals", goalController.CreateGoal)
+		ruWebRoutes.GET("/goals/:id", goalController.GetGoal)
+		ruWebRoutes.PUT("/goals/:id", goalController.UpdateGoal)
+		ruWebRoutes.DELETE("/goals/:id", goalController.DeleteGoal)
+		ruWebRoutes.POST("/templates/:slug/fork", templateController.ForkTemplate)
+		ruWebRoutes.POST("/templates/:slug/rate", templateController.RateTemplate)
+		ruWebRoutes.POST("/roadmaps/:id/revisions/:revisionId/accept", roadmapController.AcceptRevision)
+		ruWebRoutes.POST("/roadmaps/:id/revisions/:revisionId/discard", roadmapController.DiscardRevision)
+		ruWebRoutes.POST("/roadmaps/:id/steps", roadmapController.AddStep)
+		ruWebRoutes.PUT("/roadmaps/:id/steps/order", roadmapController.ReorderSteps)
+		ruWebRoutes.PATCH("/roadmaps/:id/steps/:stepId", roadmapController.UpdateStep)
+		ruWebRoutes.DELETE("/roadmaps/:id/steps/:stepId", roadmapController.DeleteStep)
+		ruWebRoutes.POST("/roadmaps/:id/steps/:stepId/complete", roadmapController.CompleteStep)
+		ruWebRoutes.DELETE("/roadmaps/:id/steps/:stepId/complete", roadmapController.UncompleteStep)
 		ruWebRoutes.POST("/exercises", exerciseController.GenerateExercises)
+		ruWebRoutes.POST("/exercises/attempt", exerciseController.SubmitAttempt)
+		ruWebRoutes.POST("/exercises/:id/hint", exerciseController.RevealHint)
+		ruWebRoutes.POST("/exercises/:id/report", exerciseController.ReportExercise)
+
+		// Assessment endpoints
+		ruWebRoutes.GET("/assessments", assessmentController.ListAssessments)
+		ruWebRoutes.POST("/assessments", assessmentController.StartAssessment)
+		ruWebRoutes.GET("/assessments/:id", assessmentController.GetAssessment)
+		ruWebRoutes.POST("/assessments/:id/submit", assessmentController.SubmitAssessment)
 		ruWebRoutes.POST("/lecture", lectureController.GenerateLecture)
 		ruWebRoutes.POST("/lecture/modular", lectureController.GenerateLecture) // Same function but with a different route name for client distinction
 		ruWebRoutes.POST("/chat", chatController.SendChatMessage)
@@ -41,6 +83,8 @@ func RegisterRuWebRoutes(router *gin.Engine, db *gorm.DB) {
 		ruWebRoutes.GET("/progress", progressController.GetUserProgress)
 		ruWebRoutes.POST("/progress", progressController.UpdateTopicProgress)
 		ruWebRoutes.GET("/progress/:topic", progressController.GetTopicProgress)
+		ruWebRoutes.GET("/progress/:topic/timeline", progressController.GetTopicTimeline)
+		ruWebRoutes.POST("/progress/events/:id/undo", progressController.UndoProgressEvent)
 
 		// Analytics routes
 		ruWebRoutes.GET("/analytics", analyticsController.GetUserAnalytics)
@@ -48,7 +92,16 @@ func RegisterRuWebRoutes(router *gin.Engine, db *gorm.DB) {
 		ruWebRoutes.POST("/analytics/topic-completion", analyticsController.TrackTopicCompletion)
 		ruWebRoutes.POST("/analytics/exercise-activity", analyticsController.TrackExerciseActivity)
 		ruWebRoutes.POST("/analytics/rate-topic", analyticsController.RateTopic)
-		ruWebRoutes.GET("/analytics/global", analyticsController.GetGlobalAnalytics)
+		ruWebRoutes.POST("/analytics/events", analyticsController.TrackEvents)
+
+		// Export of the user's own analytics data
+		ruWebRoutes.GET("/export", exportController.ListExportDatasets)
+		ruWebRoutes.GET("/export/:dataset", exportController.ExportMyData)
+
+		// Weekly insight reports
+		ruWebRoutes.GET("/insights", insightController.ListInsightReports)
+		ruWebRoutes.GET("/insights/:id", insightController.GetInsightReport)
+		ruWebRoutes.PUT("/insights/settings", insightController.UpdateInsightSettings)
 	}
 
 	// Note: Public routes for Russian API are defined in web_routes.go
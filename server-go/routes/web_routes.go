@@ -25,6 +25,13 @@ func RegisterWebRoutes(router *gin.Engine, db *gorm.DB) {
 	chatController := controllers.NewChatController(*baseController)
 	exerciseController := controllers.NewExerciseController(*baseController)
 	progressController := controllers.NewProgressController(*baseController)
+	assessmentController := controllers.NewAssessmentController(*baseController)
+	templateController := controllers.NewTemplateController(*baseController)
+	studyPlanController := controllers.NewStudyPlanController(*baseController)
+	goalController := controllers.NewGoalController(*baseController)
+	topicController := controllers.NewTopicController(*baseController)
+	exportController := controllers.NewExportController(*baseController)
+	insightController := controllers.NewInsightController(*baseController)
 
 	// Public routes for mentors
 	publicRoutes := router.Group("/api")
@@ -41,6 +48,33 @@ func RegisterWebRoutes(router *gin.Engine, db *gorm.DB) {
 
 		webRoutes.POST("/personalized-content", contentController.PersonalizedContent)
 		webRoutes.POST("/roadmap", roadmapController.GenerateRoadmap)
+		webRoutes.GET("/roadmaps", roadmapController.ListRoadmaps)
+		webRoutes.GET("/roadmaps/:id", roadmapController.GetRoadmap)
+		webRoutes.GET("/roadmaps/:id/export", roadmapController.ExportRoadmap)
+		webRoutes.POST("/roadmaps/import", roadmapController.ImportRoadmap)
+		webRoutes.PATCH("/roadmaps/:id", roadmapController.UpdateRoadmap)
+		webRoutes.DELETE("/roadmaps/:id", roadmapController.DeleteRoadmap)
+		webRoutes.POST("/roadmaps/:id/regenerate", roadmapController.RegenerateRoadmap)
+		webRoutes.POST("/roadmaps/:id/publish", templateController.PublishRoadmap)
+		webRoutes.GET("/roadmaps/:id/plan", studyPlanController.GetPlan)
+		webRoutes.PUT("/roadmaps/:id/plan", studyPlanController.SavePlan)
+		webRoutes.DELETE("/roadmaps/:id/plan", studyPlanController.DeletePlan)
+		webRoutes.POST("/calendar/token", studyPlanController.RotateCalendarToken)
+		webRoutes.GET("/goals", goalController.ListGoals)
+		webRoutes.POST("/goals", goalController.CreateGoal)
+		webRoutes.GET("/goals/:id", goalController.GetGoal)
+		webRoutes.PUT("/goals/:id", goalController.UpdateGoal)
+		webRoutes.DELETE("/goals/:id", goalController.DeleteGoal)
+		webRoutes.POST("/templates/:slug/fork", templateController.ForkTemplate)
+		webRoutes.POST("/templates/:slug/rate", templateController.RateTemplate)
+		webRoutes.POST("/roadmaps/:id/revisions/:revisionId/accept", roadmapController.AcceptRevision)
+		webRoutes.POST("/roadmaps/:id/revisions/:revisionId/discard", roadmapController.DiscardRevision)
+		webRoutes.POST("/roadmaps/:id/steps", roadmapController.AddStep)
+		webRoutes.PUT("/roadmaps/:id/steps/order", roadmapController.ReorderSteps)
+		webRoutes.PATCH("/roadmaps/:id/steps/:stepId", roadmapController.UpdateStep)
+		webRoutes.DELETE("/roadmaps/:id/steps/:stepId", roadmapController.DeleteStep)
+		webRoutes.POST("/roadmaps/:id/steps/:stepId/complete", roadmapController.CompleteStep)
+		webRoutes.DELETE("/roadmaps/:id/steps/:stepId/complete", roadmapController.UncompleteStep)
 		webRoutes.POST("/lecture", lectureController.GenerateLecture)
 		webRoutes.POST("/lecture/modular", lectureController.GenerateLecture)
 		webRoutes.POST("/chat", chatController.SendChatMessage)
@@ -52,9 +86,29 @@ func RegisterWebRoutes(router *gin.Engine, db *gorm.DB) {
 		webRoutes.GET("/progress", progressController.GetUserProgress)
 		webRoutes.POST("/progress/topic", progressController.UpdateTopicProgress)
 		webRoutes.GET("/progress/topic/:topic", progressController.GetTopicProgress)
+		webRoutes.GET("/progress/topic/:topic/timeline", progressController.GetTopicTimeline)
+		webRoutes.POST("/progress/events/:id/undo", progressController.UndoProgressEvent)
+
+		// Export of the user's own analytics data
+		webRoutes.GET("/export", exportController.ListExportDatasets)
+		webRoutes.GET("/export/:dataset", exportController.ExportMyData)
+
+		// Weekly insight reports
+		webRoutes.GET("/insights", insightController.ListInsightReports)
+		webRoutes.GET("/insights/:id", insightController.GetInsightReport)
+		webRoutes.PUT("/insights/settings", insightController.UpdateInsightSettings)
 
 		// Exercise endpoints for authenticated users
 		webRoutes.POST("/exercises", exerciseController.GenerateExercises)
+		webRoutes.POST("/exercises/attempt", exerciseController.SubmitAttempt)
+		webRoutes.POST("/exercises/:id/hint", exerciseController.RevealHint)
+		webRoutes.POST("/exercises/:id/report", exerciseController.ReportExercise)
+
+		// Assessment endpoints
+		webRoutes.GET("/assessments", assessmentController.ListAssessments)
+		webRoutes.POST("/assessments", assessmentController.StartAssessment)
+		webRoutes.GET("/assessments/:id", assessmentController.GetAssessment)
+		webRoutes.POST("/assessments/:id/submit", assessmentController.SubmitAssessment)
 	}
 
 	// Also create a public route for exercises with optional authentication
@@ -63,6 +117,12 @@ func RegisterWebRoutes(router *gin.Engine, db *gorm.DB) {
 	{
 		publicRoutes.Use(middleware.OptionalAuth(db))
 		publicRoutes.POST("/exercises", exerciseController.GenerateExercises)
+		publicRoutes.GET("/templates", templateController.ListTemplates)
+		publicRoutes.GET("/templates/:slug", templateController.GetTemplate)
+		publicRoutes.GET("/calendar/:token", studyPlanController.GetCalendarFeed)
+		publicRoutes.GET("/topics", topicController.ListTopics)
+		publicRoutes.GET("/topics/resolve", topicController.ResolveTopic)
+		publicRoutes.GET("/topics/:slug", topicController.GetTopic)
 	}
 
 	// Public Russian routes - only adding public exercises route here
@@ -71,5 +131,11 @@ func RegisterWebRoutes(router *gin.Engine, db *gorm.DB) {
 	{
 		ruPublicRoutes.Use(middleware.OptionalAuth(db))
 		ruPublicRoutes.POST("/exercises", exerciseController.GenerateExercises)
+		ruPublicRoutes.GET("/templates", templateController.ListTemplates)
+		ruPublicRoutes.GET("/templates/:slug", templateController.GetTemplate)
+		ruPublicRoutes.GET("/calendar/:token", studyPlanController.GetCalendarFeed)
+		ruPublicRoutes.GET("/topics", topicController.ListTopics)
+		ruPublicRoutes.GET("/topics/resolve", topicController.ResolveTopic)
+		ruPublicRoutes.GET("/topics/:slug", topicController.GetTopic)
 	}
 }